@@ -0,0 +1,101 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/joehattori/tgocc/types"
+)
+
+// argClass is the class of a value passed or returned by value in the System V AMD64 ABI.
+type argClass int
+
+const (
+	// classInteger values are passed in general purpose registers, one eightbyte per register.
+	classInteger argClass = iota
+	// classMemory values are passed on the stack, and returned through the hidden pointer in rdi.
+	classMemory
+)
+
+// classify returns the class of a value of type t. There are no floating point types, so nothing is SSE.
+func classify(t types.Type) argClass {
+	if isComposite(t) && (t.Size() > 16 || hasUnalignedField(t)) {
+		return classMemory
+	}
+	return classInteger
+}
+
+// hasUnalignedField reports whether t has a member, possibly nested, placed at an offset not aligned to its type.
+func hasUnalignedField(t types.Type) bool {
+	var members []*types.Member
+	switch t := t.(type) {
+	case *types.Arr:
+		return hasUnalignedField(t.Of)
+	case *types.Struct:
+		members = t.Members
+	case *types.Union:
+		members = t.Members
+	}
+	for _, m := range members {
+		if m.IsBitField {
+			continue
+		}
+		if align := m.Type.Alignment(); align > 0 && m.Offset%align != 0 || hasUnalignedField(m.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func isComposite(t types.Type) bool {
+	_, ok := t.(types.Composite)
+	return ok
+}
+
+// eightbytes returns the number of eightbytes a value of type t occupies.
+func eightbytes(t types.Type) int {
+	return (t.Size() + 7) / 8
+}
+
+// loadEightbyte loads size (up to 8) bytes at [addr+offset] into reg, zero-extended.
+// r10 is used as a scratch register.
+func loadEightbyte(reg string, addr string, offset int, size int) {
+	if size >= 8 {
+		fmt.Printf("	mov %s, [%s+%d]\n", reg, addr, offset)
+		return
+	}
+	// read byte by byte so that no byte beyond the object is touched.
+	fmt.Printf("	xor %s, %s\n", reg, reg)
+	for i := size - 1; i >= 0; i-- {
+		fmt.Printf("	shl %s, 8\n", reg)
+		fmt.Printf("	movzx r10, byte ptr [%s+%d]\n", addr, offset+i)
+		fmt.Printf("	or %s, r10\n", reg)
+	}
+}
+
+// storeEightbyte stores the lower size (up to 8) bytes of reg to [addr+offset].
+// r10 is used as a scratch register.
+func storeEightbyte(reg string, addr string, offset int, size int) {
+	if size >= 8 {
+		fmt.Printf("	mov [%s+%d], %s\n", addr, offset, reg)
+		return
+	}
+	fmt.Printf("	mov r10, %s\n", reg)
+	for i := 0; i < size; i++ {
+		fmt.Printf("	mov [%s+%d], r10b\n", addr, offset+i)
+		fmt.Println("	shr r10, 8")
+	}
+}
+
+// copyMem copies size bytes from [src+srcOffset] to [dst+dstOffset].
+// r10 is used as a scratch register.
+func copyMem(dst string, dstOffset int, src string, srcOffset int, size int) {
+	i := 0
+	for ; i+8 <= size; i += 8 {
+		fmt.Printf("	mov r10, [%s+%d]\n", src, srcOffset+i)
+		fmt.Printf("	mov [%s+%d], r10\n", dst, dstOffset+i)
+	}
+	for ; i < size; i++ {
+		fmt.Printf("	mov r10b, [%s+%d]\n", src, srcOffset+i)
+		fmt.Printf("	mov [%s+%d], r10b\n", dst, dstOffset+i)
+	}
+}
@@ -0,0 +1,522 @@
+package ast
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/joehattori/tgocc/types"
+)
+
+type (
+	// AsmNode represents an asm statement, whose template is written in Intel syntax as the rest of the output.
+	// The template of basic asm, which has no operands, is emitted as it is.
+	// That of extended asm has its operands substituted for `%0`, `%[name]` and so on.
+	AsmNode struct {
+		template string
+		isBasic  bool
+		outputs  []*AsmOperand
+		inputs   []*AsmOperand
+		// saved holds the callee-saved registers used by the asm, which are restored after it.
+		saved []string
+	}
+
+	// AsmOperand is an operand of extended asm, e.g) `[name] "=r"(x)`.
+	AsmOperand struct {
+		name string
+		expr Node
+		ty   types.Type
+		kind asmOperandKind
+		// reg is the register holding the value, or the address for a memory operand.
+		reg string
+		imm int64
+		// match is the index of the output operand which an input operand with a matching constraint shares the register with,
+		// or -1.
+		match       int
+		isReadWrite bool
+		// specific is set when the constraint names a register, e.g) `a` for rax.
+		specific bool
+	}
+
+	asmOperandKind int
+)
+
+const (
+	asmReg asmOperandKind = iota
+	asmMem
+	asmImm
+)
+
+// asmRegs holds the general purpose registers usable by asm, with their 32, 16 and 8 bits parts.
+// rsp and rbp are reserved for the frame.
+var asmRegs = map[string][3]string{
+	"rax": {"eax", "ax", "al"},
+	"rbx": {"ebx", "bx", "bl"},
+	"rcx": {"ecx", "cx", "cl"},
+	"rdx": {"edx", "dx", "dl"},
+	"rsi": {"esi", "si", "sil"},
+	"rdi": {"edi", "di", "dil"},
+	"r8":  {"r8d", "r8w", "r8b"},
+	"r9":  {"r9d", "r9w", "r9b"},
+	"r10": {"r10d", "r10w", "r10b"},
+	"r11": {"r11d", "r11w", "r11b"},
+	"r12": {"r12d", "r12w", "r12b"},
+	"r13": {"r13d", "r13w", "r13b"},
+	"r14": {"r14d", "r14w", "r14b"},
+	"r15": {"r15d", "r15w", "r15b"},
+}
+
+// asmRegOrder is the order in which the registers are allocated to the operands.
+// The callee-saved ones come last, since they have to be saved around the asm.
+var asmRegOrder = [...]string{"rax", "rdx", "rcx", "rsi", "rdi", "r8", "r9", "r10", "r11", "rbx", "r12", "r13", "r14", "r15"}
+
+// asmConstraintRegs are the constraints which name a register.
+var asmConstraintRegs = map[byte]string{'a': "rax", 'b': "rbx", 'c': "rcx", 'd': "rdx", 'S': "rsi", 'D': "rdi"}
+
+func isCalleeSaved(reg string) bool {
+	switch reg {
+	case "rbx", "r12", "r13", "r14", "r15":
+		return true
+	}
+	return false
+}
+
+// NewBasicAsmNode returns basic asm, whose template is emitted without substitution.
+func NewBasicAsmNode(template string) *AsmNode {
+	return &AsmNode{template: template, isBasic: true}
+}
+
+// IsBasic reports whether a is basic asm.
+func (a *AsmNode) IsBasic() bool {
+	return a.isBasic
+}
+
+// LoadType returns the type of the asm statement, which has no value.
+func (*AsmNode) LoadType() types.Type {
+	return types.NewEmpty()
+}
+
+// NewAsmOperand returns the operand of extended asm with the constraint, which is an output operand when isOutput is set.
+// expr is addressable for output and memory operands, and ty is the type of its value after the lvalue conversion.
+func NewAsmOperand(name string, constraint string, expr Node, ty types.Type, isOutput bool) *AsmOperand {
+	o := &AsmOperand{name: name, expr: expr, ty: ty, match: -1}
+	var allowsReg, allowsMem, allowsImm bool
+	for i := 0; i < len(constraint); i++ {
+		c := constraint[i]
+		switch {
+		case c == '=' || c == '+':
+			if !isOutput {
+				log.Fatalf("Input operand constraint contains '%c'", c)
+			}
+			o.isReadWrite = c == '+'
+		case c == '&' || c == '%':
+			// every operand is given a register of its own, so that an early clobbered output never overlaps an input.
+		case c == 'r' || c == 'q' || c == 'g':
+			allowsReg = true
+			allowsMem = allowsMem || c == 'g'
+			allowsImm = allowsImm || c == 'g'
+		case asmConstraintRegs[c] != "":
+			if o.reg != "" && o.reg != asmConstraintRegs[c] {
+				log.Fatalf("Constraint %s names more than one register", constraint)
+			}
+			o.reg, o.specific = asmConstraintRegs[c], true
+		case c == 'm' || c == 'o' || c == 'V':
+			allowsMem = true
+		case c == 'i' || c == 'n' || strings.IndexByte("IJKLMNeZ", c) >= 0:
+			allowsImm = true
+		case '0' <= c && c <= '9':
+			if isOutput {
+				log.Fatalf("Matching constraint %s is not allowed in output operand", constraint)
+			}
+			j := i
+			for j < len(constraint) && '0' <= constraint[j] && constraint[j] <= '9' {
+				j++
+			}
+			o.match, _ = strconv.Atoi(constraint[i:j])
+			i = j - 1
+		case c == ',':
+			log.Fatalf("Multiple alternative constraints are not supported: %s", constraint)
+		default:
+			log.Fatalf("Invalid constraint %s in asm", constraint)
+		}
+	}
+	if isOutput && !strings.ContainsAny(constraint, "=+") {
+		log.Fatalf("Output operand constraint %s lacks '='", constraint)
+	}
+
+	_, isAddressable := expr.(AddressableNode)
+	switch {
+	case allowsImm && !isOutput && types.IsInteger(ty) && isConst(expr):
+		o.kind, o.imm = asmImm, Eval(expr)
+		o.reg, o.specific = "", false
+	case o.reg != "" || o.match >= 0 || allowsReg && (isScalarSize(ty) || !allowsMem):
+		o.kind = asmReg
+	case allowsMem:
+		if !isAddressable {
+			log.Fatalf("Memory operand with constraint %s is not addressable", constraint)
+		}
+		o.kind = asmMem
+	case allowsImm:
+		log.Fatalf("Impossible constraint %s in asm: the operand is not an integer constant", constraint)
+	default:
+		log.Fatalf("Impossible constraint %s in asm", constraint)
+	}
+	if o.kind == asmReg && !isScalarSize(ty) {
+		log.Fatalf("Operand of type %T with constraint %s does not fit in a register", ty, constraint)
+	}
+	if isOutput && !isAddressable {
+		log.Fatalf("Output operand with constraint %s is not an lvalue", constraint)
+	}
+	return o
+}
+
+func isConst(n Node) bool {
+	_, err := TryEval(n)
+	return err == nil
+}
+
+func isScalarSize(t types.Type) bool {
+	if isComposite(t) {
+		return false
+	}
+	switch t.Size() {
+	case 1, 2, 4, 8:
+		return true
+	}
+	return false
+}
+
+// NewAsmNode returns extended asm, allocating the registers to the operands.
+// The registers named in clobbers, e.g) "rbx", "memory" or "cc", are not allocated to any operand.
+func NewAsmNode(template string, outputs []*AsmOperand, inputs []*AsmOperand, clobbers []string) *AsmNode {
+	if len(outputs)+len(inputs) > 30 {
+		log.Fatal("More than 30 operands in asm")
+	}
+	a := &AsmNode{template: template, outputs: outputs, inputs: inputs}
+	// used holds the registers which are not allocated anymore.
+	used := map[string]bool{}
+	clobbered := map[string]bool{}
+	for _, c := range clobbers {
+		switch c {
+		case "memory", "cc":
+			// nothing is cached in registers across statements, so they need nothing.
+			continue
+		}
+		reg, ok := asmRegName(c)
+		if !ok {
+			log.Fatalf("Unknown register name %s in asm clobber list", c)
+		}
+		if reg != "" {
+			clobbered[reg] = true
+			used[reg] = true
+		}
+	}
+
+	// the registers named by constraints are taken first. An input may share one with an output, which it is loaded before.
+	for _, ops := range [][]*AsmOperand{outputs, inputs} {
+		taken := map[string]bool{}
+		for _, o := range ops {
+			if !o.specific {
+				continue
+			}
+			if taken[o.reg] {
+				log.Fatalf("Register %s is named by more than one operand in asm", o.reg)
+			}
+			if clobbered[o.reg] {
+				log.Fatalf("Register %s of an operand conflicts with asm clobber list", o.reg)
+			}
+			taken[o.reg] = true
+			used[o.reg] = true
+		}
+	}
+	for _, in := range inputs {
+		for _, out := range outputs {
+			if in.specific && out.reg == in.reg && out.isReadWrite {
+				log.Fatalf("Register %s of an input operand is also read by a read-write output operand", in.reg)
+			}
+		}
+	}
+	for _, o := range inputs {
+		if o.match < 0 {
+			continue
+		}
+		if o.match >= len(outputs) {
+			log.Fatalf("Matching constraint references invalid operand number %d", o.match)
+		}
+		out := outputs[o.match]
+		if out.kind != asmReg {
+			log.Fatalf("Matching constraint references non-register operand %d", o.match)
+		}
+		if o.specific && (!out.specific || out.reg != o.reg) {
+			log.Fatalf("Matching constraint of operand %d names another register", o.match)
+		}
+	}
+
+	for _, ops := range [][]*AsmOperand{outputs, inputs} {
+		for _, o := range ops {
+			if o.reg != "" || o.kind == asmImm || o.match >= 0 {
+				continue
+			}
+			o.reg = allocAsmReg(used)
+		}
+	}
+	for _, o := range inputs {
+		if o.match >= 0 {
+			o.reg = outputs[o.match].reg
+		}
+	}
+
+	for _, r := range asmRegOrder {
+		if isCalleeSaved(r) && used[r] {
+			a.saved = append(a.saved, r)
+		}
+	}
+	return a
+}
+
+func allocAsmReg(used map[string]bool) string {
+	for _, r := range asmRegOrder {
+		if !used[r] {
+			used[r] = true
+			return r
+		}
+	}
+	log.Fatal("Impossible register constraints in asm: no register is left")
+	return ""
+}
+
+// asmRegName returns the 64-bit register which the register name s in a clobber list, e.g) "%eax", is part of.
+// It returns "" for a register which no operand is allocated to, such as xmm0.
+func asmRegName(s string) (string, bool) {
+	s = strings.TrimPrefix(s, "%")
+	for r, subs := range asmRegs {
+		if s == r || s == subs[0] || s == subs[1] || s == subs[2] {
+			return r, true
+		}
+	}
+	switch s {
+	case "ah", "bh", "ch", "dh":
+		return "r" + s[:1] + "x", true
+	}
+	if strings.HasPrefix(s, "xmm") || strings.HasPrefix(s, "st") || s == "flags" || s == "dirflag" || s == "fpsr" {
+		return "", true
+	}
+	return "", false
+}
+
+// gen evaluates the operands onto the stack, loads them to their registers and emits the template.
+// The register outputs are stored to their lvalues after that. The stack is left as it was.
+func (a *AsmNode) gen() {
+	if a.isBasic {
+		fmt.Printf("	%s\n", a.template)
+		return
+	}
+	// depth is the number of the eightbytes pushed since the beginning.
+	// A slot is the depth right after an eightbyte is pushed, which is at [rsp+8*(depth-slot)].
+	depth := 0
+	at := func(slot int) string {
+		return fmt.Sprintf("[rsp+%d]", 8*(depth-slot))
+	}
+	// slots holds the addresses of the outputs and memory inputs, and the values of the register inputs.
+	slots := map[*AsmOperand]int{}
+	for _, o := range a.outputs {
+		o.expr.(AddressableNode).genAddr()
+		depth++
+		slots[o] = depth
+	}
+	for _, o := range a.inputs {
+		switch o.kind {
+		case asmMem:
+			o.expr.(AddressableNode).genAddr()
+		case asmReg:
+			o.expr.gen()
+		default:
+			continue
+		}
+		depth++
+		slots[o] = depth
+	}
+	// the current values of the read-write register outputs are loaded through their addresses.
+	values := map[*AsmOperand]int{}
+	for _, o := range a.outputs {
+		if o.kind == asmReg && o.isReadWrite {
+			fmt.Printf("	push %s\n", at(slots[o]))
+			loadFrom(o.expr.(AddressableNode))
+			depth++
+			values[o] = depth
+		}
+	}
+
+	for _, r := range a.saved {
+		fmt.Printf("	push %s\n", r)
+		depth++
+	}
+	for _, o := range a.outputs {
+		if o.kind == asmMem {
+			fmt.Printf("	mov %s, %s\n", o.reg, at(slots[o]))
+		} else if o.isReadWrite {
+			fmt.Printf("	mov %s, %s\n", o.reg, at(values[o]))
+		}
+	}
+	for _, o := range a.inputs {
+		if o.kind != asmImm {
+			fmt.Printf("	mov %s, %s\n", o.reg, at(slots[o]))
+		}
+	}
+
+	fmt.Printf("	%s\n", a.substitute())
+
+	// the register outputs are pushed, and then stored one by one with their addresses put on top of them.
+	pushed := 0
+	for _, o := range a.outputs {
+		if o.kind == asmReg {
+			fmt.Printf("	push %s\n", o.reg)
+			depth++
+			pushed++
+			values[o] = depth
+		}
+	}
+	for _, o := range a.outputs {
+		if o.kind != asmReg {
+			continue
+		}
+		fmt.Printf("	push %s\n", at(slots[o]))
+		depth++
+		fmt.Printf("	push %s\n", at(values[o]))
+		depth++
+		storeTo(o.expr.(AddressableNode))
+		fmt.Println("	add rsp, 8")
+		depth -= 2
+	}
+	if pushed > 0 {
+		fmt.Printf("	add rsp, %d\n", 8*pushed)
+	}
+	for i := len(a.saved) - 1; i >= 0; i-- {
+		fmt.Printf("	pop %s\n", a.saved[i])
+	}
+	if depth -= pushed + len(a.saved); depth > 0 {
+		fmt.Printf("	add rsp, %d\n", 8*depth)
+	}
+}
+
+// substitute returns the template with the operands substituted, following GCC's Intel syntax output.
+// `%0` is replaced by the register of the size of the operand, e.g) eax for int, by `dword ptr [rax]` for a memory operand,
+// or by the value of an immediate operand. The modifiers b, w, k and q select the 8, 16, 32 and 64 bits part of the register,
+// h the high byte, and c the bare constant. `%%` is replaced by `%`, and `%=` by a number unique to the asm.
+func (a *AsmNode) substitute() string {
+	operands := append(append([]*AsmOperand{}, a.outputs...), a.inputs...)
+	s := a.template
+	var b strings.Builder
+	unique := labelCount
+	labelCount++
+	for {
+		i := strings.IndexByte(s, '%')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i+1:]
+		if s == "" {
+			log.Fatal("Template of asm ends with %")
+		}
+		switch s[0] {
+		case '%':
+			b.WriteByte('%')
+			s = s[1:]
+			continue
+		case '=':
+			b.WriteString(strconv.Itoa(unique))
+			s = s[1:]
+			continue
+		}
+		var modifier byte
+		if strings.IndexByte("bhwkqcPl", s[0]) >= 0 {
+			modifier = s[0]
+			s = s[1:]
+		}
+		var o *AsmOperand
+		if strings.HasPrefix(s, "[") {
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				log.Fatalf("Missing ']' in asm template: %s", a.template)
+			}
+			name := s[1:end]
+			for _, op := range operands {
+				if op.name == name {
+					o = op
+				}
+			}
+			if o == nil {
+				log.Fatalf("Undefined named operand %s in asm", name)
+			}
+			s = s[end+1:]
+		} else {
+			j := 0
+			for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+				j++
+			}
+			if j == 0 {
+				log.Fatalf("Invalid %%-code in asm template: %s", a.template)
+			}
+			n, _ := strconv.Atoi(s[:j])
+			if n >= len(operands) {
+				log.Fatalf("Operand number %d is out of range in asm", n)
+			}
+			o = operands[n]
+			s = s[j:]
+		}
+		b.WriteString(o.text(modifier))
+	}
+}
+
+// text returns the operand as it is written in the template with the modifier, which is 0 when omitted.
+func (o *AsmOperand) text(modifier byte) string {
+	switch o.kind {
+	case asmImm:
+		return strconv.FormatInt(o.imm, 10)
+	case asmMem:
+		return memOperand(o.reg, o.expr.LoadType().Size())
+	}
+	size := o.ty.Size()
+	switch modifier {
+	case 'b':
+		size = 1
+	case 'w':
+		size = 2
+	case 'k':
+		size = 4
+	case 'q':
+		size = 8
+	case 'h':
+		if !strings.Contains("rax rbx rcx rdx", o.reg) {
+			log.Fatalf("Register %s has no high byte", o.reg)
+		}
+		return o.reg[1:2] + "h"
+	}
+	switch size {
+	case 1:
+		return asmRegs[o.reg][2]
+	case 2:
+		return asmRegs[o.reg][1]
+	case 4:
+		return asmRegs[o.reg][0]
+	}
+	return o.reg
+}
+
+// memOperand returns the memory operand at the address in reg of an object of size bytes.
+func memOperand(reg string, size int) string {
+	switch size {
+	case 1:
+		return fmt.Sprintf("byte ptr [%s]", reg)
+	case 2:
+		return fmt.Sprintf("word ptr [%s]", reg)
+	case 4:
+		return fmt.Sprintf("dword ptr [%s]", reg)
+	case 8:
+		return fmt.Sprintf("qword ptr [%s]", reg)
+	}
+	return fmt.Sprintf("[%s]", reg)
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/joehattori/tgocc/types"
 	"github.com/joehattori/tgocc/vars"
@@ -10,32 +11,186 @@ import (
 type Ast struct {
 	Fns   []*FnNode
 	GVars []*vars.GVar
+	// Asms holds the basic asm at file scope, which is emitted before everything else.
+	Asms []*AsmNode
+
+	// FunctionSections and DataSections place each function and each global variable in a section of its own,
+	// so that the linker can discard the unused ones with --gc-sections.
+	FunctionSections bool
+	DataSections     bool
 }
 
 func (a *Ast) Gen() {
 	fmt.Println(".intel_syntax noprefix")
+	for _, asm := range a.Asms {
+		asm.gen()
+	}
 	a.genData()
 	a.genText()
+	a.genSyms()
 }
 
 func (a *Ast) genData() {
-	fmt.Println(".data")
 	for _, g := range a.GVars {
-		if g.Emit {
-			fmt.Printf(".globl %s\n", g.Name())
-			fmt.Printf("%s:\n", g.Name())
+		if !g.Emit || g.IsDiscarded() {
+			continue
+		}
+		label := g.Label()
+		if g.Init == nil && g.Section == "" && !g.IsWeak && !g.IsTLS {
+			// a tentative definition or a static variable without initializer is a common symbol,
+			// which the linker merges with the definitions of the same name in other translation units.
+			if g.IsStatic {
+				fmt.Printf(".local %s\n", label)
+			}
+			genVisibility(label, g)
+			fmt.Printf(".comm %s, %d, %d\n", label, g.Type().Size(), g.Alignment())
+			continue
+		}
+		genBinding(label, g)
+		isZero := g.Init == nil || g.Init.IsZero()
+		if s, ok := g.Init.(*vars.GVarInitStr); ok && g.IsStrLit && s.IsCString() {
+			// the linker merges identical strings in this section.
+			fmt.Printf(".section .rodata.str%d.%d,\"aMS\",@progbits,%d\n", s.ElemSize, s.ElemSize, s.ElemSize)
+		} else if g.Section != "" {
+			fmt.Println(userDataSection(g.Section, types.IsConst(g.Type()) && !g.IsTLS, isZero, g.IsTLS))
+		} else if g.IsTLS && isZero {
+			// each thread gets its own copy of the thread-local sections, which the C runtime sets up.
+			fmt.Println(a.dataSection(".tbss", label))
+		} else if g.IsTLS {
+			fmt.Println(a.dataSection(".tdata", label))
+		} else if types.IsConst(g.Type()) || g.IsStrLit {
+			// const objects and string literals are never written, so they live in the read-only section.
+			fmt.Println(a.dataSection(".rodata", label))
+		} else if isZero {
+			fmt.Println(a.dataSection(".bss", label))
+		} else {
+			fmt.Println(a.dataSection(".data", label))
+		}
+		fmt.Printf(".align %d\n", g.Alignment())
+		if !strings.HasPrefix(label, ".L") {
+			fmt.Printf(".type %s, @object\n", label)
+			fmt.Printf(".size %s, %d\n", label, g.Type().Size())
+		}
+		fmt.Printf("%s:\n", label)
+		if isZero {
+			fmt.Printf("	.zero %d\n", g.Type().Size())
+		} else {
+			genDataGVar(g.Init, g.Type())
+		}
+	}
+}
+
+// genBinding emits the binding and the visibility of the symbol label of g.
+func genBinding(label string, g *vars.GVar) {
+	if g.IsWeak {
+		fmt.Printf(".weak %s\n", label)
+	} else if !g.IsStatic {
+		fmt.Printf(".globl %s\n", label)
+	}
+	genVisibility(label, g)
+}
+
+func genVisibility(label string, g *vars.GVar) {
+	if g.Visibility != "" && g.Visibility != "default" {
+		fmt.Printf(".%s %s\n", g.Visibility, label)
+	}
+}
+
+// userDataSection returns the directive switching to the section name, whose flags are guessed from the name as GCC does.
+func userDataSection(name string, isConst bool, isZero bool, isTLS bool) string {
+	flags, typ := "aw", "@progbits"
+	if isConst {
+		flags = "a"
+	} else if isZero && (strings.HasPrefix(name, ".bss") || strings.HasPrefix(name, ".tbss")) {
+		typ = "@nobits"
+	}
+	if isTLS {
+		flags += "T"
+	}
+	return fmt.Sprintf(".section %s,\"%s\",%s", name, flags, typ)
+}
+
+// dataSection returns the directive switching to the section named kind, e.g) .bss, for the global variable label.
+func (a *Ast) dataSection(kind string, label string) string {
+	flags := map[string]string{
+		".data":   `"aw",@progbits`,
+		".bss":    `"aw",@nobits`,
+		".rodata": `"a",@progbits`,
+		".tdata":  `"awT",@progbits`,
+		".tbss":   `"awT",@nobits`,
+	}[kind]
+	if !a.DataSections {
+		switch kind {
+		case ".data", ".bss":
+			return kind
+		case ".rodata":
+			return ".section .rodata"
 		}
-		genDataGVar(g.Init, g.Type())
+		return fmt.Sprintf(".section %s,%s", kind, flags)
 	}
+	return fmt.Sprintf(".section %s.%s,%s", kind, label, flags)
 }
 
 func (a *Ast) genText() {
-	fmt.Println(".text")
 	for _, f := range a.Fns {
+		if f.Sym.IsDiscarded() {
+			continue
+		}
+		switch {
+		case f.Sym.Section != "":
+			fmt.Printf(".section %s,\"ax\",@progbits\n", f.Sym.Section)
+		case a.FunctionSections:
+			fmt.Printf(".section .text.%s,\"ax\",@progbits\n", f.name)
+		default:
+			fmt.Println(".text")
+		}
 		f.gen()
 	}
 }
 
+// genSyms emits the aliases, the weak references and the arrays of the constructors and destructors.
+func (a *Ast) genSyms() {
+	for _, g := range a.GVars {
+		label := g.Label()
+		fn, isFn := g.Type().(*types.Fn)
+		switch {
+		case g.Alias != "":
+			genBinding(label, g)
+			if isFn {
+				fmt.Printf(".type %s, @function\n", label)
+			} else {
+				fmt.Printf(".type %s, @object\n", label)
+				fmt.Printf(".size %s, %d\n", label, g.Type().Size())
+			}
+			fmt.Printf(".set %s, %s\n", label, g.Alias)
+		case isFn && !fn.IsComplete || !isFn && !g.Emit:
+			// the symbol defined in another translation unit may be referred weakly or with a visibility.
+			if g.IsWeak {
+				fmt.Printf(".weak %s\n", label)
+			}
+			genVisibility(label, g)
+		}
+	}
+	for _, g := range a.GVars {
+		if g.IsCtor {
+			genInitArray(".init_array", g.CtorPriority, g.Label())
+		}
+		if g.IsDtor {
+			genInitArray(".fini_array", g.DtorPriority, g.Label())
+		}
+	}
+}
+
+// genInitArray adds the function label to the section such as .init_array, suffixed with the priority if any.
+func genInitArray(section string, priority int, label string) {
+	if priority > 0 {
+		section = fmt.Sprintf("%s.%05d", section, priority)
+	}
+	fmt.Printf(".section %s,\"aw\"\n", section)
+	fmt.Println(".align 8")
+	fmt.Printf("	.quad %s\n", label)
+}
+
 func genDataGVar(init vars.GVarInit, t types.Type) {
 	if init == nil {
 		fmt.Printf("	.zero %d\n", t.Size())
@@ -0,0 +1,236 @@
+package ast
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joehattori/tgocc/types"
+)
+
+type (
+	// AtomicNode is an atomic operation on the object which ptr points to, done by a built-in function such as __atomic_fetch_add.
+	// Every operation is sequentially consistent regardless of the memory order given to the built-in.
+	AtomicNode struct {
+		op AtomicOp
+		// ty is the type of the object.
+		ty  types.Type
+		ptr Node
+		// val is the operand of the operation, which is the desired value for AtomicCAS.
+		val Node
+		// expected is the value compared with the object by AtomicCAS, or the pointer to it when isExpectedPtr is set,
+		// where the current value is stored on failure.
+		expected      Node
+		isExpectedPtr bool
+		// returnsNew is set when the result is the new value instead of the old one, e.g) __atomic_add_fetch.
+		returnsNew bool
+		// returnsOld is set when the result of AtomicCAS is the old value instead of whether the object was replaced,
+		// e.g) __sync_val_compare_and_swap.
+		returnsOld bool
+	}
+
+	// AtomicOp is the kind of an atomic operation.
+	AtomicOp int
+)
+
+const (
+	AtomicLoad AtomicOp = iota
+	AtomicStore
+	AtomicExchange
+	AtomicFetchAdd
+	AtomicFetchSub
+	AtomicFetchAnd
+	AtomicFetchOr
+	AtomicFetchXor
+	AtomicFetchNand
+	AtomicCAS
+	// AtomicFence orders the memory accesses before it and after it.
+	AtomicFence
+)
+
+// NewAtomicNode returns the atomic operation op on the object of type ty which ptr points to, with the operand val.
+// The result is the new value when returnsNew is set, and the old one otherwise.
+func NewAtomicNode(op AtomicOp, ty types.Type, ptr Node, val Node, returnsNew bool) *AtomicNode {
+	return &AtomicNode{op: op, ty: ty, ptr: ptr, val: val, returnsNew: returnsNew}
+}
+
+// NewAtomicCASNode returns the compare-and-swap of the object of type ty which ptr points to,
+// which replaces it with desired if it equals the expected value.
+// The result is whether it was replaced, or the old value when returnsOld is set.
+func NewAtomicCASNode(ty types.Type, ptr Node, expected Node, isExpectedPtr bool, desired Node, returnsOld bool) *AtomicNode {
+	return &AtomicNode{op: AtomicCAS, ty: ty, ptr: ptr, val: desired, expected: expected, isExpectedPtr: isExpectedPtr, returnsOld: returnsOld}
+}
+
+// NewAtomicFenceNode returns a full memory barrier.
+func NewAtomicFenceNode() *AtomicNode {
+	return &AtomicNode{op: AtomicFence}
+}
+
+func (a *AtomicNode) LoadType() types.Type {
+	switch a.op {
+	case AtomicStore, AtomicFence:
+		return types.NewVoid()
+	case AtomicCAS:
+		if !a.returnsOld {
+			return types.NewBool()
+		}
+	}
+	return types.Unqualified(a.ty)
+}
+
+func (a *AtomicNode) gen() {
+	switch a.op {
+	case AtomicFence:
+		fmt.Println("	mfence")
+		fmt.Println("	push 0")
+		return
+	case AtomicLoad:
+		// a load on x86-64 is atomic by itself, and ordered after the stores since they are done by xchg.
+		a.ptr.gen()
+		load(a.ty)
+		return
+	case AtomicCAS:
+		a.genCAS()
+		return
+	}
+	a.ptr.gen()
+	a.val.gen()
+	size := a.ty.Size()
+	switch a.op {
+	case AtomicFetchAnd, AtomicFetchOr, AtomicFetchXor, AtomicFetchNand:
+		inst := map[AtomicOp]string{AtomicFetchAnd: "and", AtomicFetchOr: "or", AtomicFetchXor: "xor", AtomicFetchNand: "and"}[a.op]
+		genAtomicRMW(a.ty, func() {
+			fmt.Println("	pop rdi")
+			fmt.Println("	pop rax")
+			fmt.Printf("	%s rax, rdi\n", inst)
+			if a.op == AtomicFetchNand {
+				fmt.Println("	not rax")
+			}
+			fmt.Println("	push rax")
+		})
+		fmt.Println("	pop rax")
+		if a.returnsNew {
+			fmt.Println("	mov [rsp], rax")
+		}
+		return
+	}
+	fmt.Println("	pop rdi")
+	fmt.Println("	pop rax")
+	convert("rdi", a.ty)
+	switch a.op {
+	case AtomicStore:
+		// xchg with memory is locked implicitly, which makes the store sequentially consistent.
+		fmt.Printf("	xchg [rax], %s\n", sizedReg("rdi", size))
+		fmt.Println("	push 0")
+		return
+	case AtomicExchange:
+		fmt.Printf("	xchg [rax], %s\n", sizedReg("rdi", size))
+	case AtomicFetchAdd, AtomicFetchSub:
+		fmt.Println("	mov r8, rdi")
+		if a.op == AtomicFetchSub {
+			fmt.Println("	neg rdi")
+		}
+		fmt.Printf("	lock xadd [rax], %s\n", sizedReg("rdi", size))
+		if a.returnsNew {
+			if a.op == AtomicFetchSub {
+				fmt.Println("	sub rdi, r8")
+			} else {
+				fmt.Println("	add rdi, r8")
+			}
+		}
+	default:
+		log.Fatal("Unhandled atomic operation")
+	}
+	// rdi holds the old value, or the new one.
+	convert("rdi", a.ty)
+	fmt.Println("	push rdi")
+}
+
+// genCAS compares the object with the expected value and replaces it with the desired one if they are equal, by lock cmpxchg.
+func (a *AtomicNode) genCAS() {
+	size := a.ty.Size()
+	a.ptr.gen()
+	a.expected.gen()
+	a.val.gen()
+	fmt.Println("	pop rdi")
+	fmt.Println("	pop rsi")
+	fmt.Println("	pop rdx")
+	convert("rdi", a.ty)
+	if a.isExpectedPtr {
+		fmt.Printf("	mov %s, %s\n", sizedReg("rax", size), memOperand("rsi", size))
+	} else {
+		fmt.Println("	mov rax, rsi")
+	}
+	fmt.Printf("	lock cmpxchg %s, %s\n", memOperand("rdx", size), sizedReg("rdi", size))
+	fmt.Println("	sete cl")
+	if a.isExpectedPtr {
+		// the current value is written back to the expected one on failure.
+		c := labelCount
+		labelCount++
+		fmt.Printf("	je .L.cas.%d\n", c)
+		fmt.Printf("	mov %s, %s\n", memOperand("rsi", size), sizedReg("rax", size))
+		fmt.Printf(".L.cas.%d:\n", c)
+	}
+	if a.returnsOld {
+		extend("rax", a.ty)
+		fmt.Println("	push rax")
+		return
+	}
+	fmt.Println("	movzx eax, cl")
+	fmt.Println("	push rax")
+}
+
+// genAtomicRMW replaces the value of the atomic object of type t at the address right below the operand on the stack top
+// with the one which op computes from the old value and the operand, by lock cmpxchg.
+// It is retried while another thread modifies the object in between. Any operation is thus atomic and sequentially consistent.
+// The address and the operand on the stack are replaced by the old value and the new one.
+func genAtomicRMW(t types.Type, op func()) {
+	c := labelCount
+	labelCount++
+	fmt.Println("	push [rsp+8]")
+	load(t)
+	fmt.Printf(".L.atomic.%d:\n", c)
+	// op consumes the copies of the old value and the operand.
+	fmt.Println("	push [rsp]")
+	fmt.Println("	push [rsp+16]")
+	op()
+	fmt.Println("	pop rdi")
+	convert("rdi", t)
+	fmt.Println("	pop rax")
+	fmt.Println("	mov rsi, [rsp+8]")
+	fmt.Printf("	lock cmpxchg [rsi], %s\n", sizedReg("rdi", t.Size()))
+	fmt.Printf("	je .L.atomic.%d.done\n", c)
+	// cmpxchg has loaded the current value into rax, which is tried next.
+	extend("rax", t)
+	fmt.Println("	push rax")
+	fmt.Printf("	jmp .L.atomic.%d\n", c)
+	fmt.Printf(".L.atomic.%d.done:\n", c)
+	fmt.Println("	add rsp, 16")
+	fmt.Println("	push rax")
+	fmt.Println("	push rdi")
+}
+
+// genIncDecResult leaves the value of an atomic increment or decrement from the old value and the new one on the stack,
+// which is the new one for the prefix form and the old one for the postfix form.
+func genIncDecResult(isPre bool) {
+	fmt.Println("	pop rax")
+	if !isPre {
+		return
+	}
+	fmt.Println("	mov [rsp], rax")
+}
+
+// sizedReg returns the part of the 64-bit register r which holds a value of the given size.
+func sizedReg(r string, size int) string {
+	switch size {
+	case 1:
+		return asmRegs[r][2]
+	case 2:
+		return asmRegs[r][1]
+	case 4:
+		return asmRegs[r][0]
+	case 8:
+		return r
+	}
+	log.Fatalf("Unhandled type size: %d", size)
+	return ""
+}
@@ -0,0 +1,466 @@
+package ast
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/joehattori/tgocc/types"
+	"github.com/joehattori/tgocc/vars"
+)
+
+// constVal is the value of a constant expression, sign- or zero-extended to 64 bits, with the size and the signedness of its type.
+type constVal struct {
+	val        int64
+	size       int
+	isUnsigned bool
+}
+
+var constInt = constVal{size: 4}
+
+// Eval evaluates the integer constant expression n, following C11 6.6.
+func Eval(n Node) int64 {
+	c, err := evalConst(n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return c.val
+}
+
+// TryEval evaluates n like Eval, but reports the failure as an error, e.g) for the length of a VLA.
+func TryEval(n Node) (int64, error) {
+	c, err := evalConst(n)
+	return c.val, err
+}
+
+// EvalAs evaluates the constant expression n converted to the type t, e.g) the initializer of a global variable.
+func EvalAs(n Node, t types.Type) int64 {
+	return Eval(NewCastNode(n, t))
+}
+
+func evalConst(n Node) (constVal, error) {
+	switch n := n.(type) {
+	case *NumNode:
+		return constOf(n.val, n.LoadType())
+	case *CastNode:
+		c, err := evalConst(n.base)
+		if err != nil {
+			return c, err
+		}
+		return castConst(n, c, n.toTy)
+	case *BinaryNode:
+		return evalBinary(n)
+	case *NotNode:
+		c, err := evalConst(n.body)
+		if err != nil {
+			return c, err
+		}
+		return boolConst(c.val == 0), nil
+	case *BitNotNode:
+		c, err := evalConst(n.body)
+		if err != nil {
+			return c, err
+		}
+		c = promote(c)
+		c.val = ^c.val
+		return c.normalize(), nil
+	case *TernaryNode:
+		cond, err := evalConst(n.cond)
+		if err != nil {
+			return cond, err
+		}
+		// only the selected operand is evaluated, but the result has the type common to both.
+		chosen := n.lhs
+		if cond.val == 0 {
+			chosen = n.rhs
+		}
+		c, err := evalConst(chosen)
+		if err != nil {
+			return c, err
+		}
+		if t, err := constOf(0, n.LoadType()); err == nil {
+			c = t.convert(c.val)
+		}
+		return c, nil
+	case *CommaNode:
+		return constVal{}, fmt.Errorf("Comma operator in constant expression: %s", describe(n))
+	}
+	return constVal{}, fmt.Errorf("Not a constant expression: %s", describe(n))
+}
+
+func evalBinary(n *BinaryNode) (constVal, error) {
+	switch n.op {
+	case NdLogAnd, NdLogOr:
+		// the right operand is evaluated only when the left does not decide the result.
+		l, err := evalConst(n.lhs)
+		if err != nil {
+			return l, err
+		}
+		if (l.val != 0) == (n.op == NdLogOr) {
+			return boolConst(l.val != 0), nil
+		}
+		r, err := evalConst(n.rhs)
+		if err != nil {
+			return r, err
+		}
+		return boolConst(r.val != 0), nil
+	case NdPtrDiff:
+		// the difference of addresses within the same object is a constant, e.g) &a[3] - &a[0].
+		l, lAddend, lok := EvalAddr(n.lhs)
+		r, rAddend, rok := EvalAddr(n.rhs)
+		if !lok || !rok || l != r {
+			return constVal{}, fmt.Errorf("Not a constant expression: %s", describe(n))
+		}
+		size := int64(n.lhs.LoadType().(types.Pointing).Base().Size())
+		return constVal{size: 8}.convert((lAddend - rAddend) / size), nil
+	}
+	l, err := evalConst(n.lhs)
+	if err != nil {
+		return l, err
+	}
+	r, err := evalConst(n.rhs)
+	if err != nil {
+		return r, err
+	}
+	switch n.op {
+	case NdShl, NdShr:
+		// the type of a shift is the promoted type of its left operand.
+		l = promote(l)
+		if r.val < 0 || (r.isUnsigned && uint64(r.val) >= 64) || r.val >= int64(8*l.size) {
+			return l, fmt.Errorf("Shift count out of range in constant expression: %s", describe(n))
+		}
+		if n.op == NdShr {
+			if l.isUnsigned {
+				l.val = int64(uint64(l.val) >> uint(r.val))
+			} else {
+				l.val >>= uint(r.val)
+			}
+			return l.normalize(), nil
+		}
+		if l.isUnsigned {
+			l.val <<= uint(r.val)
+			return l.normalize(), nil
+		}
+		// GCC accepts these shifts, e.g) 1 << 31 for flags, and only warns with -pedantic.
+		if l.val < 0 {
+			log.Printf("warning: left shift of negative value in constant expression: %s", describe(n))
+		} else if v := new(big.Int).Lsh(big.NewInt(l.val), uint(r.val)); !fits(l, v) {
+			log.Printf("warning: result of left shift overflows in constant expression: %s", describe(n))
+		}
+		l.val <<= uint(r.val)
+		return l.normalize(), nil
+	}
+
+	t := commonType(l, r)
+	l, r = t.convert(l.val), t.convert(r.val)
+	switch n.op {
+	case NdEq:
+		return boolConst(l.val == r.val), nil
+	case NdNeq:
+		return boolConst(l.val != r.val), nil
+	case NdLt, NdLeq, NdGt, NdGeq:
+		cmp := compare(l, r)
+		switch n.op {
+		case NdLt:
+			return boolConst(cmp < 0), nil
+		case NdLeq:
+			return boolConst(cmp <= 0), nil
+		case NdGt:
+			return boolConst(cmp > 0), nil
+		default:
+			return boolConst(cmp >= 0), nil
+		}
+	case NdBitOr:
+		return t.convert(l.val | r.val), nil
+	case NdBitXor:
+		return t.convert(l.val ^ r.val), nil
+	case NdBitAnd:
+		return t.convert(l.val & r.val), nil
+	case NdDiv, NdMod:
+		if r.val == 0 {
+			return t, fmt.Errorf("Division by zero in constant expression: %s", describe(n))
+		}
+	case NdAdd, NdSub, NdMul:
+	default:
+		return t, fmt.Errorf("Not a constant expression: %s", describe(n))
+	}
+
+	if t.isUnsigned {
+		// unsigned arithmetic wraps around.
+		a, b := uint64(l.val), uint64(r.val)
+		var v uint64
+		switch n.op {
+		case NdAdd:
+			v = a + b
+		case NdSub:
+			v = a - b
+		case NdMul:
+			v = a * b
+		case NdDiv:
+			v = a / b
+		case NdMod:
+			v = a % b
+		}
+		return t.convert(int64(v)), nil
+	}
+	a, b := big.NewInt(l.val), big.NewInt(r.val)
+	v := new(big.Int)
+	switch n.op {
+	case NdAdd:
+		v.Add(a, b)
+	case NdSub:
+		v.Sub(a, b)
+	case NdMul:
+		v.Mul(a, b)
+	case NdDiv:
+		// C truncates toward zero.
+		v.Quo(a, b)
+	case NdMod:
+		v.Rem(a, b)
+	}
+	return checkedConst(n, t, v)
+}
+
+// checkedConst returns v as a value of the signed type t, or an error when v overflows it.
+func checkedConst(n Node, t constVal, v *big.Int) (constVal, error) {
+	if !fits(t, v) {
+		return t, fmt.Errorf("Integer overflow in constant expression: %s", describe(n))
+	}
+	return t.convert(v.Int64()), nil
+}
+
+// fits reports whether the signed type t can represent v.
+func fits(t constVal, v *big.Int) bool {
+	bits := uint(8*t.size - 1)
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1))
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), bits))
+	return v.Cmp(max) <= 0 && v.Cmp(min) >= 0
+}
+
+// constOf returns val as a value of the type t.
+func constOf(val int64, t types.Type) (constVal, error) {
+	switch t := t.(type) {
+	case *types.Bool, *types.Char, *types.Short, *types.Int, *types.Long:
+		return intConst(val, t), nil
+	case *types.Enum:
+		return constOf(val, t.Base)
+	case *types.Ptr:
+		return constVal{size: 8, isUnsigned: true}.convert(val), nil
+	}
+	return constVal{}, fmt.Errorf("Not an arithmetic type in constant expression")
+}
+
+func castConst(n *CastNode, c constVal, t types.Type) (constVal, error) {
+	if _, ok := t.(*types.Bool); ok {
+		return constVal{size: 1, isUnsigned: true, val: boolConst(c.val != 0).val}, nil
+	}
+	to, err := constOf(0, t)
+	if err != nil {
+		return to, fmt.Errorf("Not a constant expression: %s", describe(n))
+	}
+	return to.convert(c.val), nil
+}
+
+// convert returns val converted to the type of c.
+func (c constVal) convert(val int64) constVal {
+	c.val = val
+	return c.normalize()
+}
+
+func (c constVal) normalize() constVal {
+	if c.size >= 8 {
+		return c
+	}
+	shift := uint(64 - 8*c.size)
+	if c.isUnsigned {
+		c.val = int64(uint64(c.val) << shift >> shift)
+	} else {
+		c.val = c.val << shift >> shift
+	}
+	return c
+}
+
+// intConst returns val as a value of the integer type t.
+func intConst(val int64, t types.Type) constVal {
+	return constVal{size: t.Size(), isUnsigned: types.IsUnsigned(t)}.convert(val)
+}
+
+// typ returns the integer type of c. A pointer is regarded as unsigned long.
+func (c constVal) typ() types.Type {
+	switch c.size {
+	case 1:
+		return &types.Char{IsUnsigned: c.isUnsigned}
+	case 2:
+		return &types.Short{IsUnsigned: c.isUnsigned}
+	case 4:
+		return &types.Int{IsUnsigned: c.isUnsigned}
+	}
+	return &types.Long{IsUnsigned: c.isUnsigned}
+}
+
+// promote applies the integer promotions to c.
+func promote(c constVal) constVal {
+	return intConst(c.val, types.Promote(c.typ()))
+}
+
+// commonType returns the type of the usual arithmetic conversions of l and r, holding the value zero.
+func commonType(l, r constVal) constVal {
+	return intConst(0, types.UsualArith(l.typ(), r.typ()))
+}
+
+func compare(l, r constVal) int {
+	if l.isUnsigned {
+		a, b := uint64(l.val), uint64(r.val)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	}
+	if l.val < r.val {
+		return -1
+	} else if l.val > r.val {
+		return 1
+	}
+	return 0
+}
+
+func boolConst(b bool) constVal {
+	if b {
+		return constInt.convert(1)
+	}
+	return constInt
+}
+
+// EvalAddr evaluates n as an address constant, which is the address of a global variable or a label plus the addend.
+func EvalAddr(n Node) (label string, addend int64, ok bool) {
+	switch n := n.(type) {
+	case *AddrNode:
+		return evalLvalueAddr(n.Var)
+	case *LabelAddrNode:
+		return labelName(n.fnName, n.label), 0, true
+	case *VarNode, *DerefNode, *MemberNode:
+		// arrays and functions decay to their addresses.
+		switch n.LoadType().(type) {
+		case *types.Arr, *types.Fn:
+			return evalLvalueAddr(n)
+		}
+	case *CastNode:
+		if n.toTy.Size() == 8 {
+			return EvalAddr(n.base)
+		}
+	case *TernaryNode:
+		cond, err := evalConst(n.cond)
+		if err != nil {
+			return "", 0, false
+		}
+		if cond.val != 0 {
+			return EvalAddr(n.lhs)
+		}
+		return EvalAddr(n.rhs)
+	case *BinaryNode:
+		switch n.op {
+		case NdPtrAdd, NdPtrSub:
+			if label, addend, ok = EvalAddr(n.lhs); !ok {
+				return
+			}
+			diff := Eval(n.rhs) * int64(n.LoadType().(types.Pointing).Base().Size())
+			if n.op == NdPtrSub {
+				diff = -diff
+			}
+			return label, addend + diff, true
+		}
+	}
+	return "", 0, false
+}
+
+// evalLvalueAddr evaluates the address of the lvalue n as an address constant.
+func evalLvalueAddr(n Node) (label string, addend int64, ok bool) {
+	switch n := n.(type) {
+	case *VarNode:
+		// the address of a thread-local variable differs among threads, which is not a constant.
+		if g, isGVar := n.Var.(*vars.GVar); isGVar && !g.IsTLS {
+			return g.Label(), 0, true
+		}
+	case *DerefNode:
+		return EvalAddr(n.ptr)
+	case *MemberNode:
+		if label, addend, ok = evalLvalueAddr(n.lhs); ok {
+			addend += int64(n.mem.Offset)
+		}
+		return
+	}
+	return "", 0, false
+}
+
+// describe renders the expression n in C syntax for diagnostics.
+func describe(n Node) string {
+	switch n := n.(type) {
+	case *NumNode:
+		return strconv.FormatInt(n.val, 10)
+	case *VarNode:
+		return n.Var.Name()
+	case *BinaryNode:
+		return fmt.Sprintf("%s %s %s", describeOperand(n.lhs), opStrings[n.op], describeOperand(n.rhs))
+	case *AssignNode:
+		return fmt.Sprintf("%s = %s", describeOperand(n.lhs), describeOperand(n.rhs))
+	case *CastNode:
+		return fmt.Sprintf("(%s)%s", types.String(n.toTy), describeOperand(n.base))
+	case *NotNode:
+		return "!" + describeOperand(n.body)
+	case *BitNotNode:
+		return "~" + describeOperand(n.body)
+	case *DerefNode:
+		return "*" + describeOperand(n.ptr)
+	case *AddrNode:
+		return "&" + describeOperand(n.Var)
+	case *MemberNode:
+		return describeOperand(n.lhs) + "." + n.mem.Name
+	case *IncNode:
+		if n.isPre {
+			return "++" + describeOperand(n.body)
+		}
+		return describeOperand(n.body) + "++"
+	case *DecNode:
+		if n.isPre {
+			return "--" + describeOperand(n.body)
+		}
+		return describeOperand(n.body) + "--"
+	case *TernaryNode:
+		return fmt.Sprintf("%s ? %s : %s", describeOperand(n.cond), describeOperand(n.lhs), describeOperand(n.rhs))
+	case *CommaNode:
+		return fmt.Sprintf("%s, %s", describe(n.lhs), describe(n.rhs))
+	case *FnCallNode:
+		var args []string
+		for _, arg := range n.params {
+			args = append(args, describe(arg))
+		}
+		return fmt.Sprintf("%s(%s)", describeOperand(n.fn), strings.Join(args, ", "))
+	case *StmtExprNode:
+		return "({ ... })"
+	}
+	return "expression"
+}
+
+// describeOperand renders n, parenthesized when it has an operator of its own.
+func describeOperand(n Node) string {
+	switch n.(type) {
+	case *BinaryNode, *AssignNode, *TernaryNode, *CommaNode:
+		return "(" + describe(n) + ")"
+	}
+	return describe(n)
+}
+
+var opStrings = map[nodeKind]string{
+	NdAdd: "+", NdSub: "-", NdMul: "*", NdDiv: "/", NdMod: "%",
+	NdEq: "==", NdNeq: "!=", NdLt: "<", NdLeq: "<=", NdGt: ">", NdGeq: ">=",
+	NdPtrAdd: "+", NdPtrSub: "-", NdPtrDiff: "-",
+	NdAddEq: "+=", NdSubEq: "-=", NdMulEq: "*=", NdDivEq: "/=", NdModEq: "%=",
+	NdPtrAddEq: "+=", NdPtrSubEq: "-=",
+	NdBitOr: "|", NdBitXor: "^", NdBitAnd: "&", NdLogOr: "||", NdLogAnd: "&&",
+	NdShl: "<<", NdShr: ">>", NdShlEq: "<<=", NdShrEq: ">>=",
+	NdBitOrEq: "|=", NdBitXorEq: "^=", NdBitAndEq: "&=",
+}
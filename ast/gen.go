@@ -19,6 +19,17 @@ var (
 	paramRegs8 = [...]string{"rdi", "rsi", "rdx", "rcx", "r8", "r9"}
 )
 
+func (a *AllocaNode) gen() {
+	a.size.gen()
+	fmt.Println("	pop rax")
+	fmt.Println("	add rax, 15")
+	fmt.Println("	and rax, -16")
+	fmt.Println("	neg rax")
+	fmt.Printf("	add rax, [rbp-%d]\n", a.bottom.Offset)
+	moveStackBottom(a.bottom)
+	fmt.Println("	push rax")
+}
+
 func (a *AddrNode) gen() {
 	a.Var.genAddr()
 }
@@ -26,23 +37,50 @@ func (a *AddrNode) gen() {
 func (a *AssignNode) gen() {
 	a.lhs.genAddr()
 	a.rhs.gen()
-	store(a.LoadType())
+	storeTo(a.lhs)
 }
 
 func (b *BinaryNode) gen() {
 	lhs, rhs := b.lhs, b.rhs
 	switch b.op {
-	case NdAddEq, NdSubEq, NdMulEq, NdDivEq, NdPtrAddEq, NdPtrSubEq, NdShlEq, NdShrEq:
+	case NdAddEq, NdSubEq, NdMulEq, NdDivEq, NdModEq, NdPtrAddEq, NdPtrSubEq,
+		NdBitOrEq, NdBitXorEq, NdBitAndEq, NdShlEq, NdShrEq:
+		if t := lhs.LoadType(); types.IsAtomic(t) {
+			lhs.(AddressableNode).genAddr()
+			rhs.gen()
+			genAtomicRMW(t, b.genOp)
+			// the value of the expression is the new one.
+			fmt.Println("	pop rax")
+			fmt.Println("	mov [rsp], rax")
+			return
+		}
+		// the address is computed only once and used for both load and store.
 		lhs.(AddressableNode).genAddr()
-		defer store(lhs.LoadType())
+		fmt.Println("	push [rsp]")
+		loadFrom(lhs.(AddressableNode))
+		defer storeTo(lhs.(AddressableNode))
+	default:
+		lhs.gen()
 	}
-
-	lhs.gen()
 	rhs.gen()
+	b.genOp()
+}
 
+// genOp pops the operands of b and pushes the result of the operation.
+func (b *BinaryNode) genOp() {
 	fmt.Println("	pop rdi")
 	fmt.Println("	pop rax")
 
+	t := b.opType()
+	isUnsigned := types.IsUnsigned(t)
+	switch b.op {
+	case NdShl, NdShlEq, NdShr, NdShrEq, NdPtrAdd, NdPtrAddEq, NdPtrSub, NdPtrSubEq, NdPtrDiff, NdLogOr, NdLogAnd:
+	default:
+		// the operands are converted to their common type.
+		convert("rax", t)
+		convert("rdi", t)
+	}
+
 	switch b.op {
 	case NdAdd, NdAddEq:
 		fmt.Println("	add rax, rdi")
@@ -50,9 +88,17 @@ func (b *BinaryNode) gen() {
 		fmt.Println("	sub rax, rdi")
 	case NdMul, NdMulEq:
 		fmt.Println("	imul rax, rdi")
-	case NdDiv, NdDivEq:
-		fmt.Println("	cqo")
-		fmt.Println("	idiv rdi")
+	case NdDiv, NdDivEq, NdMod, NdModEq:
+		if isUnsigned {
+			fmt.Println("	xor edx, edx")
+			fmt.Println("	div rdi")
+		} else {
+			fmt.Println("	cqo")
+			fmt.Println("	idiv rdi")
+		}
+		if b.op == NdMod || b.op == NdModEq {
+			fmt.Println("	mov rax, rdx")
+		}
 	case NdEq:
 		fmt.Println("	cmp rax, rdi")
 		fmt.Println("	sete al")
@@ -61,38 +107,34 @@ func (b *BinaryNode) gen() {
 		fmt.Println("	cmp rax, rdi")
 		fmt.Println("	setne al")
 		fmt.Println("	movzb rax, al")
-	case NdLt:
-		fmt.Println("	cmp rax, rdi")
-		fmt.Println("	setl al")
-		fmt.Println("	movzb rax, al")
-	case NdLeq:
-		fmt.Println("	cmp rax, rdi")
-		fmt.Println("	setle al")
-		fmt.Println("	movzb rax, al")
-	case NdGt:
-		fmt.Println("	cmp rdi, rax")
-		fmt.Println("	setl al")
-		fmt.Println("	movzb rax, al")
-	case NdGeq:
-		fmt.Println("	cmp rdi, rax")
-		fmt.Println("	setle al")
+	case NdLt, NdLeq, NdGt, NdGeq:
+		if b.op == NdGt || b.op == NdGeq {
+			fmt.Println("	cmp rdi, rax")
+		} else {
+			fmt.Println("	cmp rax, rdi")
+		}
+		set := map[bool]string{false: "setl", true: "setb"}[isUnsigned]
+		if b.op == NdLeq || b.op == NdGeq {
+			set += "e"
+		}
+		fmt.Printf("	%s al\n", set)
 		fmt.Println("	movzb rax, al")
 	case NdPtrAdd, NdPtrAddEq:
-		fmt.Printf("	imul rdi, %d\n", b.LoadType().(types.Pointing).Base().Size())
+		fmt.Printf("	imul rdi, %s\n", elemSize(b.LoadType()))
 		fmt.Printf("	add rax, rdi\n")
 	case NdPtrSub, NdPtrSubEq:
-		fmt.Printf("	imul rdi, %d\n", b.LoadType().(types.Pointing).Base().Size())
+		fmt.Printf("	imul rdi, %s\n", elemSize(b.LoadType()))
 		fmt.Printf("	sub rax, rdi\n")
 	case NdPtrDiff:
 		fmt.Println("	sub rax, rdi")
 		fmt.Println("	cqo")
-		fmt.Printf("	mov rdi, %d\n", b.lhs.LoadType().(types.Pointing).Base().Size())
+		fmt.Printf("	mov rdi, %s\n", elemSize(b.lhs.LoadType()))
 		fmt.Println("	idiv rdi")
-	case NdBitOr:
+	case NdBitOr, NdBitOrEq:
 		fmt.Println("	or rax, rdi")
-	case NdBitXor:
+	case NdBitXor, NdBitXorEq:
 		fmt.Println("	xor rax, rdi")
-	case NdBitAnd:
+	case NdBitAnd, NdBitAndEq:
 		fmt.Println("	and rax, rdi")
 	case NdLogOr:
 		c := labelCount
@@ -106,6 +148,7 @@ func (b *BinaryNode) gen() {
 		fmt.Printf(".L.true.%d:\n", c)
 		fmt.Println("	setne al")
 		fmt.Printf(".L.end.%d:\n", c)
+		fmt.Println("	movzb rax, al")
 	case NdLogAnd:
 		c := labelCount
 		labelCount++
@@ -118,23 +161,47 @@ func (b *BinaryNode) gen() {
 		fmt.Printf(".L.false.%d:\n", c)
 		fmt.Println("	setne al")
 		fmt.Printf(".L.end.%d:\n", c)
+		fmt.Println("	movzb rax, al")
 	case NdShl, NdShlEq:
 		fmt.Println("	mov cl, dil")
 		fmt.Println("	sal rax, cl")
 	case NdShr, NdShrEq:
 		fmt.Println("	mov cl, dil")
-		fmt.Println("	sar rax, cl")
+		if isUnsigned {
+			fmt.Println("	shr rax, cl")
+		} else {
+			fmt.Println("	sar rax, cl")
+		}
 	default:
 		log.Fatal("Unhandled node kind")
 	}
+	// the result wraps around in the type of the operation, e.g) 32 bits for int.
+	convert("rax", t)
 
 	fmt.Println("	push rax")
 }
 
+func (c *CompoundLitNode) gen() {
+	c.init.gen()
+	c.obj.gen()
+}
+
+func (c *CompoundLitNode) genAddr() {
+	c.init.gen()
+	c.obj.genAddr()
+}
+
+func (c *CommaNode) gen() {
+	c.lhs.gen()
+	fmt.Println("	add rsp, 8")
+	c.rhs.gen()
+}
+
 func (b *BitNotNode) gen() {
 	b.body.gen()
 	fmt.Println("	pop rax")
 	fmt.Println("	not rax")
+	convert("rax", b.LoadType())
 	fmt.Println("	push rax")
 }
 
@@ -160,23 +227,7 @@ func (c *CaseNode) gen() {
 func (c *CastNode) gen() {
 	c.base.gen()
 	fmt.Println("	pop rax")
-	t := c.toTy
-	if _, ok := t.(*types.Bool); ok {
-		fmt.Println("	cmp rax, 0")
-		fmt.Println("	setne al")
-	}
-	switch t.Size() {
-	case 1:
-		fmt.Println("	movsx rax, al")
-	case 2:
-		fmt.Println("	movsx rax, ax")
-	case 4:
-		fmt.Println("	movsxd rax, eax")
-	case 8:
-		// rax is 8 bits register
-	default:
-		log.Fatalf("Unhandled type size: %d", t)
-	}
+	convert("rax", c.toTy)
 	fmt.Println("	push rax")
 }
 
@@ -189,33 +240,48 @@ func (c *ContinueNode) gen() {
 
 func (d *DecNode) gen() {
 	body := d.body
-	t := body.LoadType()
-	var diff int
-	if p, ok := body.LoadType().(types.Pointing); ok {
-		diff = p.Base().Size()
-	} else {
-		diff = 1
+	diff := "1"
+	if _, ok := body.LoadType().(types.Pointing); ok {
+		diff = elemSize(body.LoadType())
+	}
+
+	if types.IsAtomic(body.LoadType()) {
+		body.genAddr()
+		fmt.Printf("	push %s\n", diff)
+		genAtomicRMW(body.LoadType(), func() {
+			fmt.Println("	pop rdi")
+			fmt.Println("	pop rax")
+			fmt.Println("	sub rax, rdi")
+			fmt.Println("	push rax")
+		})
+		genIncDecResult(d.isPre)
+		return
 	}
 
 	body.genAddr()
 	fmt.Println("	push [rsp]")
-	load(t)
+	loadFrom(body)
 	fmt.Println("	pop rax")
-	fmt.Printf("	sub rax, %d\n", diff)
-	fmt.Println("	push rax")
-	store(t)
-
 	if !d.isPre {
-		fmt.Println("	pop rax")
-		fmt.Printf("	add rax, %d\n", diff)
+		// keep the old value below the address as the value of the expression.
+		fmt.Println("	pop rdi")
 		fmt.Println("	push rax")
+		fmt.Println("	push rdi")
+	}
+	fmt.Printf("	sub rax, %s\n", diff)
+	fmt.Println("	push rax")
+	storeTo(body)
+	if !d.isPre {
+		fmt.Println("	add rsp, 8")
 	}
 }
 
 func (d *DerefNode) gen() {
 	d.ptr.gen()
-	ty := d.LoadType()
-	if _, ok := ty.(*types.Arr); !ok {
+	switch ty := d.LoadType().(type) {
+	case *types.Arr, *types.VLA, *types.Fn, *types.Struct, *types.Union:
+		// the address itself is the value.
+	default:
 		load(ty)
 	}
 }
@@ -269,41 +335,196 @@ func (f *ForNode) gen() {
 }
 
 func (f *FnCallNode) gen() {
+	// every argument is evaluated onto the stack. A struct or union argument is evaluated to its address.
 	for _, param := range f.params {
 		param.gen()
 	}
-	for i := len(f.params) - 1; i >= 0; i-- {
-		fmt.Printf("	pop %s\n", paramRegs8[i])
+	callee, isDirect := f.directCallee()
+	base := 0
+	if !isDirect {
+		// indirect call: the callee address is evaluated last.
+		f.fn.gen()
+		base = 8
+	}
+	// argAddr returns the address where the i-th evaluated argument lives, relative to r11.
+	argAddr := func(i int) int { return base + 8*(len(f.params)-1-i) }
+
+	retTy := f.FnTy.RetTy
+	retInMem := isComposite(retTy) && classify(retTy) == classMemory
+	gp := 0
+	if retInMem {
+		// rdi holds the address where the callee stores the return value.
+		gp++
+	}
+	// decide the location of each argument, either registers from regIdx[i] or the stack at stackOffset[i].
+	regIdx := make([]int, len(f.params))
+	stackOffset := make([]int, len(f.params))
+	stackSize := 0
+	for i, param := range f.params {
+		t := param.LoadType()
+		n := 1
+		if isComposite(t) {
+			n = eightbytes(t)
+		}
+		if classify(t) == classInteger && gp+n <= len(paramRegs8) {
+			regIdx[i] = gp
+			gp += n
+			continue
+		}
+		// arguments which do not fit in registers are passed on the stack in order, each aligned to 8 bytes.
+		regIdx[i] = -1
+		stackOffset[i] = stackSize
+		stackSize += types.AlignTo(t.Size(), 8)
+	}
+
+	// allocate the outgoing argument area aligned to 16 bytes, and remember the evaluation stack in it.
+	fmt.Println("	mov r11, rsp")
+	fmt.Printf("	sub rsp, %d\n", stackSize+8)
+	fmt.Println("	and rsp, -16")
+	fmt.Printf("	mov [rsp+%d], r11\n", stackSize)
+	for i, param := range f.params {
+		if regIdx[i] >= 0 {
+			continue
+		}
+		fmt.Printf("	mov rax, [r11+%d]\n", argAddr(i))
+		if t := param.LoadType(); isComposite(t) {
+			copyMem("rsp", stackOffset[i], "rax", 0, t.Size())
+		} else {
+			f.convertArg(i)
+			fmt.Printf("	mov [rsp+%d], rax\n", stackOffset[i])
+		}
+	}
+	for i, param := range f.params {
+		if regIdx[i] < 0 {
+			continue
+		}
+		t := param.LoadType()
+		if !isComposite(t) {
+			fmt.Printf("	mov rax, [r11+%d]\n", argAddr(i))
+			f.convertArg(i)
+			fmt.Printf("	mov %s, rax\n", paramRegs8[regIdx[i]])
+			continue
+		}
+		fmt.Printf("	mov rax, [r11+%d]\n", argAddr(i))
+		for j := 0; j < eightbytes(t); j++ {
+			loadEightbyte(paramRegs8[regIdx[i]+j], "rax", 8*j, t.Size()-8*j)
+		}
+	}
+	if retInMem {
+		fmt.Printf("	lea rdi, [rbp-%d]\n", f.RetBuf.Offset)
+	}
+	if !isDirect {
+		fmt.Println("	mov r10, [r11]")
+		callee = "r10"
 	}
-	// align rsp to 16 byte boundary
-	fmt.Println("	mov rax, rsp")
-	fmt.Println("	and rax, 15")
-	fmt.Printf("	jz .L.func.call.%d\n", labelCount)
-	fmt.Println("	mov rax, 0")
-	fmt.Printf("	call %s\n", f.name)
-	fmt.Printf("	jmp .L.func.end.%d\n", labelCount)
-	fmt.Printf(".L.func.call.%d:\n", labelCount)
-	fmt.Println("	sub rsp, 8")
 	fmt.Println("	mov rax, 0")
-	fmt.Printf("	call %s\n", f.name)
-	fmt.Println("	add rsp, 8")
-	fmt.Printf(".L.func.end.%d:\n", labelCount)
+	fmt.Printf("	call %s\n", callee)
+	// restore the evaluation stack and drop the evaluated arguments.
+	fmt.Printf("	mov rsp, [rsp+%d]\n", stackSize)
+	fmt.Printf("	add rsp, %d\n", base+8*len(f.params))
+
+	if isComposite(retTy) && !retInMem {
+		// the value returned in rax and rdx is stored to the buffer, whose address becomes the value of the call.
+		fmt.Printf("	lea r11, [rbp-%d]\n", f.RetBuf.Offset)
+		storeEightbyte("rax", "r11", 0, retTy.Size())
+		if retTy.Size() > 8 {
+			storeEightbyte("rdx", "r11", 8, retTy.Size()-8)
+		}
+		fmt.Println("	mov rax, r11")
+	} else {
+		// only the lower bits of a returned value narrower than 8 bytes are defined.
+		extend("rax", retTy)
+	}
 	fmt.Println("	push rax")
-	labelCount++
+}
+
+// convertArg converts the i-th argument in rax to the type of the corresponding parameter.
+// Arguments passed as variadic ones keep their values, which are already promoted.
+func (f *FnCallNode) convertArg(i int) {
+	if i < len(f.FnTy.Params) {
+		convert("rax", f.FnTy.Params[i])
+	}
+}
+
+// genAddr pushes the address of the returned struct or union, which allows member access such as f().a.
+func (f *FnCallNode) genAddr() {
+	if !isComposite(f.FnTy.RetTy) {
+		log.Fatal("Function call returning non-struct is not an lvalue")
+	}
+	f.gen()
+}
+
+// directCallee returns the function name when the callee is a function designator.
+func (f *FnCallNode) directCallee() (string, bool) {
+	v, ok := f.fn.(*VarNode)
+	if !ok {
+		return "", false
+	}
+	g, ok := v.Var.(*vars.GVar)
+	if !ok {
+		return "", false
+	}
+	if _, ok := g.Type().(*types.Fn); !ok {
+		return "", false
+	}
+	return g.Label(), true
 }
 
 func (f *FnNode) gen() {
 	name := f.name
-	if !f.isStatic {
-		fmt.Printf(".globl %s\n", name)
+	genBinding(name, f.Sym)
+	if f.Sym.Align > 0 {
+		fmt.Printf(".align %d\n", f.Sym.Align)
 	}
+	fmt.Printf(".type %s, @function\n", name)
 	fmt.Printf("%s:\n", name)
 	fmt.Println("	push rbp")
 	fmt.Println("	mov rbp, rsp")
 	fmt.Printf("	sub rsp, %d\n", f.StackSize)
-	for i, param := range f.Params {
+	if f.StackBottom != nil {
+		fmt.Printf("	mov [rbp-%d], rsp\n", f.StackBottom.Offset)
+	}
+	if f.RegSaveArea != nil {
+		// spill every argument register, since va_arg can not know which ones hold the unnamed arguments.
+		for i, r := range paramRegs8 {
+			fmt.Printf("	mov [rbp-%d], %s\n", f.RegSaveArea.Offset-8*i, r)
+		}
+		for i := 0; i < 8; i++ {
+			fmt.Printf("	movsd [rbp-%d], xmm%d\n", f.RegSaveArea.Offset-48-16*i, i)
+		}
+	}
+	gp := 0
+	if isComposite(f.RetTy) && classify(f.RetTy) == classMemory {
+		// keep the address where the return value is stored.
+		fmt.Printf("	mov [rbp-%d], rdi\n", f.RetPtr.Offset)
+		gp++
+	}
+	// arguments passed on the stack are above the return address and the saved rbp.
+	stackOffset := 16
+	for _, param := range f.Params {
+		t := param.Type()
+		if isComposite(t) {
+			fmt.Printf("	lea rax, [rbp-%d]\n", param.Offset)
+			if n := eightbytes(t); classify(t) == classInteger && gp+n <= len(paramRegs8) {
+				for j := 0; j < n; j++ {
+					storeEightbyte(paramRegs8[gp+j], "rax", 8*j, t.Size()-8*j)
+				}
+				gp += n
+			} else {
+				copyMem("rax", 0, "rbp", stackOffset, t.Size())
+				stackOffset += types.AlignTo(t.Size(), 8)
+			}
+			continue
+		}
+		if gp >= len(paramRegs8) {
+			// the parameter is passed on the stack.
+			fmt.Printf("	mov rax, [rbp+%d]\n", stackOffset)
+			fmt.Printf("	mov [rbp-%d], %s\n", param.Offset, raxOfSize(t.Size()))
+			stackOffset += 8
+			continue
+		}
 		var r [6]string
-		switch param.Type().Size() {
+		switch t.Size() {
 		case 1:
 			r = paramRegs1
 		case 2:
@@ -315,8 +536,10 @@ func (f *FnNode) gen() {
 		default:
 			log.Fatalf("Unhandled type size: %d", param.Type().Size())
 		}
-		fmt.Printf("	mov [rbp-%d], %s\n", param.Offset, r[i])
+		fmt.Printf("	mov [rbp-%d], %s\n", param.Offset, r[gp])
+		gp++
 	}
+	f.gpOffset, f.stackOffset = 8*gp, stackOffset
 	for _, node := range f.Body {
 		node.gen()
 	}
@@ -324,6 +547,17 @@ func (f *FnNode) gen() {
 	fmt.Println("	mov rsp, rbp")
 	fmt.Println("	pop rbp")
 	fmt.Println("	ret")
+	fmt.Printf(".size %s, .-%s\n", name, name)
+}
+
+func (g *GotoNode) gen() {
+	if g.dest == nil {
+		fmt.Printf("	jmp %s\n", labelName(g.fnName, g.label))
+		return
+	}
+	g.dest.gen()
+	fmt.Println("	pop rax")
+	fmt.Println("	jmp rax")
 }
 
 func (i *IfNode) gen() {
@@ -351,34 +585,49 @@ func (i *IfNode) gen() {
 
 func (i *IncNode) gen() {
 	body := i.body
-	t := body.LoadType()
-	var diff int
-	if p, ok := body.LoadType().(types.Pointing); ok {
-		diff = p.Base().Size()
-	} else {
-		diff = 1
+	diff := "1"
+	if _, ok := body.LoadType().(types.Pointing); ok {
+		diff = elemSize(body.LoadType())
+	}
+
+	if types.IsAtomic(body.LoadType()) {
+		body.genAddr()
+		fmt.Printf("	push %s\n", diff)
+		genAtomicRMW(body.LoadType(), func() {
+			fmt.Println("	pop rdi")
+			fmt.Println("	pop rax")
+			fmt.Println("	add rax, rdi")
+			fmt.Println("	push rax")
+		})
+		genIncDecResult(i.isPre)
+		return
 	}
 
 	body.genAddr()
 	fmt.Println("	push [rsp]")
-	load(t)
+	loadFrom(body)
 	fmt.Println("	pop rax")
-	fmt.Printf("	add rax, %d\n", diff)
-	fmt.Println("	push rax")
-	store(t)
-
 	if !i.isPre {
-		fmt.Println("	pop rax")
-		fmt.Printf("	sub rax, %d\n", diff)
+		// keep the old value below the address as the value of the expression.
+		fmt.Println("	pop rdi")
 		fmt.Println("	push rax")
+		fmt.Println("	push rdi")
+	}
+	fmt.Printf("	add rax, %s\n", diff)
+	fmt.Println("	push rax")
+	storeTo(body)
+	if !i.isPre {
+		fmt.Println("	add rsp, 8")
 	}
 }
 
 func (m *MemberNode) gen() {
 	m.genAddr()
-	ty := m.LoadType()
-	if _, ok := ty.(*types.Arr); !ok {
-		load(ty)
+	switch m.LoadType().(type) {
+	case *types.Arr, *types.Struct, *types.Union:
+		// the address itself is the value.
+	default:
+		loadFrom(m)
 	}
 }
 
@@ -387,13 +636,14 @@ func (n *NotNode) gen() {
 	fmt.Println("	pop rax")
 	fmt.Println("	cmp rax, 0")
 	fmt.Println("	sete al")
+	fmt.Println("	movzb rax, al")
 	fmt.Println("	push rax")
 }
 
 func (*NullNode) gen() {}
 
 func (n *NumNode) gen() {
-	if n.val > math.MaxInt32 {
+	if n.val < math.MinInt32 || n.val > math.MaxInt32 {
 		fmt.Printf("	movabs rax, %d\n", n.val)
 		fmt.Println("	push rax")
 	} else {
@@ -405,14 +655,57 @@ func (r *RetNode) gen() {
 	if r.rhs != nil {
 		r.rhs.gen()
 		fmt.Println("	pop rax")
+		if t := r.fn.RetTy; isComposite(t) {
+			genRetComposite(r.fn, t)
+		} else {
+			convert("rax", t)
+		}
 	}
-	fmt.Printf("	jmp .L.return.%s\n", r.fnName)
+	fmt.Printf("	jmp .L.return.%s\n", r.fn.name)
+}
+
+// genRetComposite returns the struct or union whose address is in rax.
+func genRetComposite(fn *FnNode, t types.Type) {
+	if classify(t) == classMemory {
+		fmt.Printf("	mov rdi, [rbp-%d]\n", fn.RetPtr.Offset)
+		copyMem("rdi", 0, "rax", 0, t.Size())
+		fmt.Println("	mov rax, rdi")
+		return
+	}
+	fmt.Println("	mov r11, rax")
+	if t.Size() > 8 {
+		loadEightbyte("rdx", "r11", 8, t.Size()-8)
+	}
+	loadEightbyte("rax", "r11", 0, t.Size())
+}
+
+func (r *RvalueNode) gen() {
+	r.genAddr()
+}
+
+func (r *RvalueNode) genAddr() {
+	fmt.Printf("	lea rax, [rbp-%d]\n", r.tmp.Offset)
+	fmt.Println("	push rax")
+	r.body.gen()
+	store(r.body.LoadType())
+}
+
+func (s *StackRestoreNode) gen() {
+	if s.SavedSP == nil {
+		return
+	}
+	fmt.Printf("	mov rax, [rbp-%d]\n", s.SavedSP.Offset)
+	moveStackBottom(s.bottom)
 }
 
 func (s *StmtExprNode) gen() {
 	for _, st := range s.body {
 		st.gen()
 	}
+	if s.Restore != nil {
+		// the value on the stack top is carried over.
+		s.Restore.gen()
+	}
 }
 
 func (s *SwitchNode) gen() {
@@ -450,21 +743,88 @@ func (t *TernaryNode) gen() {
 	fmt.Println("	pop rax")
 	fmt.Println("	cmp rax, 0")
 	fmt.Printf("	je .L.ternary.%d.rhs\n", c)
+	ty := t.LoadType()
 	t.lhs.gen()
+	convertTop(ty)
 	fmt.Printf("	jmp .L.ternary.%d.end\n", c)
 	fmt.Printf(".L.ternary.%d.rhs:\n", c)
 	t.rhs.gen()
+	convertTop(ty)
 	fmt.Printf(".L.ternary.%d.end:\n", c)
 }
 
 func (v *VarNode) gen() {
 	v.genAddr()
-	ty := v.LoadType()
-	if _, ok := ty.(*types.Arr); !ok {
+	switch ty := v.LoadType().(type) {
+	case *types.Arr, *types.VLA, *types.Fn, *types.Struct, *types.Union:
+		// the address itself is the value.
+	default:
 		load(ty)
 	}
 }
 
+func (v *VaArgNode) gen() {
+	c := labelCount
+	labelCount++
+	v.ap.gen()
+	fmt.Println("	pop rdi")
+	if n := eightbytes(v.ty); classify(v.ty) == classInteger {
+		// take the argument from the register save area while unread registers remain.
+		fmt.Println("	mov eax, dword ptr [rdi]")
+		fmt.Printf("	cmp eax, %d\n", 8*(len(paramRegs8)-n))
+		fmt.Printf("	ja .L.va_arg.%d.overflow\n", c)
+		fmt.Printf("	lea edx, [eax+%d]\n", 8*n)
+		fmt.Println("	mov dword ptr [rdi], edx")
+		fmt.Println("	add rax, [rdi+16]")
+		fmt.Printf("	jmp .L.va_arg.%d.end\n", c)
+	}
+	fmt.Printf(".L.va_arg.%d.overflow:\n", c)
+	fmt.Println("	mov rax, [rdi+8]")
+	fmt.Printf("	lea rdx, [rax+%d]\n", types.AlignTo(v.ty.Size(), 8))
+	fmt.Println("	mov [rdi+8], rdx")
+	fmt.Printf(".L.va_arg.%d.end:\n", c)
+	fmt.Println("	push rax")
+	if !isComposite(v.ty) {
+		load(v.ty)
+	}
+}
+
+// genAddr pushes the address of the struct or union argument, which allows member access such as va_arg(ap, struct s).a.
+func (v *VaArgNode) genAddr() {
+	if !isComposite(v.ty) {
+		log.Fatal("va_arg of non-struct is not an lvalue")
+	}
+	v.gen()
+}
+
+func (v *VaStartNode) gen() {
+	v.ap.gen()
+	fmt.Println("	pop rax")
+	fmt.Printf("	mov dword ptr [rax], %d\n", v.fn.gpOffset)
+	// the unnamed floating point arguments are read by callees such as vprintf from the xmm registers saved after the general purpose ones.
+	fmt.Printf("	mov dword ptr [rax+4], %d\n", 8*len(paramRegs8))
+	fmt.Printf("	lea rdx, [rbp+%d]\n", v.fn.stackOffset)
+	fmt.Println("	mov [rax+8], rdx")
+	fmt.Printf("	lea rdx, [rbp-%d]\n", v.fn.RegSaveArea.Offset)
+	fmt.Println("	mov [rax+16], rdx")
+	// va_start has no value, but every expression leaves one on the stack.
+	fmt.Println("	push 0")
+}
+
+func (v *VLAAllocNode) gen() {
+	v.size.gen()
+	fmt.Println("	pop rax")
+	// the size is rounded up so that the stack stays aligned to 16 bytes.
+	fmt.Println("	add rax, 15")
+	fmt.Println("	and rax, -16")
+	fmt.Printf("	mov rdi, [rbp-%d]\n", v.bottom.Offset)
+	fmt.Printf("	mov [rbp-%d], rdi\n", v.savedSP.Offset)
+	fmt.Println("	neg rax")
+	fmt.Println("	add rax, rdi")
+	moveStackBottom(v.bottom)
+	fmt.Printf("	mov [rbp-%d], rax\n", v.v.Offset)
+}
+
 func (w *WhileNode) gen() {
 	c := labelCount
 	labelCount++
@@ -485,6 +845,20 @@ func (d *DerefNode) genAddr() {
 	d.ptr.gen()
 }
 
+func (l *LabelAddrNode) gen() {
+	fmt.Printf("	push offset %s\n", labelName(l.fnName, l.label))
+}
+
+func (l *LabelNode) gen() {
+	fmt.Printf("%s:\n", labelName(l.fnName, l.label))
+	l.body.gen()
+}
+
+// labelName returns the assembly label of a user-defined label, which is unique across functions.
+func labelName(fnName string, label string) string {
+	return fmt.Sprintf(".L.label.%s.%s", fnName, label)
+}
+
 func (m *MemberNode) genAddr() {
 	m.lhs.genAddr()
 	fmt.Println("	pop rax")
@@ -495,8 +869,17 @@ func (m *MemberNode) genAddr() {
 func (v *VarNode) genAddr() {
 	switch v := v.Var.(type) {
 	case *vars.GVar:
-		fmt.Printf("	push offset %s\n", v.Name())
+		if v.IsTLS {
+			genTLSAddr(v)
+			return
+		}
+		fmt.Printf("	push offset %s\n", v.Label())
 	case *vars.LVar:
+		if _, ok := v.Type().(*types.VLA); ok {
+			// the frame slot holds the address of the storage.
+			fmt.Printf("	push [rbp-%d]\n", v.Offset)
+			return
+		}
 		fmt.Printf("	lea rax, [rbp-%d]\n", v.Offset)
 		fmt.Println("	push rax")
 	default:
@@ -504,15 +887,158 @@ func (v *VarNode) genAddr() {
 	}
 }
 
+// genTLSAddr pushes the address of the instance of the thread-local variable g in the current thread,
+// which is at an offset from the thread pointer held at fs:0.
+// The offset of a variable defined in this translation unit is fixed by the linker (the local-exec model),
+// while that of the others is read from the GOT (the initial-exec model), which the linker relaxes when possible.
+func genTLSAddr(g *vars.GVar) {
+	fmt.Println("	mov rax, qword ptr fs:0")
+	if g.Emit && !g.IsWeak {
+		fmt.Printf("	lea rax, [rax+%s@tpoff]\n", g.Label())
+	} else {
+		fmt.Printf("	add rax, qword ptr [rip+%s@gottpoff]\n", g.Label())
+	}
+	fmt.Println("	push rax")
+}
+
+// elemSize returns the operand holding the size of the element which a value of type t points to.
+// The size of a VLA is read from the variable it was computed into.
+func elemSize(t types.Type) string {
+	base := t.(types.Pointing).Base()
+	if v, ok := base.(*types.VLA); ok {
+		return fmt.Sprintf("qword ptr [rbp-%d]", v.SizeVar.(*vars.LVar).Offset)
+	}
+	return fmt.Sprint(base.Size())
+}
+
+// moveStackBottom moves the bottom of the stack area allocated by VLAs and alloca to the address in rax.
+// The temporaries of the expression being evaluated, which are pushed between rsp and the bottom, are moved along.
+// rax is preserved.
+func moveStackBottom(bottom *vars.LVar) {
+	c := labelCount
+	labelCount++
+	fmt.Printf("	mov rdx, [rbp-%d]\n", bottom.Offset)
+	fmt.Println("	sub rdx, rsp")
+	fmt.Printf("	mov [rbp-%d], rax\n", bottom.Offset)
+	fmt.Println("	mov rsi, rsp")
+	fmt.Println("	mov rdi, rax")
+	fmt.Println("	sub rdi, rdx")
+	fmt.Println("	mov rcx, rdx")
+	fmt.Println("	cmp rdi, rsi")
+	fmt.Printf("	ja .L.stack.%d.up\n", c)
+	// moving down: the area is allocated before the copy so that nothing below rsp is live.
+	fmt.Println("	mov rsp, rdi")
+	fmt.Println("	rep movsb")
+	fmt.Printf("	jmp .L.stack.%d.end\n", c)
+	// moving up: the areas may overlap, so copy backward and free the area afterwards.
+	fmt.Printf(".L.stack.%d.up:\n", c)
+	fmt.Println("	lea rsi, [rsi+rcx-1]")
+	fmt.Println("	lea rdi, [rdi+rcx-1]")
+	fmt.Println("	std")
+	fmt.Println("	rep movsb")
+	fmt.Println("	cld")
+	fmt.Println("	mov rsp, rax")
+	fmt.Println("	sub rsp, rdx")
+	fmt.Printf(".L.stack.%d.end:\n", c)
+}
+
+// raxOfSize returns the part of rax which holds a value of the given size.
+func raxOfSize(size int) string {
+	switch size {
+	case 1:
+		return "al"
+	case 2:
+		return "ax"
+	case 4:
+		return "eax"
+	case 8:
+		return "rax"
+	}
+	log.Fatalf("Unhandled type size: %d", size)
+	return ""
+}
+
+// subRegs holds the lower 32, 16 and 8 bits of the registers which convert and extend work on.
+var subRegs = map[string][3]string{
+	"rax": {"eax", "ax", "al"},
+	"rdi": {"edi", "di", "dil"},
+}
+
+// convert converts the value in r to the type t, as assignment, argument passing, return and cast do.
+// A value converted to _Bool is 1 unless it compares equal to 0, and one converted to a narrower integer type wraps around.
+// Other types than integers are left as they are.
+func convert(r string, t types.Type) {
+	if e, ok := t.(*types.Enum); ok {
+		t = e.Base
+	}
+	if _, ok := t.(*types.Bool); ok {
+		fmt.Printf("	cmp %s, 0\n", r)
+		fmt.Printf("	setne %s\n", subRegs[r][2])
+	}
+	extend(r, t)
+}
+
+// convertTop converts the value on the stack top to the type t.
+func convertTop(t types.Type) {
+	if !types.IsInteger(t) {
+		return
+	}
+	fmt.Println("	pop rax")
+	convert("rax", t)
+	fmt.Println("	push rax")
+}
+
+// extend sign- or zero-extends the lower bits of r which hold a value of the integer type t to 64 bits.
+func extend(r string, t types.Type) {
+	if !types.IsInteger(t) {
+		return
+	}
+	sub := subRegs[r]
+	isUnsigned := types.IsUnsigned(t)
+	switch t.Size() {
+	case 1:
+		if isUnsigned {
+			fmt.Printf("	movzx %s, %s\n", r, sub[2])
+		} else {
+			fmt.Printf("	movsx %s, %s\n", r, sub[2])
+		}
+	case 2:
+		if isUnsigned {
+			fmt.Printf("	movzx %s, %s\n", r, sub[1])
+		} else {
+			fmt.Printf("	movsx %s, %s\n", r, sub[1])
+		}
+	case 4:
+		if isUnsigned {
+			fmt.Printf("	mov %s, %s\n", sub[0], sub[0])
+		} else {
+			fmt.Printf("	movsxd %s, %s\n", r, sub[0])
+		}
+	}
+}
+
 func load(t types.Type) {
 	fmt.Println("	pop rax")
+	isUnsigned := types.IsUnsigned(t)
 	switch t.Size() {
 	case 1:
-		fmt.Println("	movsx rax, byte ptr [rax]")
+		if isUnsigned {
+			fmt.Println("	movzx rax, byte ptr [rax]")
+		} else {
+			fmt.Println("	movsx rax, byte ptr [rax]")
+		}
 	case 2:
-		fmt.Println("	movsx rax, word ptr [rax]")
+		if isUnsigned {
+			fmt.Println("	movzx rax, word ptr [rax]")
+		} else {
+			fmt.Println("	movsx rax, word ptr [rax]")
+		}
 	case 4:
-		fmt.Println("	movsxd rax, dword ptr [rax]")
+		if isUnsigned {
+			fmt.Println("	mov eax, dword ptr [rax]")
+		} else {
+			fmt.Println("	movsxd rax, dword ptr [rax]")
+		}
 	case 8:
 		fmt.Println("	mov rax, [rax]")
 	default:
@@ -524,11 +1050,13 @@ func load(t types.Type) {
 func store(t types.Type) {
 	fmt.Println("	pop rdi")
 	fmt.Println("	pop rax")
-	if _, ok := t.(*types.Bool); ok {
-		fmt.Println("	cmp rdi, 0")
-		fmt.Println("	setne dil")
-		fmt.Println("	movzb rdi, dil")
+	if isComposite(t) {
+		// struct and union are assigned by copying the memory.
+		copyMem("rax", 0, "rdi", 0, t.Size())
+		fmt.Println("	push rax")
+		return
 	}
+	convert("rdi", t)
 	var r string
 	switch t.Size() {
 	case 1:
@@ -542,6 +1070,84 @@ func store(t types.Type) {
 	default:
 		log.Fatalf("Unhandled type size: %d", t.Size())
 	}
+	if types.IsAtomic(t) {
+		// xchg with memory is locked implicitly, which makes the store sequentially consistent.
+		// The value in rdi is kept for the expression.
+		fmt.Println("	mov r10, rdi")
+		fmt.Printf("	xchg [rax], %s\n", r)
+		fmt.Println("	push r10")
+		return
+	}
 	fmt.Printf("	mov [rax], %s\n", r)
 	fmt.Println("	push rdi")
 }
+
+// loadFrom loads the value of n from the address on the stack top.
+// A bit-field is extracted from its storage unit.
+func loadFrom(n AddressableNode) {
+	t := n.LoadType()
+	load(t)
+	m, ok := n.(*MemberNode)
+	if !ok || !m.mem.IsBitField {
+		return
+	}
+	fmt.Println("	pop rax")
+	fmt.Printf("	shl rax, %d\n", 64-m.mem.BitWidth-m.mem.BitOffset)
+	if types.IsUnsigned(t) {
+		fmt.Printf("	shr rax, %d\n", 64-m.mem.BitWidth)
+	} else {
+		fmt.Printf("	sar rax, %d\n", 64-m.mem.BitWidth)
+	}
+	fmt.Println("	push rax")
+}
+
+// storeTo stores the value on the stack top to n, whose address is right below the value.
+// A bit-field is merged into its storage unit by read-modify-write.
+func storeTo(n AddressableNode) {
+	m, ok := n.(*MemberNode)
+	if !ok || !m.mem.IsBitField {
+		store(n.LoadType())
+		return
+	}
+	mem := m.mem
+	t := mem.Type
+	fmt.Println("	pop rdi")
+	fmt.Println("	pop rax")
+	convert("rdi", t)
+	mask := uint64(1)<<uint(mem.BitWidth) - 1
+	fmt.Println("	mov r8, rdi")
+	fmt.Printf("	movabs r9, %d\n", int64(mask))
+	fmt.Println("	and rdi, r9")
+	fmt.Printf("	shl rdi, %d\n", mem.BitOffset)
+
+	var r string
+	switch t.Size() {
+	case 1:
+		fmt.Println("	movzx r9, byte ptr [rax]")
+		r = "dil"
+	case 2:
+		fmt.Println("	movzx r9, word ptr [rax]")
+		r = "di"
+	case 4:
+		fmt.Println("	mov r9d, dword ptr [rax]")
+		r = "edi"
+	case 8:
+		fmt.Println("	mov r9, [rax]")
+		r = "rdi"
+	default:
+		log.Fatalf("Unhandled type size: %d", t.Size())
+	}
+	fmt.Printf("	movabs r10, %d\n", int64(^(mask << uint(mem.BitOffset))))
+	fmt.Println("	and r9, r10")
+	fmt.Println("	or rdi, r9")
+	fmt.Printf("	mov [rax], %s\n", r)
+
+	// the value of the expression is the one which the bit-field now holds.
+	fmt.Printf("	shl r8, %d\n", 64-mem.BitWidth)
+	if types.IsUnsigned(t) {
+		fmt.Printf("	shr r8, %d\n", 64-mem.BitWidth)
+	} else {
+		fmt.Printf("	sar r8, %d\n", 64-mem.BitWidth)
+	}
+	fmt.Println("	push r8")
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"log"
+	"math"
 
 	"github.com/joehattori/tgocc/types"
 	"github.com/joehattori/tgocc/vars"
@@ -19,6 +20,12 @@ type (
 		genAddr()
 	}
 
+	// AllocaNode allocates size bytes on the stack, which live until the function returns.
+	AllocaNode struct {
+		size   Node
+		bottom *vars.LVar
+	}
+
 	AddrNode struct {
 		Var AddressableNode
 		ty  types.Type
@@ -58,6 +65,18 @@ type (
 		toTy types.Type
 	}
 
+	// CompoundLitNode represents a compound literal, an unnamed object initialized where it appears.
+	CompoundLitNode struct {
+		init Node
+		obj  *VarNode
+	}
+
+	// CommaNode represents the comma operator, which evaluates lhs and then yields rhs.
+	CommaNode struct {
+		lhs Node
+		rhs Node
+	}
+
 	ContinueNode struct{}
 
 	DecNode struct {
@@ -87,19 +106,39 @@ type (
 	}
 
 	FnCallNode struct {
-		name   string
+		fn     Node
 		params []Node
-		retTy  types.Type
+		FnTy   *types.Fn
+		// RetBuf holds the returned struct or union.
+		RetBuf *vars.LVar
 	}
 
 	FnNode struct {
-		isStatic  bool
+		// Sym is the variable denoting the function, which holds its attributes.
+		Sym       *vars.GVar
 		Params    []*vars.LVar
 		Body      []Node
 		LVars     []*vars.LVar
 		name      string
 		StackSize int
 		RetTy     types.Type
+		// RetPtr holds the address where a struct or union returned in memory is stored.
+		RetPtr *vars.LVar
+		// RegSaveArea is where a variadic function spills the argument registers.
+		RegSaveArea *vars.LVar
+		// StackBottom holds the bottom of the stack area allocated by VLAs and alloca, below which the temporaries are pushed.
+		StackBottom *vars.LVar
+
+		// gpOffset and stackOffset are where the unnamed arguments of a variadic function begin.
+		gpOffset    int
+		stackOffset int
+	}
+
+	// GotoNode jumps to the label, or to the address computed by dest for GNU's `goto *ptr`.
+	GotoNode struct {
+		label  string
+		dest   Node
+		fnName string
 	}
 
 	IfNode struct {
@@ -113,6 +152,18 @@ type (
 		isPre bool
 	}
 
+	// LabelAddrNode represents GNU's `&&label`, the address of a label.
+	LabelAddrNode struct {
+		label  string
+		fnName string
+	}
+
+	LabelNode struct {
+		label  string
+		body   Node
+		fnName string
+	}
+
 	MemberNode struct {
 		lhs AddressableNode
 		mem *types.Member
@@ -126,17 +177,33 @@ type (
 
 	NumNode struct {
 		val int64
+		ty  types.Type
 	}
 
 	RetNode struct {
-		rhs    Node
-		fnName string
-		ty     types.Type
+		rhs Node
+		fn  *FnNode
+		ty  types.Type
+	}
+
+	// RvalueNode copies the struct or union value of body to tmp, so that its members can be accessed, e.g) (a = b).x.
+	RvalueNode struct {
+		body Node
+		tmp  *vars.LVar
+	}
+
+	// StackRestoreNode frees the stack area allocated since the bottom was saved to SavedSP.
+	// It does nothing while SavedSP is nil.
+	StackRestoreNode struct {
+		SavedSP *vars.LVar
+		bottom  *vars.LVar
 	}
 
 	StmtExprNode struct {
 		body []Node
 		ty   types.Type
+		// Restore cleans up the variables and frees the VLAs declared in the statement expression, after its value is computed.
+		Restore Node
 	}
 
 	SwitchNode struct {
@@ -155,6 +222,27 @@ type (
 		Var vars.Var
 	}
 
+	// VaArgNode reads the next variadic argument of type ty from the va_list ap.
+	VaArgNode struct {
+		ap Node
+		ty types.Type
+	}
+
+	// VaStartNode initializes the va_list ap with the unnamed arguments of fn.
+	VaStartNode struct {
+		ap Node
+		fn *FnNode
+	}
+
+	// VLAAllocNode allocates the storage of the VLA v, whose size in bytes is computed by size.
+	// The bottom of the stack area before the allocation is saved to savedSP.
+	VLAAllocNode struct {
+		v       *vars.LVar
+		size    Node
+		savedSP *vars.LVar
+		bottom  *vars.LVar
+	}
+
 	WhileNode struct {
 		cond Node
 		then Node
@@ -193,29 +281,43 @@ const (
 	NdShr
 	NdShlEq
 	NdShrEq
+	NdMod
+	NdModEq
+	NdBitOrEq
+	NdBitXorEq
+	NdBitAndEq
 )
 
 func NewAddNode(lhs Node, rhs Node) *BinaryNode {
 	l := lhs.LoadType()
 	r := rhs.LoadType()
 	switch l.(type) {
-	case *types.Char, *types.Int, *types.Short, *types.Long, *types.Bool:
+	case *types.Ptr, *types.Arr, *types.VLA:
+		if types.IsInteger(r) {
+			checkPtrArith(l)
+			return &BinaryNode{op: NdPtrAdd, lhs: lhs, rhs: rhs}
+		}
+	default:
+		if !types.IsInteger(l) {
+			break
+		}
 		switch r.(type) {
-		case *types.Char, *types.Int, *types.Short, *types.Long, *types.Bool:
-			return &BinaryNode{op: NdAdd, lhs: lhs, rhs: rhs}
-		case *types.Ptr, *types.Arr:
+		case *types.Ptr, *types.Arr, *types.VLA:
+			checkPtrArith(r)
 			return &BinaryNode{op: NdPtrAdd, lhs: rhs, rhs: lhs}
 		}
-	case *types.Ptr, *types.Arr:
-		switch r.(type) {
-		case *types.Char, *types.Int, *types.Short, *types.Long, *types.Bool:
-			return &BinaryNode{op: NdPtrAdd, lhs: lhs, rhs: rhs}
+		if types.IsInteger(r) {
+			return &BinaryNode{op: NdAdd, lhs: lhs, rhs: rhs}
 		}
 	}
 	log.Fatalf("Unexpected types.Typepe for addition: lhs: %T %T, rhs: %T %T", lhs, l, rhs, r)
 	return nil
 }
 
+func NewAllocaNode(size Node, bottom *vars.LVar) *AllocaNode {
+	return &AllocaNode{size, bottom}
+}
+
 func NewAddrNode(v AddressableNode) *AddrNode {
 	return &AddrNode{Var: v}
 }
@@ -225,6 +327,9 @@ func NewAssignNode(lhs AddressableNode, rhs Node) *AssignNode {
 }
 
 func NewBinaryNode(op nodeKind, lhs Node, rhs Node) *BinaryNode {
+	if op == NdPtrAddEq || op == NdPtrSubEq {
+		checkPtrArith(lhs.LoadType())
+	}
 	return &BinaryNode{op: op, lhs: lhs, rhs: rhs}
 }
 
@@ -244,6 +349,14 @@ func NewCaseNode(cmp int, body []Node, idx int) *CaseNode {
 	return &CaseNode{cmp, body, idx}
 }
 
+func NewCompoundLitNode(init Node, obj *VarNode) *CompoundLitNode {
+	return &CompoundLitNode{init, obj}
+}
+
+func NewCommaNode(lhs Node, rhs Node) *CommaNode {
+	return &CommaNode{lhs, rhs}
+}
+
 func NewCastNode(base Node, t types.Type) *CastNode {
 	return &CastNode{base, t}
 }
@@ -253,6 +366,7 @@ func NewContinueNode() *ContinueNode {
 }
 
 func NewDecNode(body AddressableNode, isPre bool) *DecNode {
+	checkPtrArith(body.LoadType())
 	return &DecNode{body, isPre}
 }
 
@@ -272,12 +386,31 @@ func NewForNode(init Node, cond Node, inc Node, body Node) *ForNode {
 	return &ForNode{init, cond, inc, body}
 }
 
-func NewFnCallNode(name string, params []Node, retTy types.Type) *FnCallNode {
-	return &FnCallNode{name, params, retTy}
+func NewFnCallNode(fn Node, params []Node) *FnCallNode {
+	var fnTy *types.Fn
+	switch t := fn.LoadType().(type) {
+	case *types.Fn:
+		fnTy = t
+	case *types.Ptr:
+		fnTy, _ = t.Base().(*types.Fn)
+	}
+	if fnTy == nil {
+		log.Fatalf("Called object is not a function: %T", fn.LoadType())
+	}
+	return &FnCallNode{fn: fn, params: params, FnTy: fnTy}
+}
+
+func NewFnNode(name string, t types.Type) *FnNode {
+	return &FnNode{name: name, RetTy: t}
 }
 
-func NewFnNode(isStatic bool, name string, t types.Type) *FnNode {
-	return &FnNode{isStatic: isStatic, name: name, RetTy: t}
+func NewGotoNode(label string, fnName string) *GotoNode {
+	return &GotoNode{label: label, fnName: fnName}
+}
+
+// NewIndirectGotoNode creates a node for `goto *dest`.
+func NewIndirectGotoNode(dest Node) *GotoNode {
+	return &GotoNode{dest: dest}
 }
 
 func NewIfNode(cond Node, then Node, els Node) *IfNode {
@@ -285,9 +418,18 @@ func NewIfNode(cond Node, then Node, els Node) *IfNode {
 }
 
 func NewIncNode(body AddressableNode, isPre bool) *IncNode {
+	checkPtrArith(body.LoadType())
 	return &IncNode{body, isPre}
 }
 
+func NewLabelAddrNode(label string, fnName string) *LabelAddrNode {
+	return &LabelAddrNode{label, fnName}
+}
+
+func NewLabelNode(label string, body Node, fnName string) *LabelNode {
+	return &LabelNode{label, body, fnName}
+}
+
 func NewMemberNode(lhs AddressableNode, m *types.Member) *MemberNode {
 	return &MemberNode{lhs, m}
 }
@@ -300,12 +442,29 @@ func NewNullNode() *NullNode {
 	return &NullNode{}
 }
 
+// NewNumNode creates an integer constant of type int, or long when int can not represent val.
 func NewNumNode(val int64) *NumNode {
-	return &NumNode{val}
+	if val < math.MinInt32 || val > math.MaxInt32 {
+		return &NumNode{val, types.NewLong()}
+	}
+	return &NumNode{val, types.NewInt()}
+}
+
+// NewTypedNumNode creates an integer constant of type t, e.g) the result of sizeof, which is unsigned long.
+func NewTypedNumNode(val int64, t types.Type) *NumNode {
+	return &NumNode{val, t}
+}
+
+func NewRetNode(rhs Node, fn *FnNode) *RetNode {
+	return &RetNode{rhs: rhs, fn: fn}
 }
 
-func NewRetNode(rhs Node, fnName string) *RetNode {
-	return &RetNode{rhs: rhs, fnName: fnName}
+func NewRvalueNode(body Node, tmp *vars.LVar) *RvalueNode {
+	return &RvalueNode{body, tmp}
+}
+
+func NewStackRestoreNode(savedSP *vars.LVar, bottom *vars.LVar) *StackRestoreNode {
+	return &StackRestoreNode{savedSP, bottom}
 }
 
 func NewStmtExprNode(body []Node) *StmtExprNode {
@@ -316,23 +475,32 @@ func NewSubNode(lhs Node, rhs Node) *BinaryNode {
 	l := lhs.LoadType()
 	r := rhs.LoadType()
 	switch l.(type) {
-	case *types.Char, *types.Int, *types.Long, *types.Short, *types.Bool:
+	case *types.Ptr, *types.Arr, *types.VLA:
 		switch r.(type) {
-		case *types.Char, *types.Int, *types.Long, *types.Short, *types.Bool:
-			return &BinaryNode{op: NdSub, lhs: lhs, rhs: rhs}
+		case *types.Ptr, *types.Arr, *types.VLA:
+			checkPtrArith(l)
+			return &BinaryNode{op: NdPtrDiff, lhs: lhs, rhs: rhs}
 		}
-	case *types.Ptr, *types.Arr:
-		switch r.(type) {
-		case *types.Char, *types.Int, *types.Long, *types.Short, *types.Bool:
+		if types.IsInteger(r) {
+			checkPtrArith(l)
 			return &BinaryNode{op: NdPtrSub, lhs: lhs, rhs: rhs}
-		case *types.Ptr, *types.Arr:
-			return &BinaryNode{op: NdPtrDiff, lhs: lhs, rhs: rhs}
+		}
+	default:
+		if types.IsInteger(l) && types.IsInteger(r) {
+			return &BinaryNode{op: NdSub, lhs: lhs, rhs: rhs}
 		}
 	}
 	log.Fatalf("Unexpected types.Typepe for subtraction: lhs: %T, rhs: %T", l, r)
 	return nil
 }
 
+// checkPtrArith aborts when t points to an incomplete type, whose size is unknown.
+func checkPtrArith(t types.Type) {
+	if p, ok := t.(types.Pointing); ok && types.IsIncomplete(p.Base()) {
+		log.Fatal("Arithmetic on pointer to incomplete type")
+	}
+}
+
 func NewSwitchNode(target Node, cases []*CaseNode, dflt *CaseNode) *SwitchNode {
 	return &SwitchNode{target, cases, dflt}
 }
@@ -345,15 +513,34 @@ func NewVarNode(v vars.Var) *VarNode {
 	return &VarNode{v}
 }
 
+func NewVaArgNode(ap Node, ty types.Type) *VaArgNode {
+	return &VaArgNode{ap, ty}
+}
+
+func NewVaStartNode(ap Node, fn *FnNode) *VaStartNode {
+	return &VaStartNode{ap, fn}
+}
+
+func NewVLAAllocNode(v *vars.LVar, size Node, savedSP *vars.LVar, bottom *vars.LVar) *VLAAllocNode {
+	return &VLAAllocNode{v, size, savedSP, bottom}
+}
+
 func NewWhileNode(cond Node, then Node) *WhileNode {
 	return &WhileNode{cond: cond, then: then}
 }
 
+func (a *AllocaNode) LoadType() types.Type {
+	a.size.LoadType()
+	return types.NewPtr(types.NewVoid())
+}
+
 func (a *AddrNode) LoadType() types.Type {
-	t := a.Var.LoadType()
-	if arrT, ok := t.(*types.Arr); ok {
-		a.ty = types.NewPtr(arrT.Base())
-	} else {
+	switch t := a.Var.LoadType().(type) {
+	case *types.Arr:
+		a.ty = types.NewPtr(t.Base())
+	case *types.VLA:
+		a.ty = types.NewPtr(t.Base())
+	default:
 		a.ty = types.NewPtr(t)
 	}
 	return a.ty
@@ -369,14 +556,58 @@ func (a *AssignNode) LoadType() types.Type {
 
 func (a *BinaryNode) LoadType() types.Type {
 	if a.ty == nil {
-		a.ty = a.lhs.LoadType()
+		l := a.lhs.LoadType()
+		switch a.op {
+		case NdAdd, NdSub, NdMul, NdDiv, NdMod, NdBitOr, NdBitXor, NdBitAnd:
+			a.ty = types.UsualArith(promotedType(a.lhs), promotedType(a.rhs))
+		case NdShl, NdShr:
+			a.ty = promotedType(a.lhs)
+		case NdEq, NdNeq, NdLt, NdLeq, NdGt, NdGeq, NdLogOr, NdLogAnd:
+			a.ty = types.NewInt()
+		case NdPtrDiff:
+			a.ty = types.NewLong()
+		default:
+			// pointer arithmetic and compound assignments have the type of the left operand.
+			a.ty = l
+		}
 	}
 	a.rhs.LoadType()
 	return a.ty
 }
 
+// opType returns the type in which the operation is performed.
+// It differs from the type of the result for comparisons and compound assignments.
+func (a *BinaryNode) opType() types.Type {
+	switch a.op {
+	case NdShlEq, NdShrEq:
+		return promotedType(a.lhs)
+	case NdAddEq, NdSubEq, NdMulEq, NdDivEq, NdModEq, NdBitOrEq, NdBitXorEq, NdBitAndEq,
+		NdEq, NdNeq, NdLt, NdLeq, NdGt, NdGeq:
+		return types.UsualArith(promotedType(a.lhs), promotedType(a.rhs))
+	}
+	return a.LoadType()
+}
+
+// promotedType returns the type of n after the integer promotions, which depends on the width of a bit-field.
+func promotedType(n Node) types.Type {
+	t := n.LoadType()
+	if m, ok := n.(*MemberNode); ok && m.mem.IsBitField {
+		return types.PromoteBitField(t, m.mem.BitWidth)
+	}
+	return types.Promote(t)
+}
+
+func (c *CompoundLitNode) LoadType() types.Type {
+	return c.obj.LoadType()
+}
+
+func (c *CommaNode) LoadType() types.Type {
+	c.lhs.LoadType()
+	return c.rhs.LoadType()
+}
+
 func (b *BitNotNode) LoadType() types.Type {
-	return b.body.LoadType()
+	return promotedType(b.body)
 }
 
 func (b *BlkNode) LoadType() types.Type {
@@ -414,6 +645,11 @@ func (d *DerefNode) LoadType() types.Type {
 		d.ty = v.Base()
 	case *types.Arr:
 		d.ty = v.Base()
+	case *types.VLA:
+		d.ty = v.Base()
+	case *types.Fn:
+		// dereferencing a function designator yields the function itself.
+		d.ty = v
 	default:
 		log.Fatalf("Cannot dereference types.Typepe %T", d.ptr.LoadType())
 	}
@@ -446,10 +682,11 @@ func (f *ForNode) LoadType() types.Type {
 }
 
 func (f *FnCallNode) LoadType() types.Type {
+	f.fn.LoadType()
 	for _, param := range f.params {
 		param.LoadType()
 	}
-	return f.retTy
+	return f.FnTy.RetTy
 }
 
 func (f *FnNode) LoadType() types.Type {
@@ -459,6 +696,13 @@ func (f *FnNode) LoadType() types.Type {
 	return f.RetTy
 }
 
+func (g *GotoNode) LoadType() types.Type {
+	if g.dest != nil {
+		g.dest.LoadType()
+	}
+	return types.NewEmpty()
+}
+
 func (i *IfNode) LoadType() types.Type {
 	i.cond.LoadType()
 	i.then.LoadType()
@@ -472,12 +716,24 @@ func (i *IncNode) LoadType() types.Type {
 	return i.body.LoadType()
 }
 
+func (l *LabelAddrNode) LoadType() types.Type {
+	return types.NewPtr(types.NewVoid())
+}
+
+func (l *LabelNode) LoadType() types.Type {
+	l.body.LoadType()
+	return types.NewEmpty()
+}
+
 func (m *MemberNode) LoadType() types.Type {
-	return m.mem.Type
+	// a member of a qualified struct or union is qualified as well.
+	q := types.QualsOf(m.lhs.LoadType())
+	q.IsRestrict = false
+	return types.Qualify(m.mem.Type, q)
 }
 
 func (n *NotNode) LoadType() types.Type {
-	return types.NewBool()
+	return types.NewInt()
 }
 
 func (*NullNode) LoadType() types.Type {
@@ -485,7 +741,7 @@ func (*NullNode) LoadType() types.Type {
 }
 
 func (n *NumNode) LoadType() types.Type {
-	return types.NewLong()
+	return n.ty
 }
 
 func (r *RetNode) LoadType() types.Type {
@@ -498,6 +754,14 @@ func (r *RetNode) LoadType() types.Type {
 	return r.ty
 }
 
+func (r *RvalueNode) LoadType() types.Type {
+	return r.body.LoadType()
+}
+
+func (*StackRestoreNode) LoadType() types.Type {
+	return types.NewEmpty()
+}
+
 func (s *StmtExprNode) LoadType() types.Type {
 	if s.ty == nil {
 		s.ty = s.body[len(s.body)-1].LoadType()
@@ -510,91 +774,44 @@ func (s *SwitchNode) LoadType() types.Type {
 }
 
 func (t *TernaryNode) LoadType() types.Type {
-	return t.lhs.LoadType()
+	l, r := t.lhs.LoadType(), t.rhs.LoadType()
+	if types.IsInteger(l) && types.IsInteger(r) {
+		return types.UsualArith(promotedType(t.lhs), promotedType(t.rhs))
+	}
+	return l
 }
 
 func (v *VarNode) LoadType() types.Type {
 	return v.Var.Type()
 }
 
+func (v *VaArgNode) LoadType() types.Type {
+	return v.ty
+}
+
+func (*VaStartNode) LoadType() types.Type {
+	return types.NewVoid()
+}
+
+func (v *VLAAllocNode) LoadType() types.Type {
+	v.size.LoadType()
+	return types.NewEmpty()
+}
+
 func (w *WhileNode) LoadType() types.Type {
 	w.cond.LoadType()
 	w.then.LoadType()
 	return types.NewEmpty()
 }
 
-func Eval(n Node) int64 {
+// IsRvalue reports whether n designates a temporary holding a struct or union value, such as f(), f().a and va_arg(ap, struct s),
+// which is addressable but can not be modified.
+func IsRvalue(n Node) bool {
 	switch n := n.(type) {
-	case *BinaryNode:
-		switch n.op {
-		case NdAdd:
-			return Eval(n.lhs) + Eval(n.rhs)
-		case NdSub:
-			return Eval(n.lhs) - Eval(n.rhs)
-		case NdMul:
-			return Eval(n.lhs) * Eval(n.rhs)
-		case NdDiv:
-			return Eval(n.lhs) / Eval(n.rhs)
-		case NdBitOr:
-			return Eval(n.lhs) | Eval(n.rhs)
-		case NdBitXor:
-			return Eval(n.lhs) ^ Eval(n.rhs)
-		case NdBitAnd:
-			return Eval(n.lhs) & Eval(n.rhs)
-		case NdShl:
-			return Eval(n.lhs) << Eval(n.rhs)
-		case NdShr:
-			return Eval(n.lhs) >> Eval(n.rhs)
-		case NdEq:
-			if Eval(n.lhs) == Eval(n.rhs) {
-				return 1
-			}
-			return 0
-		case NdNeq:
-			if Eval(n.lhs) != Eval(n.rhs) {
-				return 1
-			}
-			return 0
-		case NdLt:
-			if Eval(n.lhs) < Eval(n.rhs) {
-				return 1
-			}
-			return 0
-		case NdLeq:
-			if Eval(n.lhs) <= Eval(n.rhs) {
-				return 1
-			}
-			return 0
-		case NdGt:
-			if Eval(n.lhs) > Eval(n.rhs) {
-				return 1
-			}
-			return 0
-		case NdGeq:
-			if Eval(n.lhs) <= Eval(n.rhs) {
-				return 1
-			}
-			return 0
-		case NdLogAnd:
-			return Eval(n.lhs) & Eval(n.rhs)
-		case NdLogOr:
-			return Eval(n.lhs) | Eval(n.rhs)
-		}
-	case *BitNotNode:
-		return ^Eval(n.body)
-	case *NotNode:
-		if Eval(n.body) != 0 {
-			return 1
-		}
-		return 0
-	case *NumNode:
-		return n.val
-	case *TernaryNode:
-		if Eval(n.cond) == 0 {
-			return Eval(n.rhs)
-		}
-		return Eval(n.lhs)
+	case *FnCallNode, *RvalueNode, *VaArgNode:
+		return true
+	case *MemberNode:
+		return IsRvalue(n.lhs)
 	}
-	log.Fatalf("Not a constant expression.")
-	return 0
+	return false
 }
@@ -3,19 +3,46 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/joehattori/tgocc/parser"
 	"github.com/joehattori/tgocc/tokenizer"
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "usage: ./tccgo <filename>")
+	var path string
+	var dumpRecordLayouts, werrorImplicitFnDecl, warnUnused, functionSections, dataSections bool
+	for _, arg := range os.Args[1:] {
+		switch {
+		case arg == "-fdump-record-layouts":
+			dumpRecordLayouts = true
+		case arg == "-Werror=implicit-function-declaration":
+			werrorImplicitFnDecl = true
+		case arg == "-Wunused":
+			warnUnused = true
+		case arg == "-ffunction-sections":
+			functionSections = true
+		case arg == "-fdata-sections":
+			dataSections = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(os.Stderr, "unknown option: %s\n", arg)
+			os.Exit(1)
+		default:
+			path = arg
+		}
+	}
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "usage: ./tccgo [-fdump-record-layouts] [-Werror=implicit-function-declaration] [-Wunused] [-ffunction-sections] [-fdata-sections] <filename>")
 		return
 	}
-	t := tokenizer.NewTokenizer(os.Args[1], true)
+	t := tokenizer.NewTokenizer(path, true)
 	toks := t.Tokenize()
 	parser := parser.NewParser(toks)
+	parser.DumpRecordLayouts = dumpRecordLayouts
+	parser.WerrorImplicitFnDecl = werrorImplicitFnDecl
+	parser.WarnUnused = warnUnused
 	parser.Parse()
+	parser.Ast.FunctionSections = functionSections
+	parser.Ast.DataSections = dataSections
 	parser.Ast.Gen()
 }
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"log"
+
+	"github.com/joehattori/tgocc/ast"
+	"github.com/joehattori/tgocc/tokenizer"
+	"github.com/joehattori/tgocc/types"
+)
+
+// consumeAsm reads the asm keyword, which GNU C also spells __asm__ and __asm.
+func (p *Parser) consumeAsm() bool {
+	return p.consume("asm") || p.consume("__asm__") || p.consume("__asm")
+}
+
+// asmStmt reads the rest of an asm statement after the keyword, e.g) `volatile("rdtsc" : "=a"(lo), "=d"(hi));`.
+// Without the colon, it is basic asm.
+func (p *Parser) asmStmt() *ast.AsmNode {
+	// the asm is never removed nor duplicated, so the qualifiers need nothing.
+	for p.consumeAsmQualifier() {
+	}
+	if p.consume("goto") {
+		log.Fatal("asm goto is not supported")
+	}
+	p.expect("(")
+	template := p.expectStr().Str()
+	if p.consume(")") {
+		p.expect(";")
+		return ast.NewBasicAsmNode(template)
+	}
+	p.expect(":")
+	var outputs, inputs []*ast.AsmOperand
+	var clobbers []string
+	if !p.beginsWith(":") && !p.beginsWith(")") {
+		outputs = p.asmOperands(true)
+	}
+	if p.consume(":") && !p.beginsWith(":") && !p.beginsWith(")") {
+		inputs = p.asmOperands(false)
+	}
+	if p.consume(":") && !p.beginsWith(")") {
+		for {
+			clobbers = append(clobbers, p.expectStr().Str())
+			if !p.consume(",") {
+				break
+			}
+		}
+	}
+	p.expect(")")
+	p.expect(";")
+	return ast.NewAsmNode(template, outputs, inputs, clobbers)
+}
+
+// topLevelAsm reads basic asm at file scope, which can not have operands.
+func (p *Parser) topLevelAsm() *ast.AsmNode {
+	a := p.asmStmt()
+	if !a.IsBasic() {
+		log.Fatal("Extended asm is not allowed outside function")
+	}
+	return a
+}
+
+// consumeAsmQualifier reads a qualifier of asm such as volatile, where only volatile is a keyword of the tokenizer.
+func (p *Parser) consumeAsmQualifier() bool {
+	if p.consume("volatile") {
+		return true
+	}
+	if tok, ok := p.Toks[0].(*tokenizer.IDTok); ok {
+		switch tok.Str() {
+		case "__volatile__", "__volatile", "inline", "__inline__", "__inline":
+			p.popToks()
+			return true
+		}
+	}
+	return false
+}
+
+// asmOperands reads the comma separated operands, e.g) `[lo] "=a"(lo), "=d"(hi)`.
+func (p *Parser) asmOperands(isOutput bool) (operands []*ast.AsmOperand) {
+	for {
+		var name string
+		if p.consume("[") {
+			name = p.expectID().Str()
+			p.expect("]")
+		}
+		constraint := p.expectStr().Str()
+		p.expect("(")
+		expr := p.expr()
+		p.expect(")")
+		if isOutput {
+			// an output operand is written like the left-hand side of an assignment.
+			expr = modifiableLvalue(expr)
+		}
+		t := expr.LoadType()
+		if !isOutput {
+			t = lvalueConvert(t)
+		}
+		operands = append(operands, ast.NewAsmOperand(name, constraint, expr, types.Unqualified(t), isOutput))
+		if !p.consume(",") {
+			return
+		}
+	}
+}
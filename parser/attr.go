@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"log"
+	"strings"
+
+	"github.com/joehattori/tgocc/tokenizer"
+	"github.com/joehattori/tgocc/types"
+	"github.com/joehattori/tgocc/vars"
+)
+
+// biggestAlignment is the alignment specified by aligned without argument.
+const biggestAlignment = 16
+
+// declAttrs holds the attributes given to a declaration by GNU's __attribute__ and _Alignas.
+type declAttrs struct {
+	// align is the alignment specified by _Alignas or aligned, or 0.
+	// alignas is the one specified by _Alignas alone.
+	align, alignas int
+	packed         bool
+	// section, alias and visibility are empty when not specified.
+	section, alias, visibility   string
+	weak, noreturn, unused, used bool
+	// ctor and dtor are set by constructor and destructor, whose priorities are 0 when omitted.
+	ctor, dtor                 bool
+	ctorPriority, dtorPriority int
+	// cleanup is the function called with the address of the variable when it goes out of scope, or empty.
+	cleanup string
+	format  *formatAttr
+}
+
+// formatAttr is the format attribute of a function, e.g) format(printf, 1, 2). firstArg is 0 for a function taking va_list.
+type formatAttr struct {
+	archetype string
+	fmtIdx    int
+	firstArg  int
+}
+
+// merge returns the attributes of a and b, where b takes precedence over a.
+func (a *declAttrs) merge(b *declAttrs) *declAttrs {
+	c := *a
+	if b.align > c.align {
+		c.align = b.align
+	}
+	c.packed = c.packed || b.packed
+	if b.section != "" {
+		c.section = b.section
+	}
+	if b.alias != "" {
+		c.alias = b.alias
+	}
+	if b.visibility != "" {
+		c.visibility = b.visibility
+	}
+	c.weak = c.weak || b.weak
+	c.noreturn = c.noreturn || b.noreturn
+	c.unused = c.unused || b.unused
+	c.used = c.used || b.used
+	if b.ctor {
+		c.ctor, c.ctorPriority = true, b.ctorPriority
+	}
+	if b.dtor {
+		c.dtor, c.dtorPriority = true, b.dtorPriority
+	}
+	if b.cleanup != "" {
+		c.cleanup = b.cleanup
+	}
+	if b.format != nil {
+		c.format = b.format
+	}
+	return &c
+}
+
+// attributes reads `__attribute__((attr, ...))` repeatedly into a.
+func (p *Parser) attributes(a *declAttrs) {
+	for p.consume("__attribute__") || p.consume("__attribute") {
+		p.expect("(")
+		p.expect("(")
+		for !p.consume(")") {
+			// empty attributes are allowed, e.g) __attribute__((, packed))
+			if !p.consume(",") {
+				p.attribute(a)
+			}
+		}
+		p.expect(")")
+	}
+}
+
+// isAttrNullStmt reports whether the next tokens are a null statement with attributes, e.g) `__attribute__((fallthrough));`.
+func (p *Parser) isAttrNullStmt() bool {
+	orig := p.Toks
+	defer func() { p.Toks = orig }()
+	found := false
+	for p.consume("__attribute__") || p.consume("__attribute") {
+		p.expect("(")
+		p.skipParen()
+		found = true
+	}
+	return found && p.consume(";")
+}
+
+// attribute reads an attribute, e.g) `aligned(8)`. An unknown attribute is ignored with a warning.
+func (p *Parser) attribute(a *declAttrs) {
+	name := p.Toks[0].Str()
+	if _, ok := p.Toks[0].(*tokenizer.StrTok); ok || p.isEOF() || name == "(" || name == ")" {
+		log.Fatalf("Attribute name was expected but got %s", name)
+	}
+	p.popToks()
+	if len(name) > 4 && strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__") {
+		name = name[2 : len(name)-2]
+	}
+	switch name {
+	case "aligned":
+		align := biggestAlignment
+		if p.consume("(") {
+			align = int(p.constExpr())
+			p.expect(")")
+			checkAlignment(align)
+		}
+		if a.align < align {
+			a.align = align
+		}
+	case "packed":
+		a.packed = true
+	case "section":
+		a.section = p.strArg()
+	case "weak":
+		a.weak = true
+	case "alias":
+		a.alias = p.strArg()
+	case "visibility":
+		switch v := p.strArg(); v {
+		case "default", "hidden", "protected", "internal":
+			a.visibility = v
+		default:
+			log.Fatalf("Visibility %s is not one of default, hidden, protected or internal", v)
+		}
+	case "noreturn":
+		a.noreturn = true
+	case "unused":
+		a.unused = true
+	case "used":
+		a.used = true
+	case "constructor":
+		a.ctor, a.ctorPriority = true, p.priorityArg()
+	case "destructor":
+		a.dtor, a.dtorPriority = true, p.priorityArg()
+	case "cleanup":
+		p.expect("(")
+		a.cleanup = p.expectID().Str()
+		p.expect(")")
+	case "format":
+		p.expect("(")
+		archetype := strings.Trim(p.expectID().Str(), "_")
+		p.expect(",")
+		fmtIdx := int(p.constExpr())
+		p.expect(",")
+		firstArg := int(p.constExpr())
+		p.expect(")")
+		switch archetype {
+		case "printf", "gnu_printf":
+			a.format = &formatAttr{"printf", fmtIdx, firstArg}
+		case "scanf", "gnu_scanf":
+			a.format = &formatAttr{"scanf", fmtIdx, firstArg}
+		default:
+			log.Printf("warning: %s is an unrecognized format function type", archetype)
+		}
+	case "fallthrough":
+		// it only marks a null statement at the end of a switch case, which needs nothing.
+	default:
+		log.Printf("warning: '%s' attribute directive ignored", name)
+		if p.consume("(") {
+			p.skipParen()
+		}
+	}
+}
+
+// strArg reads the argument of an attribute which is a string literal, e.g) `("name")`.
+func (p *Parser) strArg() string {
+	p.expect("(")
+	s := p.expectStr().Str()
+	p.expect(")")
+	return s
+}
+
+// priorityArg reads the optional priority of constructor and destructor.
+func (p *Parser) priorityArg() int {
+	if !p.consume("(") {
+		return 0
+	}
+	priority := int(p.constExpr())
+	p.expect(")")
+	if priority < 0 || priority > 65535 {
+		log.Fatalf("Priority %d is out of range from 0 to 65535", priority)
+	}
+	if priority <= 100 {
+		log.Printf("warning: priorities from 0 to 100 are reserved for the implementation")
+	}
+	return priority
+}
+
+// checkAlignAs checks that _Alignas does not reduce the alignment of the variable or member id of type t (C11 6.7.5p4).
+func checkAlignAs(id string, t types.Type, a *declAttrs) {
+	if a.alignas > 0 && a.alignas < t.Alignment() {
+		log.Fatalf("_Alignas can not reduce the alignment of %s", id)
+	}
+}
+
+// declareAttrs records the attributes a given to a declaration of v, which is merged with the ones given by the previous declarations.
+func (p *Parser) declareAttrs(v vars.Var, a *declAttrs) {
+	if old, ok := p.attrs[v]; ok {
+		a = old.merge(a)
+	}
+	p.attrs[v] = a
+	g, ok := v.(*vars.GVar)
+	if !ok {
+		return
+	}
+	if a.align > g.Align {
+		g.Align = a.align
+	}
+	if fnTy, ok := g.Type().(*types.Fn); ok && a.format != nil {
+		checkFormatAttr(g.Name(), fnTy, a.format)
+	}
+	if a.weak && g.IsStatic {
+		log.Fatalf("Weak declaration of %s must be public", g.Name())
+	}
+	g.Section, g.Alias, g.Visibility = a.section, a.alias, a.visibility
+	g.IsWeak, g.IsUsed = a.weak, a.used
+	g.IsCtor, g.CtorPriority = a.ctor, a.ctorPriority
+	g.IsDtor, g.DtorPriority = a.dtor, a.dtorPriority
+}
+
+// resolveAliases checks that the target of every alias is defined in the translation unit, which is thus referenced.
+func (p *Parser) resolveAliases() {
+	for _, g := range p.Ast.GVars {
+		if g.Alias == "" {
+			continue
+		}
+		target := p.linked[g.Alias]
+		if target == nil || !isDefined(target) {
+			log.Fatalf("%s is aliased to undefined symbol %s", g.Name(), g.Alias)
+		}
+		target.IsReferenced = true
+		g.Alias = target.Label()
+	}
+}
@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"log"
+	"strings"
+
+	"github.com/joehattori/tgocc/ast"
+	"github.com/joehattori/tgocc/types"
+)
+
+// vaListType returns the type of __builtin_va_list, laid out as the System V AMD64 ABI defines.
+func vaListType() types.Type {
+	members := []*types.Member{
+		types.NewMember("gp_offset", 0, types.NewUInt()),
+		types.NewMember("fp_offset", 4, types.NewUInt()),
+		types.NewMember("overflow_arg_area", 8, types.NewPtr(types.NewVoid())),
+		types.NewMember("reg_save_area", 16, types.NewPtr(types.NewVoid())),
+	}
+	return types.NewArr(types.NewStruct(8, members, 24), 1)
+}
+
+// builtin reads a call to the built-in function id. ok is false when id is not a built-in.
+func (p *Parser) builtin(id string) (node ast.Node, ok bool) {
+	switch id {
+	case "__builtin_alloca":
+		p.expect("(")
+		size := p.assign()
+		p.expect(")")
+		return ast.NewAllocaNode(size, p.stackBottom()), true
+	case "__builtin_va_start":
+		p.expect("(")
+		ap := p.assign()
+		p.expect(",")
+		p.assign()
+		p.expect(")")
+		if p.curFn == nil || p.curFn.RegSaveArea == nil {
+			log.Fatal("va_start used in function with fixed arguments")
+		}
+		return ast.NewVaStartNode(ap, p.curFn), true
+	case "__builtin_va_arg":
+		p.expect("(")
+		ap := p.assign()
+		p.expect(",")
+		t := p.typeName()
+		p.expect(")")
+		return ast.NewVaArgNode(ap, t), true
+	case "__builtin_va_end":
+		p.expect("(")
+		ap := p.assign()
+		p.expect(")")
+		return ast.NewCastNode(ap, types.NewVoid()), true
+	case "__builtin_va_copy":
+		p.expect("(")
+		dst := p.assign()
+		p.expect(",")
+		src := p.assign()
+		p.expect(")")
+		// va_list is copied as a struct.
+		return ast.NewAssignNode(ast.NewDerefNode(dst), ast.NewDerefNode(src)), true
+	}
+	return p.atomicBuiltin(id)
+}
+
+// atomicFetchOps holds the built-in functions of the atomic read-modify-write operations,
+// with whether they return the new value rather than the old one.
+var atomicFetchOps = map[string]struct {
+	op         ast.AtomicOp
+	returnsNew bool
+}{
+	"__atomic_fetch_add":   {ast.AtomicFetchAdd, false},
+	"__atomic_fetch_sub":   {ast.AtomicFetchSub, false},
+	"__atomic_fetch_and":   {ast.AtomicFetchAnd, false},
+	"__atomic_fetch_or":    {ast.AtomicFetchOr, false},
+	"__atomic_fetch_xor":   {ast.AtomicFetchXor, false},
+	"__atomic_fetch_nand":  {ast.AtomicFetchNand, false},
+	"__atomic_add_fetch":   {ast.AtomicFetchAdd, true},
+	"__atomic_sub_fetch":   {ast.AtomicFetchSub, true},
+	"__atomic_and_fetch":   {ast.AtomicFetchAnd, true},
+	"__atomic_or_fetch":    {ast.AtomicFetchOr, true},
+	"__atomic_xor_fetch":   {ast.AtomicFetchXor, true},
+	"__atomic_nand_fetch":  {ast.AtomicFetchNand, true},
+	"__sync_fetch_and_add": {ast.AtomicFetchAdd, false},
+	"__sync_fetch_and_sub": {ast.AtomicFetchSub, false},
+	"__sync_fetch_and_and": {ast.AtomicFetchAnd, false},
+	"__sync_fetch_and_or":  {ast.AtomicFetchOr, false},
+	"__sync_fetch_and_xor": {ast.AtomicFetchXor, false},
+	"__sync_add_and_fetch": {ast.AtomicFetchAdd, true},
+	"__sync_sub_and_fetch": {ast.AtomicFetchSub, true},
+	"__sync_and_and_fetch": {ast.AtomicFetchAnd, true},
+	"__sync_or_and_fetch":  {ast.AtomicFetchOr, true},
+	"__sync_xor_and_fetch": {ast.AtomicFetchXor, true},
+}
+
+// atomicBuiltin reads a call to the built-in function id of GCC's atomic operations,
+// i.e. the __atomic ones taking memory orders as <stdatomic.h> does, and the legacy __sync ones.
+// The memory orders are not evaluated, since every operation is sequentially consistent.
+func (p *Parser) atomicBuiltin(id string) (node ast.Node, ok bool) {
+	switch id {
+	case "__atomic_load_n":
+		args := p.builtinArgs(id, 2)
+		return ast.NewAtomicNode(ast.AtomicLoad, atomicObject(id, args[0], false), args[0], nil, false), true
+	case "__atomic_store_n":
+		args := p.builtinArgs(id, 3)
+		return ast.NewAtomicNode(ast.AtomicStore, atomicObject(id, args[0], true), args[0], args[1], false), true
+	case "__atomic_exchange_n", "__sync_lock_test_and_set":
+		n := 3
+		if id == "__sync_lock_test_and_set" {
+			n = 2
+		}
+		args := p.builtinArgs(id, n)
+		return ast.NewAtomicNode(ast.AtomicExchange, atomicObject(id, args[0], true), args[0], args[1], false), true
+	case "__sync_lock_release":
+		args := p.builtinArgs(id, 1)
+		return ast.NewAtomicNode(ast.AtomicStore, atomicObject(id, args[0], true), args[0], ast.NewNumNode(0), false), true
+	case "__atomic_compare_exchange_n":
+		// the arguments are the pointer, the pointer to the expected value, the desired value, weak and the two memory orders.
+		args := p.builtinArgs(id, 6)
+		t := atomicObject(id, args[0], true)
+		if ptr, ok := lvalueConvert(args[1].LoadType()).(*types.Ptr); !ok || ptr.To.Size() != t.Size() {
+			log.Fatalf("Argument 2 of %s must be a pointer to the type of the object", id)
+		}
+		return ast.NewAtomicCASNode(t, args[0], args[1], true, args[2], false), true
+	case "__sync_bool_compare_and_swap", "__sync_val_compare_and_swap":
+		args := p.builtinArgs(id, 3)
+		t := atomicObject(id, args[0], true)
+		return ast.NewAtomicCASNode(t, args[0], args[1], false, args[2], id == "__sync_val_compare_and_swap"), true
+	case "__atomic_thread_fence":
+		args := p.builtinArgs(id, 1)
+		// only a sequentially consistent fence needs an instruction on x86-64, whose loads and stores are ordered otherwise.
+		if order, err := ast.TryEval(args[0]); err == nil && order != atomicSeqCst {
+			return ast.NewCastNode(ast.NewNumNode(0), types.NewVoid()), true
+		}
+		return ast.NewAtomicFenceNode(), true
+	case "__atomic_signal_fence":
+		// a signal handler runs on the same thread, which needs no instruction.
+		p.builtinArgs(id, 1)
+		return ast.NewCastNode(ast.NewNumNode(0), types.NewVoid()), true
+	case "__sync_synchronize":
+		p.builtinArgs(id, 0)
+		return ast.NewAtomicFenceNode(), true
+	}
+	f, ok := atomicFetchOps[id]
+	if !ok {
+		return nil, false
+	}
+	n := 3
+	if strings.HasPrefix(id, "__sync") {
+		n = 2
+	}
+	args := p.builtinArgs(id, n)
+	t := atomicObject(id, args[0], true)
+	if _, isBool := t.(*types.Bool); isBool {
+		log.Fatalf("Operand of %s must not be _Bool", id)
+	}
+	return ast.NewAtomicNode(f.op, t, args[0], args[1], f.returnsNew), true
+}
+
+// atomicSeqCst is __ATOMIC_SEQ_CST, the memory order of sequential consistency.
+const atomicSeqCst = 5
+
+// builtinArgs reads the arguments of the built-in function name, which takes n arguments.
+func (p *Parser) builtinArgs(name string, n int) (args []ast.Node) {
+	p.expect("(")
+	if !p.consume(")") {
+		for {
+			args = append(args, p.assign())
+			if !p.consume(",") {
+				break
+			}
+		}
+		p.expect(")")
+	}
+	if len(args) != n {
+		log.Fatalf("Built-in function %s takes %d arguments, but %d were given", name, n, len(args))
+	}
+	return
+}
+
+// atomicObject returns the type of the object which ptr, the first argument of the atomic built-in function name, points to.
+// It is an integer or a pointer which an instruction can access at once. isModified is set when the function writes to it.
+func atomicObject(name string, ptr ast.Node, isModified bool) types.Type {
+	p, ok := lvalueConvert(ptr.LoadType()).(*types.Ptr)
+	if !ok {
+		log.Fatalf("Argument 1 of %s must be a pointer", name)
+	}
+	t := p.To
+	_, isPtr := t.(*types.Ptr)
+	if !types.IsInteger(t) && !isPtr {
+		log.Fatalf("Argument 1 of %s must point to an integer or a pointer", name)
+	}
+	if isModified && types.IsConst(t) {
+		log.Fatalf("Argument 1 of %s points to a const object", name)
+	}
+	return t
+}
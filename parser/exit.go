@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joehattori/tgocc/ast"
+	"github.com/joehattori/tgocc/types"
+	"github.com/joehattori/tgocc/vars"
+)
+
+// exitScope is a VLA or a variable with the cleanup attribute in scope. They form a list from the innermost one.
+type exitScope struct {
+	// savedSP holds the bottom of the stack area before the VLA was allocated.
+	savedSP *vars.LVar
+	// cleanupFn is the function called with the address of the variable v, which is nil for a VLA.
+	cleanupFn ast.Node
+	v         *vars.LVar
+	parent    *exitScope
+}
+
+// isIn reports whether outer is in the list from e, i.e. a jump from e to outer does not enter any scope.
+func (e *exitScope) isIn(outer *exitScope) bool {
+	for ; e != nil; e = e.parent {
+		if e == outer {
+			return true
+		}
+	}
+	return outer == nil
+}
+
+// leave returns the nodes run when the control leaves the scopes from e to outer. The VLAs are not freed when bottom is nil.
+func (e *exitScope) leave(outer *exitScope, bottom *vars.LVar) (nodes []ast.Node) {
+	for ; e != outer; e = e.parent {
+		if e.cleanupFn != nil {
+			call := ast.NewFnCallNode(e.cleanupFn, []ast.Node{ast.NewAddrNode(ast.NewVarNode(e.v))})
+			nodes = append(nodes, ast.NewExprNode(call))
+		} else if bottom != nil {
+			nodes = append(nodes, ast.NewStackRestoreNode(e.savedSP, bottom))
+		}
+	}
+	return
+}
+
+// what describes e in error messages.
+func (e *exitScope) what() string {
+	if e.cleanupFn == nil {
+		return "a variable length array"
+	}
+	return fmt.Sprintf("variable %s with cleanup attribute", e.v.Name())
+}
+
+// exitGoto is a goto which may leave the scopes of VLAs or variables with cleanup.
+type exitGoto struct {
+	label string
+	exit  *exitScope
+	leave *ast.BlkNode
+}
+
+// restoreExit returns the node leaving the scopes which came in scope after outer, and brings outer back in scope.
+func (p *Parser) restoreExit(outer *exitScope) ast.Node {
+	nodes := p.exit.leave(outer, p.curFn.StackBottom)
+	p.exit = outer
+	if len(nodes) == 0 {
+		return ast.NewNullNode()
+	}
+	return ast.NewBlkNode(nodes)
+}
+
+// leaveExit returns the node leaving the scopes which came in scope after outer before the jump such as break.
+func (p *Parser) leaveExit(outer *exitScope, jump ast.Node) ast.Node {
+	nodes := p.exit.leave(outer, p.curFn.StackBottom)
+	if len(nodes) == 0 {
+		return jump
+	}
+	return ast.NewBlkNode(append(nodes, jump))
+}
+
+// resolveExitGotos fills in the nodes leaving the scopes before the gotos in the current function.
+func (p *Parser) resolveExitGotos() {
+	for _, g := range p.exitGotos {
+		outer := p.labelExits[g.label]
+		if g.exit == outer {
+			continue
+		}
+		if !g.exit.isIn(outer) {
+			log.Fatalf("Jump to label %s enters the scope of %s in %s", g.label, outer.what(), p.curFnName)
+		}
+		g.leave.Body = g.exit.leave(outer, p.curFn.StackBottom)
+	}
+}
+
+// addCleanup brings the variable lv in scope, which is cleaned up by the function named fnName.
+func (p *Parser) addCleanup(lv *vars.LVar, fnName string) {
+	fn, ok := p.findVar(fnName).(*vars.GVar)
+	if !ok {
+		log.Fatalf("Cleanup argument %s of %s is not a function", fnName, lv.Name())
+	}
+	fnTy, ok := fn.Type().(*types.Fn)
+	if !ok {
+		log.Fatalf("Cleanup argument %s of %s is not a function", fnName, lv.Name())
+	}
+	// the variable is used by the cleanup.
+	fn.IsReferenced, lv.IsReferenced = true, true
+	fnNode := ast.NewVarNode(fn)
+	checkArgs(fnNode, fnTy, []ast.Node{ast.NewAddrNode(ast.NewVarNode(lv))})
+	p.exit = &exitScope{cleanupFn: fnNode, v: lv, parent: p.exit}
+}
+
+// ret returns the node returning rhs, which may be nil, from the current function.
+func (p *Parser) ret(rhs ast.Node) ast.Node {
+	cleanups := p.exit.leave(nil, nil)
+	if len(cleanups) == 0 {
+		return ast.NewRetNode(rhs, p.curFn)
+	}
+	var nodes []ast.Node
+	if rhs != nil {
+		if _, isVoid := p.curFn.RetTy.(*types.Void); isVoid {
+			nodes = append(nodes, ast.NewExprNode(rhs))
+			rhs = nil
+		} else {
+			// the value is kept in a temporary, since the cleanup may modify the variables it is computed from.
+			tmp := p.newTmpLVar(types.Unqualified(p.curFn.RetTy))
+			nodes = append(nodes, ast.NewExprNode(ast.NewAssignNode(ast.NewVarNode(tmp), rhs)))
+			rhs = ast.NewVarNode(tmp)
+		}
+	}
+	nodes = append(nodes, cleanups...)
+	return ast.NewBlkNode(append(nodes, ast.NewRetNode(rhs, p.curFn)))
+}
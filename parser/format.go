@@ -0,0 +1,268 @@
+package parser
+
+import (
+	"log"
+	"strings"
+
+	"github.com/joehattori/tgocc/ast"
+	"github.com/joehattori/tgocc/types"
+)
+
+// checkFormat warns about the arguments of the call to fn mismatching its format string, as GCC does with -Wformat.
+func (p *Parser) checkFormat(fn ast.Node, args []ast.Node) {
+	v, ok := fn.(*ast.VarNode)
+	if !ok {
+		return
+	}
+	a, ok := p.attrs[v.Var]
+	if !ok || a.format == nil {
+		return
+	}
+	f := a.format
+	if f.fmtIdx < 1 || f.fmtIdx > len(args) {
+		return
+	}
+	s, ok := isStrNode(args[f.fmtIdx-1])
+	if !ok || s.ElemSize != 1 {
+		return
+	}
+	format := make([]byte, 0, len(s.Units))
+	for _, u := range s.Units[:len(s.Units)-1] {
+		format = append(format, byte(u))
+	}
+	c := &formatChecker{name: v.Var.Name(), args: args, next: f.firstArg - 1, check: f.firstArg > 0}
+	if f.archetype == "scanf" {
+		c.scanf(string(format))
+	} else {
+		c.printf(string(format))
+	}
+	if c.check && c.next < len(args) {
+		log.Printf("warning: too many arguments for format of %s", c.name)
+	}
+}
+
+// formatChecker consumes the arguments of a call to the function name by the conversions in its format string.
+type formatChecker struct {
+	name string
+	args []ast.Node
+	// next is the index of the argument consumed by the next conversion.
+	next int
+	// check is unset for a function taking va_list, whose arguments are not checked.
+	check bool
+}
+
+// consume checks the type of the next argument by ok, where want describes the expected type in the warning.
+func (c *formatChecker) consume(conv string, want string, ok func(t types.Type) bool) {
+	if !c.check {
+		return
+	}
+	if c.next >= len(c.args) {
+		log.Printf("warning: format '%s' expects a matching %s argument of %s", conv, want, c.name)
+		// the missing arguments are reported only once.
+		c.check = false
+		return
+	}
+	t := lvalueConvert(c.args[c.next].LoadType())
+	if !ok(t) {
+		log.Printf("warning: format '%s' expects argument of type %s, but argument %d of %s has type '%s'", conv, want, c.next+1, c.name, types.String(t))
+	}
+	c.next++
+}
+
+// lengthModifier reads the length modifier at the head of s, and returns its size in bytes, or 0, and the rest of s.
+func lengthModifier(s string) (size int, mod string, rest string) {
+	for _, m := range []string{"hh", "h", "ll", "l", "j", "z", "t", "q", "L"} {
+		if strings.HasPrefix(s, m) {
+			switch m {
+			case "hh":
+				size = 1
+			case "h":
+				size = 2
+			default:
+				size = 8
+			}
+			return size, m, s[len(m):]
+		}
+	}
+	return 0, "", s
+}
+
+func isIntArg(size int) func(t types.Type) bool {
+	return func(t types.Type) bool {
+		if !types.IsInteger(t) {
+			return false
+		}
+		// arguments narrower than int are promoted to int.
+		if size == 8 {
+			return t.Size() == 8
+		}
+		return t.Size() <= 4
+	}
+}
+
+func isPtrArg(ok func(to types.Type) bool) func(t types.Type) bool {
+	return func(t types.Type) bool {
+		ptr, isPtr := t.(*types.Ptr)
+		return isPtr && ok(ptr.To)
+	}
+}
+
+func isAny(types.Type) bool { return true }
+
+// isIntOfSize returns the check of an integer object of the size, or of int when size is 0.
+func isIntOfSize(size int) func(t types.Type) bool {
+	if size == 0 {
+		size = 4
+	}
+	return func(t types.Type) bool { return types.IsInteger(t) && t.Size() == size }
+}
+
+func isChar(t types.Type) bool {
+	_, ok := t.(*types.Char)
+	return ok
+}
+
+// intName describes the integer type of the length modifier size.
+func intName(size int) string {
+	switch size {
+	case 1:
+		return "'char'"
+	case 2:
+		return "'short'"
+	case 8:
+		return "'long'"
+	}
+	return "'int'"
+}
+
+// printf checks the arguments against the printf format string s.
+func (c *formatChecker) printf(s string) {
+	for {
+		i := strings.IndexByte(s, '%')
+		if i < 0 {
+			return
+		}
+		s = s[i+1:]
+		if strings.HasPrefix(s, "%") {
+			s = s[1:]
+			continue
+		}
+		s = strings.TrimLeft(s, "-+ #0'")
+		// the width and the precision given by `*` are int arguments.
+		if strings.HasPrefix(s, "*") {
+			c.consume("*", "'int'", isIntArg(4))
+			s = s[1:]
+		}
+		s = strings.TrimLeft(s, "0123456789")
+		if strings.HasPrefix(s, ".") {
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				c.consume(".*", "'int'", isIntArg(4))
+				s = s[1:]
+			}
+			s = strings.TrimLeft(s, "0123456789")
+		}
+		size, mod, rest := lengthModifier(s)
+		s = rest
+		if s == "" {
+			log.Printf("warning: spurious trailing '%%' in format of %s", c.name)
+			return
+		}
+		conv := "%" + mod + s[:1]
+		switch s[0] {
+		case 'd', 'i', 'o', 'u', 'x', 'X', 'c':
+			c.consume(conv, intName(size), isIntArg(size))
+		case 's':
+			if mod == "l" {
+				c.consume(conv, "'wchar_t *'", isPtrArg(isIntOfSize(4)))
+			} else {
+				c.consume(conv, "'char *'", isPtrArg(isChar))
+			}
+		case 'p':
+			c.consume(conv, "'void *'", isPtrArg(isAny))
+		case 'n':
+			c.consume(conv, intName(size)+" pointer", isPtrArg(isIntOfSize(size)))
+		case 'f', 'F', 'e', 'E', 'g', 'G', 'a', 'A':
+			// floating point types are not supported, so no argument matches.
+			c.consume(conv, "'double'", func(types.Type) bool { return false })
+		default:
+			log.Printf("warning: unknown conversion type character '%c' in format of %s", s[0], c.name)
+		}
+		s = s[1:]
+	}
+}
+
+// scanf checks the arguments against the scanf format string s, where every conversion stores to a pointer.
+func (c *formatChecker) scanf(s string) {
+	for {
+		i := strings.IndexByte(s, '%')
+		if i < 0 {
+			return
+		}
+		s = s[i+1:]
+		if strings.HasPrefix(s, "%") {
+			s = s[1:]
+			continue
+		}
+		// `*` suppresses the assignment, which consumes no argument.
+		suppress := strings.HasPrefix(s, "*")
+		s = strings.TrimLeft(strings.TrimPrefix(s, "*"), "0123456789")
+		size, mod, rest := lengthModifier(s)
+		s = rest
+		if s == "" {
+			log.Printf("warning: spurious trailing '%%' in format of %s", c.name)
+			return
+		}
+		conv := "%" + mod + s[:1]
+		var want string
+		var ok func(t types.Type) bool
+		switch s[0] {
+		case 'd', 'i', 'o', 'u', 'x', 'X', 'n':
+			want, ok = intName(size)+" pointer", isPtrArg(isIntOfSize(size))
+		case 's', 'c', '[':
+			want, ok = "'char *'", isPtrArg(isChar)
+			if s[0] == '[' {
+				// skip the scanset, where `]` right after `[` or `[^` is a member of it.
+				j := 1
+				if strings.HasPrefix(s[j:], "^") {
+					j++
+				}
+				if strings.HasPrefix(s[j:], "]") {
+					j++
+				}
+				if k := strings.IndexByte(s[j:], ']'); k >= 0 {
+					s = s[j+k:]
+				} else {
+					log.Printf("warning: no closing ']' for '%%[' format of %s", c.name)
+					return
+				}
+			}
+		case 'p':
+			want, ok = "'void **'", isPtrArg(func(t types.Type) bool { _, isPtr := t.(*types.Ptr); return isPtr })
+		case 'f', 'F', 'e', 'E', 'g', 'G', 'a', 'A':
+			want, ok = "'float *'", func(types.Type) bool { return false }
+		default:
+			log.Printf("warning: unknown conversion type character '%c' in format of %s", s[0], c.name)
+		}
+		if ok != nil && !suppress {
+			c.consume(conv, want, ok)
+		}
+		s = s[1:]
+	}
+}
+
+// checkFormatAttr checks that the format attribute a of the function fnTy refers to its parameters.
+func checkFormatAttr(name string, fnTy *types.Fn, a *formatAttr) {
+	if !fnTy.HasProto {
+		return
+	}
+	if a.fmtIdx < 1 || a.fmtIdx > len(fnTy.Params) {
+		log.Fatalf("Format string argument %d of %s is out of range", a.fmtIdx, name)
+	}
+	if ptr, ok := fnTy.Params[a.fmtIdx-1].(*types.Ptr); !ok || !isChar(ptr.To) {
+		log.Fatalf("Format string argument %d of %s is not a string type", a.fmtIdx, name)
+	}
+	if a.firstArg != 0 && (a.firstArg <= a.fmtIdx || !fnTy.IsVariadic || a.firstArg != len(fnTy.Params)+1) {
+		log.Fatalf("Format arguments of %s should begin at the variadic arguments, but %d is given", name, a.firstArg)
+	}
+}
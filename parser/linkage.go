@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joehattori/tgocc/ast"
+	"github.com/joehattori/tgocc/types"
+	"github.com/joehattori/tgocc/vars"
+)
+
+var localStaticCount int
+
+// newLocalStaticLabel returns a unique label for the static local variable name,
+// since static local variables of the same name may be declared in different blocks.
+func newLocalStaticLabel(name string) string {
+	defer func() { localStaticCount++ }()
+	return fmt.Sprintf("%s.%d", name, localStaticCount)
+}
+
+// declareGlobal declares id with linkage in the scope s and returns the variable denoting it.
+// Every declaration of id with linkage in the file, including the ones in blocks, denotes the same variable.
+// An extern declaration or a function declaration without storage class follows the linkage of the previous declaration.
+func (p *Parser) declareGlobal(s *scope, id string, t types.Type, sc storageClass) *vars.GVar {
+	_, isFn := t.(*types.Fn)
+	isTLS := (sc & threadLocal) != 0
+	if isFn && isTLS {
+		log.Fatalf("Function %s is declared thread-local", id)
+	}
+	g := p.linked[id]
+	if g != nil && !isFn && g.IsTLS != isTLS {
+		if isTLS {
+			log.Fatalf("Thread-local declaration of %s follows non-thread-local declaration", id)
+		}
+		log.Fatalf("Non-thread-local declaration of %s follows thread-local declaration", id)
+	}
+	switch {
+	case g == nil:
+		g = vars.NewGVar(false, id, t, nil)
+		g.IsStatic = (sc & static) != 0
+		g.IsTLS = isTLS
+		p.linked[id] = g
+		// functions are held as well, since their symbols may have attributes.
+		p.Ast.GVars = append(p.Ast.GVars, g)
+	case (sc & static) != 0:
+		if !g.IsStatic {
+			log.Fatalf("Static declaration of %s follows non-static declaration", id)
+		}
+		mergeGlobalType(g, t)
+	case (sc&extern) == 0 && !isFn:
+		if g.IsStatic {
+			log.Fatalf("Non-static declaration of %s follows static declaration", id)
+		}
+		mergeGlobalType(g, t)
+	default:
+		mergeGlobalType(g, t)
+	}
+
+	switch v := s.searchVar(id).(type) {
+	case nil:
+		s.vars = append(s.vars, g)
+	case *vars.GVar:
+		if v != g {
+			log.Fatalf("identifier %s is already defined", id)
+		}
+	default:
+		log.Fatalf("identifier %s is already defined", id)
+	}
+	return g
+}
+
+// mergeGlobalType merges the type t of a redeclaration into g.
+func mergeGlobalType(g *vars.GVar, t types.Type) {
+	id := g.Name()
+	switch old := g.Type().(type) {
+	case *types.Fn:
+		fn, ok := t.(*types.Fn)
+		if !ok || !types.IsCompatible(old, fn) {
+			log.Fatalf("Conflicting types for %s", id)
+		}
+		if old.IsComplete && fn.IsComplete {
+			log.Fatalf("Redefinition of function %s", id)
+		}
+		// the definition, or else the declaration with prototype, is kept.
+		if fn.IsComplete || !old.IsComplete && fn.HasProto {
+			g.SetType(t)
+		}
+	case *types.Arr:
+		if !types.IsCompatible(old, t) {
+			log.Fatalf("Conflicting types for %s", id)
+		}
+		// the length is completed by a later declaration, e.g) extern int a[]; int a[3];
+		if old.Len < 0 {
+			g.SetType(t)
+		}
+	default:
+		if !types.IsCompatible(old, t) {
+			log.Fatalf("Conflicting types for %s", id)
+		}
+	}
+}
+
+// defineGlobal records the definition of the variable g by a declaration with the initializer rhs, which may be nil.
+// A declaration without initializer is a tentative definition,
+// which defines g initialized with zero unless another declaration initializes it.
+func defineGlobal(g *vars.GVar, t types.Type, rhs ast.Node) {
+	g.Emit = true
+	if rhs == nil {
+		return
+	}
+	if g.Init != nil {
+		log.Fatalf("Redefinition of %s", g.Name())
+	}
+	g.Init = buildGVarInit(t, rhs)
+	// buildGVarInit decides the length of an array initialized without it.
+	mergeGlobalType(g, t)
+}
+
+// completeTentativeArrays completes the type of each array defined only by tentative definitions of unknown length,
+// e.g) int a[];, which has one element at the end of the translation unit.
+func (p *Parser) completeTentativeArrays() {
+	for _, g := range p.Ast.GVars {
+		if arr, ok := g.Type().(*types.Arr); ok && g.Emit && g.Init == nil && arr.Len < 0 {
+			log.Printf("warning: array %s assumed to have one element", g.Name())
+			g.SetType(types.NewArr(arr.Of, 1))
+		}
+	}
+}
+
+// isDefined reports whether the variable or function g is defined in this translation unit.
+func isDefined(g *vars.GVar) bool {
+	if fn, ok := g.Type().(*types.Fn); ok {
+		return fn.IsComplete
+	}
+	return g.Emit
+}
+
+// warnUnusedGlobals warns about the static variables and functions defined but never referenced.
+func (p *Parser) warnUnusedGlobals() {
+	for _, g := range p.Ast.GVars {
+		if g.IsDiscarded() && isDefined(g) && !p.isMaybeUnused(g) {
+			log.Printf("warning: %s defined but not used", g.Name())
+		}
+	}
+}
+
+// isMaybeUnused reports whether v has the unused attribute.
+func (p *Parser) isMaybeUnused(v vars.Var) bool {
+	a, ok := p.attrs[v]
+	return ok && a.unused
+}
@@ -2,6 +2,9 @@ package parser
 
 import (
 	"log"
+	"math"
+	"os"
+	"strings"
 
 	"github.com/joehattori/tgocc/ast"
 	"github.com/joehattori/tgocc/tokenizer"
@@ -11,24 +14,57 @@ import (
 
 // Parser holds the structure defining a parser object.
 type Parser struct {
+	curFn     *ast.FnNode
 	curFnName string
 	curScope  *scope
 	Ast       *ast.Ast
 	Toks      []tokenizer.Token
+
+	// labels are the labels defined in the current function, and labelRefs are the ones used by goto or `&&`.
+	labels    map[string]bool
+	labelRefs []string
+
+	// paramScope and paramNames are the scope and the names of the parameters of the function declarator read last,
+	// which become those of the function when the declarator begins its definition.
+	paramScope *scope
+	paramNames []string
+
+	// exit is the innermost VLA or variable with cleanup in scope, and breakExit and continueExit are the ones where break and continue jump to.
+	exit, breakExit, continueExit *exitScope
+	// labelExits holds the innermost one in scope at each label.
+	labelExits map[string]*exitScope
+	exitGotos  []exitGoto
+
+	// DumpRecordLayouts makes the parser print the layout of every struct and union to stderr.
+	DumpRecordLayouts bool
+	// WerrorImplicitFnDecl makes a call to an undeclared function an error instead of a warning.
+	WerrorImplicitFnDecl bool
+	// WarnUnused makes the parser warn about the variables and static functions which are never referenced.
+	WarnUnused bool
+
+	// linked holds the variables and functions with linkage declared so far, which may be declared in blocks.
+	linked map[string]*vars.GVar
+	// strLits holds the arrays of the string literals by their contents.
+	strLits map[string]*vars.GVar
+	// attrs holds the attributes given to the declarations of each variable and function.
+	attrs map[vars.Var]*declAttrs
 }
 
 // NewParser creates a new parser.
 func NewParser(toks []tokenizer.Token) *Parser {
-	return &Parser{"", &scope{}, &ast.Ast{}, toks}
+	p := &Parser{curScope: &scope{}, Ast: &ast.Ast{}, Toks: toks, linked: map[string]*vars.GVar{}, strLits: map[string]*vars.GVar{}, attrs: map[vars.Var]*declAttrs{}}
+	p.curScope.addTypeDef("__builtin_va_list", vaListType())
+	return p
 }
 
 /*
 Actual parsing process from here.
 
-	program    = (function | globalVar)*
-	function   = baseType types.TypeDecl "(" (ident ("," ident)* )? ")" ("{" stmt* "}" | ";")
+	program    = (function | globalVar | staticAssert)*
+	function   = baseType tyDecl ("{" stmt* "}" | ";")
 	globalVar  = decl
 	stmt       = expr ";"
+				| staticAssert
   				| "{" stmt* "}"
   				| "return" expr ";"
   				| "if" "(" expr ")" stmt ("else" stmt) ?
@@ -37,12 +73,28 @@ Actual parsing process from here.
   				| "for" "(" (expr? ";" | decl) expr? ";" expr? ")" stmt
 				| "typedef" types.Type ident ("[" constExpr "]")* ";"
 				| "switch" "(" expr ")" "{" switchCase* ("default" ":" stmt*)? }"
+				| "goto" (ident | "*" expr) ";"
+				| ident ":" stmt
   				| decl
 	switchCase = "case" num ":" stmt*
-	decl       = baseType types.TypeDecl ("[" constExpr "]")* "=" initialize ;" | baseTy ";"
-	tyDecl     = "*"* (ident | "(" types.TypeDecl ")")
-	expr       = assign
-	assign     = ternary (("=" | "+=" | "-=" | "*=" | "/=") assign) ?
+	staticAssert = ("_Static_assert" | "static_assert") "(" constExpr ("," str)? ")" ";"
+	decl       = baseType (declarator ("," declarator)*)? ";"
+	declarator = tyDecl ("=" initializer)?
+	initializer = str | "{" (initElem ("," initElem)* ","?)? "}" | assign
+	initElem   = (designator+ "=")? initializer
+	designator = "[" constExpr ("..." constExpr)? "]" | "." ident
+	baseType   = (qualifier | alignas)* ("typedef" | "static" | "extern")? (qualifier | alignas)* typeSpec (qualifier | alignas)*
+	qualifier  = "const" | "volatile" | "restrict"
+	typeSpec   = ("signed" | "unsigned")? ("char" | "short" | "int" | "long") | "void" | "_Bool" | structDecl | enumDecl | ident | typeof
+	typeof     = ("typeof" | "__typeof__" | "typeof_unqual" | "__typeof_unqual__") "(" (typeName | expr) ")"
+	alignas    = ("_Alignas" | "alignas") "(" (typeName | constExpr) ")"
+	pointers   = ("*" qualifier*)*
+	tyDecl     = pointers (ident? | "(" tyDecl ")") tySuffix
+	typeName   = baseType tyDecl
+	tySuffix   = ("[" assign? "]")* | "(" fnParams ")"
+	fnParams   = "void" | (baseType tyDecl ("," baseType tyDecl)* ("," "...")?)?
+	expr       = assign ("," assign)*
+	assign     = ternary (("=" | "+=" | "-=" | "*=" | "/=" | "%=" | "&=" | "|=" | "^=" | "<<=" | ">>=") assign) ?
 	ternary    = logOr ("?" expr ":" ternary)?
 	logOr      = logAnd ("||" logAnd)*
 	logAnd     = bitOr ("&&" bitOr)*
@@ -51,38 +103,74 @@ Actual parsing process from here.
 	bitAnd     = equality ("&" equality)*
 	equality   = relational ("==" relational | "!=" relational)*
 	relational = shift ("<" shift | "<=" shift | ">" shift | ">=" shift)*
-	shift      = add ("<<" add | ">>" add | "<<=" add | ">>=" add)
+	shift      = add ("<<" add | ">>" add)*
 	add        = mul ("+" mul | "-" mul)*
-	mul        = cat ("*" cast | "/" cast)*
-	cast       = "(" baseType "*"*  ")" cast | unary
-	unary      = ("+" | "-" | "*" | "&" | "!" | "~")? cast | ("++" | "--") unary | postfix
-	postfix    = primary (("[" expr "]") | ("." ident) | ("->" ident) | "++" | "--")*
+	mul        = cast ("*" cast | "/" cast | "%" cast)*
+	cast       = "(" typeName ")" cast | unary
+	unary      = ("+" | "-" | "*" | "&" | "!" | "~")? cast | ("++" | "--") unary | "&&" ident | postfix
+	postfix    = primary (("[" expr "]") | ("(" (assign ("," assign)*)? ")") | ("." ident) | ("->" ident) | "++" | "--")*
 	primary    =  num
+				| "sizeof" "(" typeName ")"
 				| "sizeof" unary
+				| ("_Alignof" | "alignof") "(" typeName ")"
+				| ("_Alignof" | "alignof") unary
 				| str
-				| ident ("(" (expr ("," expr)* )? ")")?
+				| ident
 				| "(" expr ")"
+				| "(" typeName ")" "{" (initElem ("," initElem)* ","?)? "}"
 				| stmtExpr
+				| genericSel
+				| builtin
 	stmtExpr   = "(" "{" stmt+ "}" ")"
+	genericSel = "_Generic" "(" assign ("," (typeName | "default") ":" assign)+ ")"
+	builtin    = "__builtin_alloca" "(" assign ")"
+				| "__builtin_va_start" "(" assign "," assign ")"
+				| "__builtin_va_arg" "(" assign "," typeName ")"
+				| "__builtin_va_end" "(" assign ")"
+				| "__builtin_va_copy" "(" assign "," assign ")"
 */
 
 // Parse traverses tokens and generates Ast.
 func (p *Parser) Parse() {
-	ast := p.Ast
 	for !p.isEOF() {
-		if p.isFunction() {
-			if fn := p.function(); fn != nil {
-				ast.Fns = append(ast.Fns, fn)
+		if p.staticAssert() {
+			continue
+		}
+		if p.consumeAsm() {
+			p.Ast.Asms = append(p.Ast.Asms, p.topLevelAsm())
+			continue
+		}
+		// the base type is read only once since it may define struct, union or enum tags.
+		ty, isTypeDef, sc, attrs := p.baseType()
+		if !isTypeDef && p.isFunction() {
+			if fn := p.function(ty, sc, attrs); fn != nil {
+				p.Ast.Fns = append(p.Ast.Fns, fn)
 			}
 		} else {
-			if ty, id, rhs, sc := p.decl(); ty != nil {
-				init := buildGVarInit(ty, rhs)
-				emit := (sc & extern) == 0
-				p.curScope.addGVar(emit, id, ty, init)
-				ast.GVars = append(ast.GVars, vars.NewGVar(emit, id, ty, init))
-			}
+			p.declRest(ty, isTypeDef, sc, attrs, func(ty types.Type, id string, rhs ast.Node, attrs *declAttrs) {
+				g := p.declareGlobal(p.curScope, id, ty, sc)
+				p.declareAttrs(g, attrs)
+				if attrs.cleanup != "" {
+					log.Printf("warning: cleanup attribute of %s is ignored at file scope", id)
+				}
+				if attrs.alias != "" {
+					// an alias is defined by its target.
+					if rhs != nil {
+						log.Fatalf("Alias %s is initialized", id)
+					}
+					return
+				}
+				if _, isFn := ty.(*types.Fn); !isFn && (sc&extern) == 0 {
+					defineGlobal(g, ty, rhs)
+				}
+			})
 		}
 	}
+	p.completeTentativeArrays()
+	p.resolveAliases()
+	if p.WarnUnused {
+		p.warnUnusedGlobals()
+	}
 }
 
 func buildGVarInit(t types.Type, rhs ast.Node) vars.GVarInit {
@@ -97,270 +185,934 @@ func buildGVarInit(t types.Type, rhs ast.Node) vars.GVarInit {
 			idx := 0
 			for _, e := range rhs.Body {
 				idx++
-				body = append(body, buildGVarInit(t.Of, e))
+				if e == nil {
+					body = append(body, vars.NewGVarInitZero(t.Of.Size()))
+				} else {
+					body = append(body, buildGVarInit(t.Of, e))
+				}
 			}
 			if t.Len < 0 {
 				t.Len = idx
 			}
 			// zero out the rest
-			if _, ok := t.Of.(*types.Arr); !ok {
-				for i := idx; i < t.Len; i++ {
-					body = append(body, vars.NewGVarInitZero(t.Of.Size()))
-				}
+			for i := idx; i < t.Len; i++ {
+				body = append(body, vars.NewGVarInitZero(t.Of.Size()))
 			}
 			return vars.NewGVarInitArr(body)
 		default:
-			if ok, str := isStrNode(rhs); ok {
+			if s, ok := isStrNode(rhs); ok {
 				if t.Len < 0 {
-					t.Len = len(str)
-				} else if t.Len > len(str) {
-					for i := len(str); i < t.Len; i++ {
-						str += string('\000')
-					}
+					t.Len = len(s.Units)
 				}
-				return vars.NewGVarInitStr(str)
+				// the rest is zero, and the terminating null character is dropped when the array has no room for it.
+				units := make([]uint32, t.Len)
+				copy(units, s.Units)
+				return vars.NewGVarInitStr(units, s.ElemSize)
 			}
 			log.Fatalf("Unhandled case in global vars.Var initialization: %T", rhs)
 			return nil
 		}
 	case *types.Struct:
+		blk, ok := rhs.(*ast.BlkNode)
+		if !ok {
+			log.Fatal("Initializer element is not constant")
+		}
 		var body []vars.GVarInit
-		idx := 0
-		for i, e := range rhs.(*ast.BlkNode).Body {
-			idx++
+		inits := blk.Body
+		// pos is the number of bytes already emitted.
+		pos := 0
+		for i := 0; i < len(t.Members); i++ {
 			mem := t.Members[i]
-			if e == nil {
-				body = append(body, vars.NewGVarInitZero(mem.Type.Size()))
+			if mem.IsBitField {
+				// consecutive bit-fields are packed together byte by byte.
+				start := (mem.Offset*8 + mem.BitOffset) / 8
+				var buf []byte
+				for ; i < len(t.Members) && t.Members[i].IsBitField; i++ {
+					buf = packBitField(buf, start, t.Members[i], inits[i])
+				}
+				i--
+				if start > pos {
+					body = append(body, vars.NewGVarInitZero(start-pos))
+				}
+				for _, b := range buf {
+					body = append(body, vars.NewGVarInitInt(int64(b), 1))
+				}
+				pos = start + len(buf)
 				continue
 			}
-			var toAppend []vars.GVarInit
-			toAppend = append(toAppend, buildGVarInit(mem.Type, e))
 			// padding for struct members
-			var end int
-			if i < len(t.Members)-1 {
-				end = t.Members[i+1].Offset
-			} else {
-				end = t.Size()
+			if mem.Offset > pos {
+				body = append(body, vars.NewGVarInitZero(mem.Offset-pos))
 			}
-			start := mem.Offset + mem.Type.Size()
-			if end-start > 0 {
-				toAppend = append(toAppend, vars.NewGVarInitZero(end-start))
+			if inits[i] == nil {
+				body = append(body, vars.NewGVarInitZero(mem.Type.Size()))
+			} else {
+				body = append(body, buildGVarInit(mem.Type, inits[i]))
 			}
-			body = append(body, vars.NewGVarInitArr(toAppend))
+			pos = mem.Offset + mem.Type.Size()
+		}
+		if t.Size() > pos {
+			body = append(body, vars.NewGVarInitZero(t.Size()-pos))
 		}
 		return vars.NewGVarInitArr(body)
+	case *types.Union:
+		blk, ok := rhs.(*ast.BlkNode)
+		if !ok {
+			log.Fatal("Initializer element is not constant")
+		}
+		for i, e := range blk.Body {
+			if e == nil {
+				continue
+			}
+			mem := t.Members[i]
+			body := []vars.GVarInit{buildGVarInit(mem.Type, e)}
+			if pad := t.Size() - mem.Type.Size(); pad > 0 {
+				body = append(body, vars.NewGVarInitZero(pad))
+			}
+			return vars.NewGVarInitArr(body)
+		}
+		return vars.NewGVarInitZero(t.Size())
 	default:
-		switch rhs := rhs.(type) {
-		case *ast.AddrNode:
-			return vars.NewGVarInitLabel(rhs.Var.(*ast.VarNode).Var.Name())
-		case *ast.VarNode:
-			if _, ok := rhs.Var.Type().(*types.Arr); ok {
-				return vars.NewGVarInitLabel(rhs.Var.Name())
+		if label, addend, ok := ast.EvalAddr(rhs); ok {
+			if t.Size() != 8 {
+				log.Fatal("Initializer element is not computable at load time")
 			}
+			return vars.NewGVarInitLabel(label, addend)
 		}
-		return vars.NewGVarInitInt(ast.Eval(rhs), t.Size())
+		return vars.NewGVarInitInt(ast.EvalAs(rhs, t), t.Size())
 	}
 }
 
-func (p *Parser) function() *ast.FnNode {
-	ty, _, sc := p.baseType()
-	fnName, ty := p.tyDecl(ty)
-	p.curFnName = fnName
-	fn := ast.NewFnNode((sc&static) != 0, fnName, ty)
-	p.spawnScope()
-	p.readFnParams(fn)
+// packBitField writes the initial value of the bit-field mem into buf, which begins at the byte start.
+func packBitField(buf []byte, start int, mem *types.Member, init ast.Node) []byte {
+	var val int64
+	if init != nil {
+		val = ast.Eval(init)
+	}
+	bit := mem.Offset*8 + mem.BitOffset - start*8
+	for i := 0; i < mem.BitWidth; i++ {
+		idx := (bit + i) / 8
+		for len(buf) <= idx {
+			buf = append(buf, 0)
+		}
+		if val>>i&1 == 1 {
+			buf[idx] |= 1 << ((bit + i) % 8)
+		}
+	}
+	return buf
+}
+
+func (p *Parser) function(ty types.Type, sc storageClass, attrs *declAttrs) *ast.FnNode {
+	// the declarator may be nested, e.g) int (*getf(int k))(int, int)
+	fnName, t := p.tyDecl(ty)
+	fnTy := t.(*types.Fn)
+	names, params := p.paramNames, p.paramScope
+	// GNU C allows attributes only before the declarator in a function definition, but they are accepted after it as well.
+	a := *attrs
+	p.attributes(&a)
+	attrs = &a
 	if p.consume(";") {
-		p.rewindScope()
-		p.curScope.addGVar((sc&static) != 0, fnName, types.NewFn(ty, false), nil)
+		g := p.declareGlobal(p.curScope, fnName, fnTy, sc)
+		p.declareAttrs(g, attrs)
 		return nil
 	}
+	p.curFnName = fnName
+	fn := ast.NewFnNode(fnName, fnTy.RetTy)
+	p.curFn = fn
+	// the parameters are declared in the outermost scope of the function.
+	p.curScope = params
+	if _, ok := fnTy.RetTy.(types.Composite); ok {
+		fn.RetPtr = p.newTmpLVar(types.NewPtr(fnTy.RetTy))
+	}
+	if attrs.alias != "" {
+		log.Fatalf("Function %s defined with alias attribute", fnName)
+	}
+	// parameters may be unnamed only in a prototype.
+	for _, name := range names {
+		if name == "" {
+			log.Fatalf("Parameter name omitted in the definition of %s", fnName)
+		}
+		fn.Params = append(fn.Params, p.curScope.searchVar(name).(*vars.LVar))
+	}
+	if fnTy.IsVariadic {
+		// general purpose registers and xmm registers are spilled here for va_arg.
+		fn.RegSaveArea = p.newTmpLVar(types.NewArr(types.NewChar(), 176))
+	}
+	// register the function before reading its body so that it can call itself.
+	fnTy.IsComplete = true
+	fn.Sym = p.declareGlobal(p.curScope.super, fnName, fnTy, sc)
+	p.declareAttrs(fn.Sym, attrs)
 	p.expect("{")
+	p.labels, p.labelRefs = map[string]bool{}, nil
+	p.exit, p.breakExit, p.continueExit = nil, nil, nil
+	p.labelExits, p.exitGotos = map[string]*exitScope{}, nil
+	for _, param := range fn.Params {
+		// the sizes of the VLAs pointed by the parameters, e.g) int m[][n], are computed on entry.
+		if sizes := vlaSizes(param.Type()); sizes != nil {
+			fn.Body = append(fn.Body, ast.NewExprNode(sizes))
+		}
+	}
 	for !p.consume("}") {
 		fn.Body = append(fn.Body, p.stmt())
 	}
+	// the variables declared in the outermost block are cleaned up when the control reaches its end.
+	fn.Body = append(fn.Body, ast.NewBlkNode(p.exit.leave(nil, nil)))
+	// labels are function-scoped, so a goto may refer to a label defined after it.
+	for _, label := range p.labelRefs {
+		if !p.labels[label] {
+			log.Fatalf("Label %s used but not defined in %s", label, fnName)
+		}
+	}
+	p.resolveExitGotos()
 	p.setFnLVars(fn)
 	p.rewindScope()
-	p.curScope.addGVar((sc&static) != 0, fnName, types.NewFn(ty, true), nil)
-	// TODO: align
-	fn.StackSize = p.curScope.curOffset
+	// the stack is kept aligned to 16 bytes, so that VLAs and alloca can allocate aligned areas below it.
+	fn.StackSize = types.AlignTo(p.curScope.curOffset, 16)
 	return fn
 }
 
+// maxStackAlign is the alignment of the stack frame, which bounds the alignment of local variables.
+const maxStackAlign = 16
+
+// staticAssert reads `_Static_assert(expr, "msg");` and aborts with the message when expr is zero.
+// It returns false when the next tokens are not a static assertion.
+func (p *Parser) staticAssert() bool {
+	if !p.consume("_Static_assert") && !p.consume("static_assert") {
+		return false
+	}
+	p.expect("(")
+	val := p.constExpr()
+	var msg string
+	// the message may be omitted since C23.
+	if p.consume(",") {
+		msg = strings.TrimRight(p.expectStr().Str(), "\000")
+	}
+	p.expect(")")
+	p.expect(";")
+	if val == 0 {
+		where := "at file scope"
+		if p.curScope.super != nil {
+			where = "in function " + p.curFnName
+		}
+		log.Fatalf("Static assertion failed %s: %s", where, msg)
+	}
+	return true
+}
+
 type storageClass int
 
 const (
-	static storageClass = 0b01
-	extern storageClass = 0b10
+	static storageClass = 0b001
+	extern storageClass = 0b010
+	// threadLocal is given by _Thread_local, thread_local or GNU's __thread, along with static or extern.
+	threadLocal storageClass = 0b100
 )
 
-func (p *Parser) decl() (t types.Type, id string, rhs ast.Node, sc storageClass) {
-	t, isTypeDef, sc := p.baseType()
+// localDecl reads a declaration in a block and returns the statements initializing the declared variables.
+func (p *Parser) localDecl() ast.Node {
+	t, isTypeDef, sc, attrs := p.baseType()
+	var nodes []ast.Node
+	p.declRest(t, isTypeDef, sc, attrs, func(t types.Type, id string, rhs ast.Node, attrs *declAttrs) {
+		if _, isFn := t.(*types.Fn); isFn || (sc&extern) != 0 {
+			// a function or an extern variable declared in a block refers to the one with linkage.
+			if rhs != nil {
+				log.Fatalf("Function %s is initialized like a variable", id)
+			}
+			if (sc & static) != 0 {
+				log.Fatalf("Invalid storage class for function %s in block", id)
+			}
+			g := p.declareGlobal(p.curScope, id, t, sc)
+			p.declareAttrs(g, attrs)
+			return
+		}
+		if attrs.cleanup != "" && (sc&static) != 0 {
+			log.Printf("warning: cleanup attribute of static variable %s is ignored", id)
+		}
+		if (sc&threadLocal) != 0 && (sc&static) == 0 {
+			log.Fatalf("Thread-local variable %s in block must be static or extern", id)
+		}
+		if (sc & static) != 0 {
+			if types.IsVariablyModified(t) {
+				log.Fatalf("Static variable %s has variably modified type", id)
+			}
+			// a static local variable has no linkage, so it is distinct from any other variable of the same name.
+			g := vars.NewGVar(true, id, t, nil)
+			g.SetLabel(newLocalStaticLabel(id))
+			g.IsStatic = true
+			g.IsTLS = (sc & threadLocal) != 0
+			g.Fn = p.curFn.Sym
+			p.declareAttrs(g, attrs)
+			if rhs != nil {
+				g.Init = buildGVarInit(t, rhs)
+			}
+			p.curScope.addVar(g)
+			p.Ast.GVars = append(p.Ast.GVars, g)
+			return
+		}
+		if attrs.align > maxStackAlign || t.Alignment() > maxStackAlign {
+			log.Fatalf("Alignment of local variable %s exceeds %d bytes", id, maxStackAlign)
+		}
+		lv := p.curScope.addLVar(id, t)
+		lv.Align = attrs.align
+		p.declareAttrs(lv, attrs)
+		if _, ok := t.(*types.VLA); ok {
+			if rhs != nil {
+				log.Fatalf("Variable-sized object %s may not be initialized", id)
+			}
+			nodes = append(nodes, p.allocVLA(lv))
+			if attrs.cleanup != "" {
+				p.addCleanup(lv, attrs.cleanup)
+			}
+			return
+		}
+		if sizes := vlaSizes(t); sizes != nil {
+			nodes = append(nodes, ast.NewExprNode(sizes))
+		}
+		if rhs != nil {
+			nodes = append(nodes, storeInit(t, ast.NewVarNode(p.findVar(id)), rhs))
+		}
+		// the variable is cleaned up only after its initialization is reached.
+		if attrs.cleanup != "" {
+			p.addCleanup(lv, attrs.cleanup)
+		}
+	})
+	return ast.NewBlkNode(nodes)
+}
+
+// declRest reads the declarators following the base type of a declaration, e.g) `a, *b = &a, c[3];`.
+// register is called with each declared variable before the next declarator is read,
+// so that an initializer can refer to the variables declared before it.
+func (p *Parser) declRest(t types.Type, isTypeDef bool, sc storageClass, attrs *declAttrs, register func(t types.Type, id string, rhs ast.Node, attrs *declAttrs)) {
 	if p.consume(";") {
 		return
 	}
-	id, t = p.tyDecl(t)
-	if isTypeDef {
-		p.expect(";")
-		p.curScope.addTypeDef(id, t)
-		// returned t is nil when it is types.Typepedef (no need to add to scope.vars)
-		return nil, "", nil, sc
+	for {
+		id, ty := p.tyDecl(t)
+		if id == "" {
+			log.Fatalf("Identifier was expected but got %s", p.Toks[0].Str())
+		}
+		a := *attrs
+		p.attributes(&a)
+		if isTypeDef {
+			if types.IsVariablyModified(ty) {
+				log.Fatalf("Typedef of variably modified type %s is not supported", id)
+			}
+			if a.align > 0 {
+				ty = types.Aligned(ty, a.align)
+			}
+			p.curScope.addTypeDef(id, ty)
+		} else {
+			checkAlignAs(id, ty, &a)
+			// the length of an array may be given by its initializer or, at file scope, by the completion of a tentative definition.
+			if (sc&extern) == 0 && types.IsIncomplete(ty) && !isUnsized(ty) {
+				log.Fatalf("Variable %s has incomplete type", id)
+			}
+			var rhs ast.Node
+			if (sc&extern) == 0 && p.consume("=") {
+				rhs = p.initializer(ty, sc)
+			}
+			register(ty, id, rhs, &a)
+		}
+		if p.consume(";") {
+			return
+		}
+		p.expect(",")
 	}
-	t = p.tySuffix(t)
-	if p.consume(";") {
+}
+
+// initializer reads the initializer of an object of type t.
+// The initializer of an array, struct or union is a BlkNode holding the initializer of each element or member,
+// where nil means that the element or member is zero-initialized.
+func (p *Parser) initializer(t types.Type, sc storageClass) ast.Node {
+	switch t.(type) {
+	case *types.Arr, *types.Struct, *types.Union:
+	default:
+		// braces around a scalar initializer are allowed, e.g) int x = {3};
+		braced := p.consume("{")
+		node := p.assign()
+		if braced {
+			p.consume(",")
+			p.expect("}")
+		}
+		warnDiscardedQuals(t, node.LoadType())
+		return node
+	}
+	if strTok, ok := p.consumeStr(); ok {
+		arr, isArr := t.(*types.Arr)
+		if !isArr {
+			log.Fatal("Struct or union initialized from string literal")
+		}
+		if of := arr.Of; !types.IsInteger(of) || of.Size() != strTok.ElemSize() {
+			log.Fatal("Array initialized from incompatible string literal")
+		}
+		// only the content of the string is used to initialize the array.
+		init := vars.NewGVarInitStr(strTok.Units(), strTok.ElemSize())
+		s := vars.NewGVar(false, newGVarLabel(), types.NewArr(strElemType(strTok), strTok.Len()), init)
+		s.IsStrLit = true
+		return ast.NewVarNode(s)
+	}
+	if !p.beginsWith("{") {
+		if _, ok := t.(*types.Arr); ok {
+			log.Fatal("Array must be initialized with a brace-enclosed initializer")
+		}
+		// initialized by an expression of the struct or union type, e.g) struct foo x = y;
+		return p.assign()
+	}
+	p.expect("{")
+	blk := ast.NewBlkNode(initElems(t))
+	p.initList(t, blk, sc, true)
+	if arr, ok := t.(*types.Arr); ok && arr.Len < 0 {
+		arr.Len = len(blk.Body)
+	}
+	return blk
+}
+
+// initList reads the initializers of the elements or members of t into blk.
+// When braced is false, the braces of a nested aggregate are omitted,
+// so the list ends as soon as every element of t is initialized, or at a designator belonging to the enclosing list.
+func (p *Parser) initList(t types.Type, blk *ast.BlkNode, sc storageClass, braced bool) {
+	if !braced {
+		if isInitFull(t, 0) {
+			log.Fatal("Excess elements in initializer")
+		}
+		p.initElem(t, blk, 0, sc)
+		p.initRest(t, blk, 1, sc)
 		return
 	}
-	if (sc & extern) != 0 {
-		p.expect(";")
+	idx := 0
+	for i := 0; ; i++ {
+		if p.consume("}") {
+			return
+		}
+		if i > 0 {
+			p.expect(",")
+			if p.consume("}") {
+				return
+			}
+		}
+		if p.beginsWith(".") || p.beginsWith("[") {
+			idx = p.designation(t, blk, sc)
+			continue
+		}
+		if isInitFull(t, idx) {
+			log.Fatal("Excess elements in initializer")
+		}
+		p.initElem(t, blk, idx, sc)
+		idx++
+	}
+}
+
+// initRest reads the initializers of the elements or members of t from idx into blk, whose braces are omitted.
+// It stops when t is fully initialized, or before the "," followed by "}" or a designator belonging to the enclosing list.
+func (p *Parser) initRest(t types.Type, blk *ast.BlkNode, idx int, sc storageClass) {
+	for !isInitFull(t, idx) && p.beginsWith(",") {
+		orig := p.Toks
+		p.expect(",")
+		if p.beginsWith("}") || p.beginsWith(".") || p.beginsWith("[") {
+			p.Toks = orig
+			return
+		}
+		p.initElem(t, blk, idx, sc)
+		idx++
+	}
+}
+
+// initElem reads the initializer of the idx-th element or member of t into blk.
+func (p *Parser) initElem(t types.Type, blk *ast.BlkNode, idx int, sc storageClass) {
+	elemTy := elemType(t, idx)
+	if p.isBraceElided(elemTy) {
+		child := ast.NewBlkNode(initElems(elemTy))
+		p.initList(elemTy, child, sc, false)
+		setInit(t, blk, idx, child)
 		return
 	}
-	p.expect("=")
-	rhs = p.initializer(t, sc)
-	p.expect(";")
-	return
+	setInit(t, blk, idx, p.initializer(elemTy, sc))
 }
 
-func (p *Parser) initializer(t types.Type, sc storageClass) ast.Node {
+// designation reads designators such as `.a.b[2]`, "=" and the initializer following them into blk.
+// It returns the index of the element or member following the designated one.
+// The initializers following a nested designation initialize the rest of the innermost designated aggregate first,
+// e.g) `{.a[1] = 1, 2}` initializes a[2] with 2.
+func (p *Parser) designation(t types.Type, blk *ast.BlkNode, sc storageClass) int {
+	begin, end := p.designator(t)
+	orig := p.Toks
+	for idx := begin; idx <= end; idx++ {
+		// the rest is read once for each element of a range designator, e.g) [0 ... 3] = 1
+		p.Toks = orig
+		if p.beginsWith(".") || p.beginsWith("[") {
+			elemTy := elemType(t, idx)
+			child, ok := initAt(blk, idx).(*ast.BlkNode)
+			if !ok {
+				child = ast.NewBlkNode(initElems(elemTy))
+			}
+			setInit(t, blk, idx, child)
+			p.initRest(elemTy, child, p.designation(elemTy, child, sc), sc)
+			continue
+		}
+		p.expect("=")
+		p.initElem(t, blk, idx, sc)
+	}
+	if u, ok := t.(*types.Union); ok {
+		// no more member of a union can be initialized.
+		return len(u.Members)
+	}
+	return end + 1
+}
+
+// designator reads a designator of t, and returns the range of indices it designates.
+func (p *Parser) designator(t types.Type) (begin int, end int) {
 	switch t := t.(type) {
 	case *types.Arr:
-		var nodes []ast.Node
-		if strTok, ok := p.consumeStr(); ok {
-			init := vars.NewGVarInitStr(strTok.Str())
-			s := vars.NewGVar((sc&static) != 0, newGVarLabel(), types.NewArr(types.NewChar(), strTok.Len()), init)
-			p.Ast.GVars = append(p.Ast.GVars, s)
-			return ast.NewVarNode(s)
+		p.expect("[")
+		begin = int(ast.Eval(p.ternary()))
+		end = begin
+		// GNU extension: [begin ... end]
+		if p.consume("...") {
+			end = int(ast.Eval(p.ternary()))
 		}
-		p.expect("{")
-		for !p.consume("}") {
-			nodes = append(nodes, p.initializer(t.Of, sc))
-			if !p.consume(",") {
-				p.expect("}")
-				break
-			}
+		p.expect("]")
+		if begin < 0 || end < begin || (t.Len >= 0 && end >= t.Len) {
+			log.Fatal("Array index in initializer exceeds array bounds")
 		}
-		return ast.NewBlkNode(nodes)
+		return
 	case *types.Struct:
-		nodes := make([]ast.Node, len(t.Members))
-		if strTok, ok := p.consumeStr(); ok {
-			init := vars.NewGVarInitStr(strTok.Str())
-			s := vars.NewGVar((sc&static) != 0, newGVarLabel(), types.NewArr(types.NewChar(), strTok.Len()), init)
-			p.Ast.GVars = append(p.Ast.GVars, s)
-			return ast.NewVarNode(s)
+		return p.memberDesignator(t.Members)
+	case *types.Union:
+		return p.memberDesignator(t.Members)
+	}
+	log.Fatal("Designator used for a scalar")
+	return
+}
+
+func (p *Parser) memberDesignator(members []*types.Member) (int, int) {
+	orig := p.Toks
+	p.expect(".")
+	id := p.expectID().Str()
+	for i, mem := range members {
+		if mem.Name == id {
+			return i, i
 		}
-		p.expect("{")
-		idx := 0
-		for !p.consume("}") {
-			if p.consume(".") {
-				id := p.expectID().Str()
-				p.expect("=")
-				for i, mem := range t.Members[idx:] {
-					if id == mem.Name {
-						idx += i
-						break
-					}
-				}
-			}
-			nodes[idx] = p.initializer(t.Members[idx].Type, sc)
-			if !p.consume(",") {
-				p.expect("}")
-				break
-			}
-			idx++
+		// a member of an anonymous struct or union is designated through it, so the designator is read again for it.
+		if c, ok := mem.Type.(types.Composite); ok && mem.Name == "" && c.FindMember(id) != nil {
+			p.Toks = orig
+			return i, i
 		}
-		return ast.NewBlkNode(nodes)
-	default:
-		return p.assign()
 	}
+	log.Fatalf("No such member %s", id)
+	return 0, 0
+}
+
+// isBraceElided reports whether the braces around the initializer of the aggregate type t are omitted.
+func (p *Parser) isBraceElided(t types.Type) bool {
+	if p.beginsWith("{") {
+		return false
+	}
+	switch t := t.(type) {
+	case *types.Arr:
+		_, isStr := p.Toks[0].(*tokenizer.StrTok)
+		_, isChar := t.Of.(*types.Char)
+		return !isStr || !isChar
+	case *types.Struct, *types.Union:
+		// a struct or union may be initialized by an expression of its type.
+		orig := p.Toks
+		node := p.assign()
+		p.Toks = orig
+		_, ok := node.LoadType().(types.Composite)
+		return !ok
+	}
+	return false
+}
+
+// initElems returns the initializers of the elements or members of t, none of which are initialized yet.
+func initElems(t types.Type) []ast.Node {
+	switch t := t.(type) {
+	case *types.Arr:
+		if t.Len < 0 {
+			return nil
+		}
+		return make([]ast.Node, t.Len)
+	case *types.Struct:
+		return make([]ast.Node, len(t.Members))
+	case *types.Union:
+		return make([]ast.Node, len(t.Members))
+	}
+	return nil
+}
+
+func elemType(t types.Type, idx int) types.Type {
+	switch t := t.(type) {
+	case *types.Arr:
+		return t.Of
+	case *types.Struct:
+		return t.Members[idx].Type
+	case *types.Union:
+		return t.Members[idx].Type
+	}
+	log.Fatalf("Unhandled type in initializer: %T", t)
+	return nil
+}
+
+// isInitFull reports whether t has no element or member at idx to initialize without a designator.
+func isInitFull(t types.Type, idx int) bool {
+	switch t := t.(type) {
+	case *types.Arr:
+		return t.Len >= 0 && idx >= t.Len
+	case *types.Struct:
+		return idx >= len(t.Members)
+	case *types.Union:
+		// only the first member is initialized without a designator.
+		return idx >= 1
+	}
+	return true
 }
 
-func (p *Parser) baseType() (t types.Type, isTypeDef bool, sc storageClass) {
+func initAt(blk *ast.BlkNode, idx int) ast.Node {
+	if idx < len(blk.Body) {
+		return blk.Body[idx]
+	}
+	return nil
+}
+
+// setInit sets the initializer of the idx-th element or member of t, overriding the earlier one.
+func setInit(t types.Type, blk *ast.BlkNode, idx int, init ast.Node) {
+	for len(blk.Body) <= idx {
+		blk.Body = append(blk.Body, nil)
+	}
+	if _, ok := t.(*types.Union); ok {
+		// only the member initialized last has a value.
+		for i := range blk.Body {
+			blk.Body[i] = nil
+		}
+	}
+	blk.Body[idx] = init
+}
+
+// baseType reads the declaration specifiers.
+func (p *Parser) baseType() (t types.Type, isTypeDef bool, sc storageClass, attrs *declAttrs) {
+	attrs = &declAttrs{}
+	p.attributes(attrs)
+	q := p.qualifiers()
+	align := p.alignAs(0)
+	p.attributes(attrs)
 	if p.consume("typedef") {
 		isTypeDef = true
 	}
+	sc = p.threadLocal()
 	if p.consume("static") {
 		sc |= static
 	}
 	if p.consume("extern") {
 		sc |= extern
 	}
-	if isTypeDef && (sc != 0) || (sc == 0b11) {
+	sc |= p.threadLocal()
+	if isTypeDef && (sc&threadLocal) != 0 {
+		log.Fatal("_Thread_local can not be used in typedef")
+	}
+	if isTypeDef && (sc != 0) || (sc&(static|extern)) == static|extern {
 		log.Fatal("typedef, static and extern should not be used together.")
 	}
+	p.attributes(attrs)
+	q = q.Merge(p.qualifiers())
+	align = p.alignAs(align)
+	p.attributes(attrs)
+	t = p.typeSpecifier()
+	p.attributes(attrs)
+	q = q.Merge(p.qualifiers())
+	align = p.alignAs(align)
+	p.attributes(attrs)
+	if isTypeDef && align > 0 {
+		log.Fatal("_Alignas can not be used in typedef")
+	}
+	if align > attrs.align {
+		attrs.align = align
+	}
+	attrs.alignas = align
+	return types.Qualify(t, q), isTypeDef, sc, attrs
+}
+
+// threadLocal reads the _Thread_local storage class specifier, which GNU C spells __thread, and returns it.
+func (p *Parser) threadLocal() storageClass {
+	if p.consume("_Thread_local") || p.consume("thread_local") || p.consume("__thread") {
+		return threadLocal
+	}
+	return 0
+}
+
+// alignAs reads _Alignas specifiers, and returns the strictest alignment among them and align.
+func (p *Parser) alignAs(align int) int {
+	for p.consume("_Alignas") || p.consume("alignas") {
+		p.expect("(")
+		var a int
+		if p.isType() {
+			a = p.typeName().Alignment()
+		} else {
+			a = int(p.constExpr())
+		}
+		p.expect(")")
+		checkAlignment(a)
+		if align < a {
+			align = a
+		}
+	}
+	return align
+}
+
+// checkAlignment checks that the alignment a requested by _Alignas or aligned is a power of 2.
+func checkAlignment(a int) {
+	if a < 0 || a&(a-1) != 0 {
+		log.Fatalf("Requested alignment %d is not a power of 2", a)
+	}
+}
+
+func (p *Parser) typeSpecifier() types.Type {
 	switch tok := p.Toks[0].(type) {
 	case *tokenizer.IDTok:
 		if typeDef, ok := p.searchVar(tok.Str()).(*vars.TypeDef); ok {
 			p.popToks()
-			return typeDef.Type(), isTypeDef, sc
+			return typeDef.Type()
 		}
 	case *tokenizer.ReservedTok:
-		if p.beginsWith("struct") {
-			return p.structDecl(), isTypeDef, sc
+		if t, ok := p.typeOf(); ok {
+			return t
+		}
+		if p.isAtomicSpecifier() {
+			return p.atomicSpecifier()
+		}
+		if p.beginsWith("struct") || p.beginsWith("union") {
+			return p.structDecl()
 		}
 		if p.beginsWith("enum") {
-			return p.enumDecl(), isTypeDef, sc
+			return p.enumDecl()
 		}
 		if p.consume("void") {
-			return types.NewVoid(), isTypeDef, sc
+			return types.NewVoid()
 		}
 		if p.consume("_Bool") {
-			return types.NewBool(), isTypeDef, sc
+			return types.NewBool()
+		}
+		isUnsigned := p.consume("unsigned")
+		if !isUnsigned {
+			p.consume("signed")
 		}
-		// just skip `volatile` keyword since there is no optimisation yet.
-		p.consume("volatile")
-		// TODO: handle unsigned properly
-		p.consume("unsigned")
-		p.consume("signed")
 		if p.consume("char") {
-			return types.NewChar(), isTypeDef, sc
+			if isUnsigned {
+				return types.NewUChar()
+			}
+			return types.NewChar()
 		}
 		if p.consume("short") {
 			p.consume("int")
-			return types.NewShort(), isTypeDef, sc
-		}
-		if p.consume("int") {
-			return types.NewInt(), isTypeDef, sc
+			if isUnsigned {
+				return types.NewUShort()
+			}
+			return types.NewShort()
 		}
 		if p.consume("long") {
 			p.consume("long")
 			p.consume("int")
-			return types.NewLong(), isTypeDef, sc
+			if isUnsigned {
+				return types.NewULong()
+			}
+			return types.NewLong()
+		}
+		p.consume("int")
+		if isUnsigned {
+			return types.NewUInt()
 		}
-		return types.NewInt(), isTypeDef, sc
+		return types.NewInt()
 	}
 	log.Fatalf("Type expected but got %T: %s", p.Toks[0], p.Toks[0].Str())
-	return
+	return nil
 }
 
-func (p *Parser) tyDecl(baseTy types.Type) (id string, ty types.Type) {
-	for p.consume("*") {
-		baseTy = types.NewPtr(baseTy)
+// typeOf reads `typeof(expr)` or `typeof(typeName)`, where the expression is not evaluated.
+// typeof_unqual drops the qualifiers of the type. ok is false when the next token is not typeof.
+func (p *Parser) typeOf() (t types.Type, ok bool) {
+	unqual := false
+	switch {
+	case p.consume("typeof"), p.consume("__typeof__"), p.consume("__typeof"):
+	case p.consume("typeof_unqual"), p.consume("__typeof_unqual__"), p.consume("__typeof_unqual"):
+		unqual = true
+	default:
+		return nil, false
 	}
-	if p.consume("(") {
-		id, ty = p.tyDecl(nil)
-		p.expect(")")
-		baseTy = p.tySuffix(baseTy)
-		switch ty := ty.(type) {
-		case *types.Arr:
-			ty.Of = baseTy
-		case *types.Ptr:
-			ty.To = baseTy
+	p.expect("(")
+	if p.isType() {
+		t = p.typeName()
+	} else {
+		t = p.expr().LoadType()
+	}
+	p.expect(")")
+	if unqual {
+		t = types.Unqualified(t)
+	}
+	return t, true
+}
+
+// qualifiers reads a sequence of type qualifiers.
+func (p *Parser) qualifiers() (q types.Quals) {
+	for {
+		switch {
+		case p.consume("const"):
+			q.IsConst = true
+		case p.consume("volatile"):
+			q.IsVolatile = true
+		case p.consume("restrict"), p.consume("__restrict"), p.consume("__restrict__"):
+			q.IsRestrict = true
+		case !p.isAtomicSpecifier() && p.consume("_Atomic"):
+			q.IsAtomic = true
 		default:
-			ty = baseTy
+			return
+		}
+	}
+}
+
+// isAtomicSpecifier reports whether the next tokens are `_Atomic(`, which begin the type specifier rather than the qualifier.
+func (p *Parser) isAtomicSpecifier() bool {
+	orig := p.Toks
+	defer func() { p.Toks = orig }()
+	return p.consume("_Atomic") && p.consume("(")
+}
+
+// atomicSpecifier reads `_Atomic(typeName)`, which denotes the atomic version of the type.
+func (p *Parser) atomicSpecifier() types.Type {
+	p.expect("_Atomic")
+	p.expect("(")
+	t := p.typeName()
+	p.expect(")")
+	switch t.(type) {
+	case *types.Arr, *types.VLA, *types.Fn:
+		log.Fatalf("_Atomic can not be applied to type %T", t)
+	}
+	if !types.QualsOf(t).IsZero() {
+		log.Fatal("_Atomic can not be applied to qualified type")
+	}
+	return types.Qualify(t, types.Quals{IsAtomic: true})
+}
+
+// pointers reads `*` each of which may be followed by qualifiers and attributes, e.g) `* const *`.
+func (p *Parser) pointers(t types.Type) types.Type {
+	for p.consume("*") {
+		attrs := &declAttrs{}
+		p.attributes(attrs)
+		q := p.qualifiers()
+		p.attributes(attrs)
+		t = types.Qualify(types.NewPtr(t), q)
+		if attrs.align > 0 {
+			t = types.Aligned(t, attrs.align)
 		}
+	}
+	return t
+}
+
+func (p *Parser) tyDecl(baseTy types.Type) (id string, ty types.Type) {
+	baseTy = p.pointers(baseTy)
+	if p.isNestedDecl() {
+		// e.g) int (*x)[3], int (*fp)(int)
+		// The suffix after the parenthesis applies first, so skip the nested declarator,
+		// read the suffix, and then come back to read the nested one with the resulting type.
+		p.expect("(")
+		nested := p.Toks
+		p.skipParen()
+		baseTy = p.tySuffix(baseTy)
+		rest := p.Toks
+		p.Toks = nested
+		id, ty = p.tyDecl(baseTy)
+		p.expect(")")
+		p.Toks = rest
 		return
 	}
-	return p.expectID().Str(), p.tySuffix(baseTy)
+	// the identifier is omitted in abstract declarators such as function parameters of prototypes.
+	if tok, ok := p.consumeID(); ok {
+		id = tok.Str()
+	}
+	return id, p.tySuffix(baseTy)
+}
+
+// typeName reads a type name, which is a declaration without an identifier, e.g) `int (*)[4]`.
+func (p *Parser) typeName() types.Type {
+	t, isTypeDef, sc, attrs := p.baseType()
+	if isTypeDef || sc != 0 {
+		log.Fatal("Storage class specifier in type name")
+	}
+	if attrs.align > 0 {
+		log.Fatal("_Alignas in type name")
+	}
+	id, t := p.tyDecl(t)
+	if id != "" {
+		log.Fatalf("Unexpected identifier %s in type name", id)
+	}
+	return t
+}
+
+// isNestedDecl reports whether the next "(" begins a nested declarator rather than a parameter list.
+func (p *Parser) isNestedDecl() bool {
+	if !p.beginsWith("(") {
+		return false
+	}
+	orig := p.Toks
+	defer func() { p.Toks = orig }()
+	p.popToks()
+	return !p.isType() && !p.beginsWith(")") && !p.beginsWith("...")
+}
+
+// skipParen skips tokens until the ")" which closes the already consumed "(".
+func (p *Parser) skipParen() {
+	for level := 1; level > 0; p.popToks() {
+		if p.isEOF() {
+			log.Fatal("Unclosed parenthesis in declarator")
+		}
+		if p.beginsWith("(") {
+			level++
+		} else if p.beginsWith(")") {
+			level--
+		}
+	}
 }
 
 func (p *Parser) tySuffix(t types.Type) types.Type {
+	if p.consume("(") {
+		// the parameters are declared in a scope of their own, since a VLA parameter may refer to the preceding ones.
+		sc := p.curScope
+		p.spawnScope()
+		names, fnTy := p.fnParams(t)
+		p.paramScope, p.paramNames = p.curScope, names
+		p.curScope = sc
+		return fnTy
+	}
 	if !p.consume("[") {
 		return t
 	}
 	l := -1
+	var vlaLen ast.Node
 	if !p.consume("]") {
-		l = int(p.constExpr())
+		n := p.assign()
+		if v, err := ast.TryEval(n); err == nil {
+			l = int(v)
+			if l < 0 {
+				log.Fatal("Size of array is negative")
+			}
+		} else if p.curScope.super == nil {
+			// VLAs can not have static storage duration.
+			log.Fatal(err)
+		} else {
+			vlaLen = n
+		}
 		p.expect("]")
 	}
 	t = p.tySuffix(t)
+	_, isVLA := t.(*types.VLA)
+	if sz := t.Size(); !isVLA && sz > 0 && sz%t.Alignment() != 0 {
+		// the elements are placed without padding, e.g) of a typedef with the aligned attribute.
+		log.Fatal("Alignment of array elements is greater than element size")
+	}
+	if isVLA && vlaLen == nil && l >= 0 {
+		// an array of VLAs is a VLA as well.
+		vlaLen = ast.NewNumNode(int64(l))
+	}
+	if vlaLen != nil {
+		return types.NewVLA(t, vlaLen, p.newTmpLVar(types.NewULong()))
+	}
 	return types.NewArr(t, l)
 }
 
@@ -368,28 +1120,50 @@ func (p *Parser) constExpr() int64 {
 	return ast.Eval(p.ternary())
 }
 
-func (p *Parser) readFnParams(fn *ast.FnNode) {
-	p.expect("(")
-	isFirstArg := true
+// fnParams reads the parameter list after "(" and returns the names of the parameters and the type of the function returning ret.
+// Names are empty for parameters declared without an identifier.
+// Each named parameter is declared in the current scope as soon as it is read.
+// Empty parentheses declare a function without prototype.
+func (p *Parser) fnParams(ret types.Type) (names []string, fnTy *types.Fn) {
+	fnTy = types.NewFn(ret, nil, false, false)
+	if p.consume(")") {
+		return
+	}
+	fnTy.HasProto = true
 	orig := p.Toks
 	if p.consume("void") && p.consume(")") {
 		return
 	}
 	p.Toks = orig
 	for !p.consume(")") {
-		if !isFirstArg {
+		if len(fnTy.Params) > 0 {
 			p.expect(",")
 		}
-		isFirstArg = false
+		if p.consume("...") {
+			fnTy.IsVariadic = true
+			p.expect(")")
+			return
+		}
 
-		ty, _, _ := p.baseType()
+		ty, _, _, attrs := p.baseType()
 		id, ty := p.tyDecl(ty)
-		if arr, ok := ty.(*types.Arr); ok {
-			ty = types.NewPtr(arr.Of)
+		p.attributes(attrs)
+		switch t := ty.(type) {
+		case *types.Arr:
+			ty = types.NewPtr(t.Of)
+		case *types.VLA:
+			ty = types.NewPtr(t.Of)
+		case *types.Fn:
+			ty = types.NewPtr(t)
 		}
-		lv := p.curScope.addLVar(id, ty)
-		fn.Params = append(fn.Params, lv)
+		if id != "" {
+			lv := p.curScope.addLVar(id, ty)
+			p.declareAttrs(lv, attrs)
+		}
+		names = append(names, id)
+		fnTy.Params = append(fnTy.Params, ty)
 	}
+	return
 }
 
 func (p *Parser) setFnLVars(fn *ast.FnNode) {
@@ -397,63 +1171,332 @@ func (p *Parser) setFnLVars(fn *ast.FnNode) {
 	for _, sv := range p.curScope.vars {
 		switch v := sv.(type) {
 		case *vars.LVar:
-			offset = types.AlignTo(offset, v.Type().Alignment())
-			offset += v.Type().Size()
+			// the end of the variable is aligned, since it is placed at rbp-offset.
+			offset = types.AlignTo(offset+v.Type().Size(), v.Alignment())
 			v.Offset = offset
 			fn.LVars = append(fn.LVars, v)
 		}
 	}
 }
 
+// structDecl reads a struct or union specifier. Both share the same tag namespace.
+// A tag which is referred before its definition denotes an incomplete type,
+// which is completed in place when the definition appears in the same scope.
 func (p *Parser) structDecl() types.Type {
-	p.expect("struct")
-	tag, tagExists := p.consumeID()
+	isUnion := p.consume("union")
+	if !isUnion {
+		p.expect("struct")
+	}
+	attrs := &declAttrs{}
+	p.attributes(attrs)
+	tagTok, tagExists := p.consumeID()
 	if tagExists && !p.beginsWith("{") {
-		if tag := p.searchStructTag(tag.Str()); tag != nil {
-			return tag.ty
+		name := tagTok.Str()
+		var tag *structTag
+		if p.beginsWith(";") {
+			// `struct foo;` declares a new tag in the current scope.
+			tag = p.curScope.searchStructTag(name)
+		} else {
+			tag = p.searchStructTag(name)
 		}
-		log.Fatalf("No such struct tag %s", tag.Str())
+		if tag == nil {
+			tag = newStructTag(name, newIncompleteRecord(isUnion))
+			p.curScope.addStructTag(tag)
+		}
+		checkTagKind(tag, isUnion)
+		return tag.ty
 	}
 	p.expect("{")
+	var ty types.Type
+	name := "(anonymous)"
+	if tagExists {
+		name = tagTok.Str()
+		// register the tag before reading the members so that they can refer to it.
+		tag := p.curScope.searchStructTag(name)
+		if tag == nil {
+			tag = newStructTag(name, newIncompleteRecord(isUnion))
+			p.curScope.addStructTag(tag)
+		}
+		checkTagKind(tag, isUnion)
+		if !types.IsIncomplete(tag.ty) {
+			log.Fatalf("Redefinition of %s", name)
+		}
+		ty = tag.ty
+	}
+	if isUnion {
+		u := p.unionMembers(attrs)
+		if t, ok := ty.(*types.Union); ok {
+			t.Complete(u)
+		} else {
+			ty = u
+		}
+		name = "union " + name
+	} else {
+		s := p.structMembers(attrs)
+		if t, ok := ty.(*types.Struct); ok {
+			t.Complete(s)
+		} else {
+			ty = s
+		}
+		name = "struct " + name
+	}
+	if p.DumpRecordLayouts {
+		types.DumpRecordLayout(os.Stderr, name, ty.(types.Composite))
+	}
+	return ty
+}
+
+func newIncompleteRecord(isUnion bool) types.Type {
+	if isUnion {
+		return types.NewIncompleteUnion()
+	}
+	return types.NewIncompleteStruct()
+}
+
+func checkTagKind(tag *structTag, isUnion bool) {
+	if _, ok := tag.ty.(*types.Union); ok != isUnion {
+		kind := "struct"
+		if isUnion {
+			kind = "union"
+		}
+		log.Fatalf("%s is not a %s tag", tag.name, kind)
+	}
+}
+
+// structMembers reads the members of a struct and lays them out.
+func (p *Parser) structMembers(attrs *declAttrs) *types.Struct {
+	// "{" is already read.
+	decls := p.memberDecls(false)
+	p.attributes(attrs)
+	var members []*types.Member
+	// the layout is computed in bits to place bit-fields.
+	// unitEnd is the end of the storage units of the bit-fields, which may exceed the bits of a packed struct.
+	bits, unitEnd, align := 0, 0, 1
+	for _, d := range decls {
+		d.packed = d.packed || attrs.packed
+		ty, tag, width := d.ty, d.id, d.width
+		if width < 0 {
+			bits = types.AlignTo(bits, d.alignment()*8)
+			members = append(members, types.NewMember(tag, bits/8, ty))
+			bits += ty.Size() * 8
+			if align < d.alignment() {
+				align = d.alignment()
+			}
+			continue
+		}
+		unit := ty.Size() * 8
+		if width == 0 {
+			// zero-width bit-field makes the next member start at the next storage unit.
+			bits = types.AlignTo(bits, unit)
+			continue
+		}
+		// a bit-field never straddles the boundary of its storage unit, even in a packed struct.
+		if bits/unit != (bits+width-1)/unit {
+			bits = types.AlignTo(bits, unit)
+		}
+		// unnamed bit-fields are padding and affect neither the members nor the alignment.
+		if tag != "" {
+			offset := bits / unit * ty.Size()
+			members = append(members, types.NewBitField(tag, offset, ty, bits-offset*8, width))
+			if align < d.alignment() {
+				align = d.alignment()
+			}
+			if unitEnd < offset*8+unit {
+				unitEnd = offset*8 + unit
+			}
+		}
+		bits += width
+	}
+	if align < attrs.align {
+		align = attrs.align
+	}
+	if bits < unitEnd {
+		bits = unitEnd
+	}
+	return types.NewStruct(align, members, types.AlignTo(types.AlignTo(bits, 8)/8, align))
+}
+
+// unionMembers reads the members of a union and lays them out.
+func (p *Parser) unionMembers(attrs *declAttrs) *types.Union {
+	// "{" is already read.
+	decls := p.memberDecls(true)
+	p.attributes(attrs)
 	var members []*types.Member
-	offset, align := 0, 0
+	size, align := 0, 1
+	for _, d := range decls {
+		d.packed = d.packed || attrs.packed
+		ty, tag, width := d.ty, d.id, d.width
+		sz := ty.Size()
+		if width >= 0 {
+			if width == 0 || tag == "" {
+				continue
+			}
+			sz = (width + 7) / 8
+		}
+		// every member of a union is placed at offset 0.
+		if width < 0 {
+			members = append(members, types.NewMember(tag, 0, ty))
+		} else {
+			members = append(members, types.NewBitField(tag, 0, ty, 0, width))
+		}
+		if size < sz {
+			size = sz
+		}
+		if align < d.alignment() {
+			align = d.alignment()
+		}
+	}
+	if align < attrs.align {
+		align = attrs.align
+	}
+	return types.NewUnion(align, members, types.AlignTo(size, align))
+}
+
+// memberDecls reads the member declarations of a struct or union until the closing brace.
+func (p *Parser) memberDecls(isUnion bool) (decls []memberDeclarator) {
 	for !p.consume("}") {
-		// TODO: handle when rhs is not null
-		ty, tag, _, _ := p.decl()
-		offset = types.AlignTo(offset, ty.Alignment())
-		members = append(members, types.NewMember(tag, offset, ty))
-		offset += ty.Size()
-		if align < ty.Size() {
-			align = ty.Size()
+		if p.staticAssert() {
+			continue
+		}
+		ds := p.memberDecl()
+		for i, d := range ds {
+			if arr, ok := d.ty.(*types.Arr); ok && arr.Len < 0 && d.width < 0 && !isUnion {
+				// flexible array member occupies no space.
+				if i < len(ds)-1 || !p.beginsWith("}") {
+					log.Fatalf("Flexible array member %s is not at the end of struct", d.id)
+				}
+				arr.Len = 0
+			}
 		}
+		decls = append(decls, ds...)
 	}
-	ty := types.NewStruct(align, members, types.AlignTo(offset, align))
-	if tagExists {
-		p.curScope.addStructTag(newStructTag(tag.Str(), ty))
+	return
+}
+
+// memberDeclarator is a declarator in a member declaration of struct or union.
+// width is the width of the bit-field, or -1 when the member is not a bit-field.
+type memberDeclarator struct {
+	ty    types.Type
+	id    string
+	width int
+	// align is the alignment specified by _Alignas or aligned, or 0.
+	align int
+	// packed is set for a member of the smallest alignment, unless align is specified.
+	packed bool
+}
+
+// alignment returns the alignment of the member, which attributes may change from that of its type.
+func (d memberDeclarator) alignment() int {
+	a := d.ty.Alignment()
+	if d.packed {
+		a = 1
+	}
+	if d.align > a {
+		return d.align
+	}
+	return a
+}
+
+// memberDecl reads a member declaration of struct or union, e.g) `int a, *b, c:3;`.
+func (p *Parser) memberDecl() (decls []memberDeclarator) {
+	base, _, _, attrs := p.baseType()
+	if p.consume(";") {
+		// anonymous struct or union member
+		return []memberDeclarator{{base, "", -1, attrs.align, attrs.packed}}
+	}
+	for {
+		id, ty := p.tyDecl(base)
+		a := *attrs
+		p.attributes(&a)
+		width := -1
+		if p.consume(":") {
+			width = int(p.constExpr())
+			if width < 0 || width > ty.Size()*8 {
+				log.Fatalf("Invalid width of bit-field %s: %d", id, width)
+			}
+			if width == 0 && id != "" {
+				log.Fatalf("Zero-width bit-field %s must be unnamed", id)
+			}
+			p.attributes(&a)
+		} else if id == "" {
+			log.Fatalf("Member name was expected but got %s", p.Toks[0].Str())
+		}
+		// flexible array members are checked by memberDecls.
+		if types.IsIncomplete(ty) && !isUnsized(ty) {
+			log.Fatalf("Member %s has incomplete type", id)
+		}
+		if types.IsVariablyModified(ty) {
+			log.Fatalf("Member %s has variably modified type", id)
+		}
+		if width >= 0 && a.align > 0 {
+			log.Fatalf("_Alignas can not be used for bit-field %s", id)
+		}
+		checkAlignAs(id, ty, &a)
+		decls = append(decls, memberDeclarator{ty, id, width, a.align, a.packed})
+		if p.consume(";") {
+			return
+		}
+		p.expect(",")
 	}
-	return ty
 }
 
 func (p *Parser) enumDecl() types.Type {
 	p.expect("enum")
+	attrs := &declAttrs{}
+	p.attributes(attrs)
 	tag, tagExists := p.consumeID()
+	var base types.Type
+	if p.consume(":") {
+		// C23 enum with the fixed underlying type
+		base = types.Unqualified(p.typeName())
+		if _, ok := base.(*types.Enum); ok || !types.IsInteger(base) {
+			log.Fatal("Underlying type of enum must be an integer type other than enum")
+		}
+	}
 	if tagExists && !p.beginsWith("{") {
 		if tag := p.searchEnumTag(tag.Str()); tag != nil {
 			return tag.ty
 		}
 		log.Fatalf("No such enum tag %s", tag.Str())
 	}
-	t := types.NewEnum()
+	var t *types.Enum
+	if base != nil {
+		t = types.NewEnum(base, true)
+	} else {
+		// the underlying type is decided by the values of the constants after the list.
+		t = types.NewEnum(types.NewUInt(), false)
+	}
 
 	p.expect("{")
-	c := 0
+	var consts []*vars.Enum
+	var val int64
+	isUnsigned := false
 	for {
 		id := p.expectID()
 		if p.consume("=") {
-			c = int(p.constExpr())
+			node := p.ternary()
+			val = ast.Eval(node)
+			isUnsigned = types.IsUnsigned(node.LoadType())
+		}
+		var ty types.Type
+		switch {
+		case t.IsFixed:
+			if !fitsIn(val, isUnsigned, base) {
+				log.Fatalf("Value of enumerator %s is out of the range of its underlying type", id.Str())
+			}
+			ty = t
+		case fitsIn(val, isUnsigned, types.NewInt()):
+			ty = types.NewInt()
+		case isUnsigned:
+			ty = types.NewULong()
+		default:
+			ty = types.NewLong()
 		}
-		p.curScope.addEnum(id.Str(), t, c)
-		c++
+		consts = append(consts, p.curScope.addEnum(id.Str(), ty, val))
+		if !isUnsigned && val == math.MaxInt64 || isUnsigned && val == -1 {
+			log.Fatalf("Overflow in the value of the enumerator following %s", id.Str())
+		}
+		val++
 		orig := p.Toks
 		if p.consume("}") || p.consume(",") && p.consume("}") {
 			break
@@ -461,44 +1504,136 @@ func (p *Parser) enumDecl() types.Type {
 		p.Toks = orig
 		p.expect(",")
 	}
+	p.attributes(attrs)
+	if attrs.align > 0 {
+		log.Printf("warning: 'aligned' attribute ignored on enum")
+	}
+	if !t.IsFixed {
+		t.Base = enumBase(consts, attrs.packed)
+		for _, c := range consts {
+			// the constants which int can not represent have the enum type after the list.
+			if _, ok := c.Type().(*types.Int); !ok {
+				c.SetType(t)
+			}
+		}
+	}
 	if tagExists {
 		p.curScope.addEnumTag(newEnumTag(tag.Str(), t))
 	}
 	return t
 }
 
+// enumBase returns the underlying type of an enum without fixed one, which can represent the values of all its constants.
+// Like GCC, it is unsigned unless some constant is negative, and a packed enum has the smallest such type.
+func enumBase(consts []*vars.Enum, packed bool) types.Type {
+	hasNeg := false
+	for _, c := range consts {
+		hasNeg = hasNeg || !types.IsUnsigned(c.Type()) && c.Val < 0
+	}
+	candidates := []types.Type{types.NewUChar(), types.NewUShort(), types.NewUInt(), types.NewULong()}
+	if hasNeg {
+		candidates = []types.Type{types.NewChar(), types.NewShort(), types.NewInt(), types.NewLong()}
+	}
+	if !packed {
+		candidates = candidates[2:]
+	}
+	for _, t := range candidates {
+		if c := firstNotFitting(consts, t); c == nil {
+			return t
+		}
+	}
+	c := firstNotFitting(consts, types.NewLong())
+	log.Fatalf("No integer type can represent the values of enumerators including %s", c.Name())
+	return nil
+}
+
+// firstNotFitting returns the first constant whose value the type t can not represent, or nil.
+func firstNotFitting(consts []*vars.Enum, t types.Type) *vars.Enum {
+	for _, c := range consts {
+		if !fitsIn(c.Val, types.IsUnsigned(c.Type()), t) {
+			return c
+		}
+	}
+	return nil
+}
+
 func (p *Parser) stmt() ast.Node {
 	// handle block
 	if p.consume("{") {
 		var blkStmts []ast.Node
+		exit := p.exit
 		p.spawnScope()
 		for !p.consume("}") {
 			blkStmts = append(blkStmts, p.stmt())
 		}
 		p.rewindScope()
+		// the variables declared in the block are cleaned up and the VLAs are freed when the control reaches its end.
+		blkStmts = append(blkStmts, p.restoreExit(exit))
 		return ast.NewBlkNode(blkStmts)
 	}
 
+	// handle goto
+	if p.consume("goto") {
+		if p.consume("*") {
+			// GNU computed goto
+			node := ast.NewIndirectGotoNode(p.expr())
+			p.expect(";")
+			return node
+		}
+		label := p.expectID().Str()
+		p.expect(";")
+		p.labelRefs = append(p.labelRefs, label)
+		node := ast.NewGotoNode(label, p.curFnName)
+		if p.exit == nil {
+			p.exitGotos = append(p.exitGotos, exitGoto{label, nil, nil})
+			return node
+		}
+		// the scopes which are not in scope at the label are left before the jump.
+		leave := ast.NewBlkNode(nil)
+		p.exitGotos = append(p.exitGotos, exitGoto{label, p.exit, leave})
+		return ast.NewBlkNode([]ast.Node{leave, node})
+	}
+
+	// handle labeled statement
+	if p.isLabel() {
+		label := p.expectID().Str()
+		p.expect(":")
+		if p.labels[label] {
+			log.Fatalf("Duplicate label %s in %s", label, p.curFnName)
+		}
+		p.labels[label] = true
+		p.labelExits[label] = p.exit
+		if p.beginsWith("}") {
+			// a label at the end of a block labels an empty statement.
+			return ast.NewLabelNode(label, ast.NewNullNode(), p.curFnName)
+		}
+		return ast.NewLabelNode(label, p.stmt(), p.curFnName)
+	}
+
 	// handle return
 	if p.consume("return") {
+		if a, ok := p.attrs[p.linked[p.curFnName]]; ok && a.noreturn {
+			log.Printf("warning: function %s declared 'noreturn' has a 'return' statement", p.curFnName)
+		}
 		if p.consume(";") {
-			return ast.NewRetNode(nil, p.curFnName)
+			return p.ret(nil)
 		}
-		node := ast.NewRetNode(p.expr(), p.curFnName)
+		rhs := p.expr()
+		warnDiscardedQuals(p.curFn.RetTy, rhs.LoadType())
 		p.expect(";")
-		return node
+		return p.ret(rhs)
 	}
 
 	// handle break
 	if p.consume("break") {
 		p.expect(";")
-		return ast.NewBreakNode()
+		return p.leaveExit(p.breakExit, ast.NewBreakNode())
 	}
 
 	// handle continue
 	if p.consume("continue") {
 		p.expect(";")
-		return ast.NewContinueNode()
+		return p.leaveExit(p.continueExit, ast.NewContinueNode())
 	}
 
 	// handle if statement
@@ -521,13 +1656,13 @@ func (p *Parser) stmt() ast.Node {
 		p.expect("(")
 		cond := p.expr()
 		p.expect(")")
-		then := p.stmt()
+		then := p.loopBody()
 		return ast.NewWhileNode(cond, then)
 	}
 
 	// handle do-while statement
 	if p.consume("do") {
-		then := p.stmt()
+		then := p.loopBody()
 		p.expect("while")
 		p.expect("(")
 		cond := p.expr()
@@ -542,17 +1677,11 @@ func (p *Parser) stmt() ast.Node {
 
 		var init, cond, inc, then ast.Node
 
+		exit := p.exit
 		p.spawnScope()
 		if !p.consume(";") {
 			if p.isType() {
-				t, id, rhs, _ := p.decl()
-				p.curScope.addLVar(id, t)
-				if rhs == nil {
-					init = ast.NewNullNode()
-				} else {
-					a := ast.NewAssignNode(ast.NewVarNode(p.findVar(id)), rhs)
-					init = ast.NewExprNode(a)
-				}
+				init = p.localDecl()
 			} else {
 				init = ast.NewExprNode(p.expr())
 				p.expect(";")
@@ -569,9 +1698,10 @@ func (p *Parser) stmt() ast.Node {
 			p.expect(")")
 		}
 
-		then = p.stmt()
+		then = p.loopBody()
 		p.rewindScope()
-		return ast.NewForNode(init, cond, inc, then)
+		// the variables declared in the first clause are cleaned up after the loop.
+		return ast.NewBlkNode([]ast.Node{ast.NewForNode(init, cond, inc, then), p.restoreExit(exit)})
 	}
 
 	// handle switch statement
@@ -581,9 +1711,14 @@ func (p *Parser) stmt() ast.Node {
 		p.expect(")")
 		p.expect("{")
 
+		exit, prevBreak := p.exit, p.breakExit
+		p.breakExit = exit
 		var cases []*ast.CaseNode
 		var dflt *ast.CaseNode
 		for idx := 0; ; idx++ {
+			if p.exit != exit && (p.beginsWith("case") || p.beginsWith("default")) {
+				log.Fatalf("Switch jumps into the scope of %s", p.exit.what())
+			}
 			if node, isDefault := p.switchCase(idx); node == nil {
 				break
 			} else {
@@ -597,25 +1732,34 @@ func (p *Parser) stmt() ast.Node {
 			}
 		}
 		p.expect("}")
-		return ast.NewSwitchNode(e, cases, dflt)
+		p.breakExit = prevBreak
+		return ast.NewBlkNode([]ast.Node{ast.NewSwitchNode(e, cases, dflt), p.restoreExit(exit)})
+	}
+
+	if p.staticAssert() {
+		return ast.NewNullNode()
+	}
+
+	// handle asm statement
+	if p.consumeAsm() {
+		return p.asmStmt()
+	}
+
+	// handle null statement, e.g) the one labeled by `out: ;`
+	if p.consume(";") {
+		return ast.NewNullNode()
+	}
+
+	// handle null statement with attributes, e.g) __attribute__((fallthrough));
+	if p.isAttrNullStmt() {
+		p.attributes(&declAttrs{})
+		p.expect(";")
+		return ast.NewNullNode()
 	}
 
 	// handle variable definition
 	if p.isType() {
-		t, id, rhs, sc := p.decl()
-		if id == "" {
-			return ast.NewNullNode()
-		}
-		if (sc & static) != 0 {
-			init := buildGVarInit(t, rhs)
-			p.curScope.addGVar(true, id, t, init)
-			return ast.NewNullNode()
-		}
-		p.curScope.addLVar(id, t)
-		if rhs == nil {
-			return ast.NewNullNode()
-		}
-		return storeInit(t, ast.NewVarNode(p.findVar(id)), rhs)
+		return p.localDecl()
 	}
 
 	node := p.expr()
@@ -623,28 +1767,44 @@ func (p *Parser) stmt() ast.Node {
 	return ast.NewExprNode(node)
 }
 
+// loopBody reads the body of a loop, where break and continue leave the scopes of the variables declared in it.
+func (p *Parser) loopBody() ast.Node {
+	prevBreak, prevContinue := p.breakExit, p.continueExit
+	p.breakExit, p.continueExit = p.exit, p.exit
+	body := p.stmt()
+	p.breakExit, p.continueExit = prevBreak, prevContinue
+	return body
+}
+
 func storeInit(t types.Type, dst ast.AddressableNode, rhs ast.Node) ast.Node {
 	switch t := t.(type) {
 	case *types.Arr:
 		// TODO: clean up
 		var body []ast.Node
 		var ln, idx int
-		_, isChar := t.Base().(*types.Char)
-		isString, str := isStrNode(rhs)
 		// string literal
-		if isChar && isString {
-			for i, r := range str {
+		if s, ok := isStrNode(rhs); ok {
+			units := s.Units
+			if t.Len >= 0 && t.Len < len(units) {
+				// the terminating null character is dropped when the array has no room for it.
+				units = units[:t.Len]
+			}
+			for i, u := range units {
 				idx++
 				addr := ast.NewDerefNode(ast.NewAddNode(dst, ast.NewNumNode(int64(i))))
-				body = append(body, ast.NewExprNode(ast.NewAssignNode(addr, ast.NewNumNode(int64(r)))))
+				body = append(body, ast.NewExprNode(ast.NewAssignNode(addr, ast.NewNumNode(int64(u)))))
 			}
-			ln = len(str)
+			ln = len(s.Units)
 		} else {
 			blkBody := rhs.(*ast.BlkNode).Body
 			for i, mem := range blkBody {
 				idx++
 				addr := ast.NewDerefNode(ast.NewAddNode(dst, ast.NewNumNode(int64(i))))
-				body = append(body, storeInit(t.Base(), addr, mem))
+				if mem == nil {
+					body = append(body, zeroOut(t.Base(), addr))
+				} else {
+					body = append(body, storeInit(t.Base(), addr, mem))
+				}
 			}
 			ln = len(blkBody)
 		}
@@ -653,16 +1813,17 @@ func storeInit(t types.Type, dst ast.AddressableNode, rhs ast.Node) ast.Node {
 			t.Len = ln
 		}
 
-		if t, ok := t.Base().(*types.Arr); !ok {
-			// zero out on initialization
-			for i := idx; i < ln; i++ {
-				addr := ast.NewDerefNode(ast.NewAddNode(dst, ast.NewNumNode(int64(i))))
-				body = append(body, zeroOut(t.Base(), addr))
-			}
+		// zero out on initialization
+		for i := idx; i < t.Len; i++ {
+			addr := ast.NewDerefNode(ast.NewAddNode(dst, ast.NewNumNode(int64(i))))
+			body = append(body, zeroOut(t.Base(), addr))
 		}
 
 		return ast.NewBlkNode(body)
 	case *types.Struct:
+		if _, ok := rhs.(*ast.BlkNode); !ok {
+			return ast.NewExprNode(ast.NewAssignNode(dst, rhs))
+		}
 		var body []ast.Node
 		idx := 0
 		for i, mem := range rhs.(*ast.BlkNode).Body {
@@ -672,7 +1833,19 @@ func storeInit(t types.Type, dst ast.AddressableNode, rhs ast.Node) ast.Node {
 			if mem == nil {
 				body = append(body, zeroOut(member.Type, node))
 			} else {
-				body = append(body, ast.NewExprNode(ast.NewAssignNode(node, mem)))
+				body = append(body, storeInit(member.Type, node, mem))
+			}
+		}
+		return ast.NewBlkNode(body)
+	case *types.Union:
+		if _, ok := rhs.(*ast.BlkNode); !ok {
+			return ast.NewExprNode(ast.NewAssignNode(dst, rhs))
+		}
+		body := []ast.Node{zeroOut(t, dst)}
+		for i, mem := range rhs.(*ast.BlkNode).Body {
+			if mem != nil {
+				member := t.Members[i]
+				body = append(body, storeInit(member.Type, ast.NewMemberNode(dst, member), mem))
 			}
 		}
 		return ast.NewBlkNode(body)
@@ -693,8 +1866,13 @@ func zeroOut(t types.Type, dst ast.AddressableNode) ast.Node {
 	case *types.Struct:
 		var body []ast.Node
 		for _, mem := range t.Members {
-			addr := ast.NewAddNode(dst, ast.NewNumNode(int64(mem.Offset)))
-			body = append(body, zeroOut(mem.Type, ast.NewDerefNode(addr)))
+			body = append(body, zeroOut(mem.Type, ast.NewMemberNode(dst, mem)))
+		}
+		return ast.NewBlkNode(body)
+	case *types.Union:
+		var body []ast.Node
+		for _, mem := range t.Members {
+			body = append(body, zeroOut(mem.Type, ast.NewMemberNode(dst, mem)))
 		}
 		return ast.NewBlkNode(body)
 	default:
@@ -702,18 +1880,17 @@ func zeroOut(t types.Type, dst ast.AddressableNode) ast.Node {
 	}
 }
 
-func isStrNode(n ast.Node) (bool, string) {
-	if v, ok := n.(*ast.VarNode); !ok {
-		return false, ""
-	} else if g, ok := v.Var.(*vars.GVar); !ok {
-		return false, ""
-	} else if t, ok := g.Type().(*types.Arr); !ok {
-		return false, ""
-	} else if _, ok := t.Base().(*types.Char); !ok {
-		return false, ""
-	} else {
-		return true, g.Init.(*vars.GVarInitStr).Content
+// isStrNode returns the content of n when n is a string literal.
+func isStrNode(n ast.Node) (*vars.GVarInitStr, bool) {
+	v, ok := n.(*ast.VarNode)
+	if !ok {
+		return nil, false
 	}
+	g, ok := v.Var.(*vars.GVar)
+	if !ok || !g.IsStrLit {
+		return nil, false
+	}
+	return g.Init.(*vars.GVarInitStr), true
 }
 
 func (p *Parser) switchCase(idx int) (node *ast.CaseNode, isDefault bool) {
@@ -738,29 +1915,48 @@ func (p *Parser) switchCase(idx int) (node *ast.CaseNode, isDefault bool) {
 }
 
 func (p *Parser) expr() ast.Node {
-	return p.assign()
+	node := p.assign()
+	for p.consume(",") {
+		node = ast.NewCommaNode(node, p.assign())
+	}
+	return node
 }
 
 func (p *Parser) assign() ast.Node {
 	node := p.ternary()
 	if p.consume("=") {
-		node = ast.NewAssignNode(node.(ast.AddressableNode), p.assign())
+		lhs := modifiableLvalue(node)
+		rhs := p.assign()
+		warnDiscardedQuals(lhs.LoadType(), rhs.LoadType())
+		node = ast.NewAssignNode(lhs, rhs)
 	} else if p.consume("+=") {
 		if _, ok := node.LoadType().(types.Pointing); ok {
-			node = ast.NewBinaryNode(ast.NdPtrAddEq, node.(ast.AddressableNode), p.assign())
+			node = ast.NewBinaryNode(ast.NdPtrAddEq, modifiableLvalue(node), p.assign())
 		} else {
-			node = ast.NewBinaryNode(ast.NdAddEq, node.(ast.AddressableNode), p.assign())
+			node = ast.NewBinaryNode(ast.NdAddEq, modifiableLvalue(node), p.assign())
 		}
 	} else if p.consume("-=") {
 		if _, ok := node.LoadType().(types.Pointing); ok {
-			node = ast.NewBinaryNode(ast.NdPtrSubEq, node.(ast.AddressableNode), p.assign())
+			node = ast.NewBinaryNode(ast.NdPtrSubEq, modifiableLvalue(node), p.assign())
 		} else {
-			node = ast.NewBinaryNode(ast.NdSubEq, node.(ast.AddressableNode), p.assign())
+			node = ast.NewBinaryNode(ast.NdSubEq, modifiableLvalue(node), p.assign())
 		}
 	} else if p.consume("*=") {
-		node = ast.NewBinaryNode(ast.NdMulEq, node.(ast.AddressableNode), p.assign())
+		node = ast.NewBinaryNode(ast.NdMulEq, modifiableLvalue(node), p.assign())
 	} else if p.consume("/=") {
-		node = ast.NewBinaryNode(ast.NdDivEq, node.(ast.AddressableNode), p.assign())
+		node = ast.NewBinaryNode(ast.NdDivEq, modifiableLvalue(node), p.assign())
+	} else if p.consume("%=") {
+		node = ast.NewBinaryNode(ast.NdModEq, modifiableLvalue(node), p.assign())
+	} else if p.consume("&=") {
+		node = ast.NewBinaryNode(ast.NdBitAndEq, modifiableLvalue(node), p.assign())
+	} else if p.consume("|=") {
+		node = ast.NewBinaryNode(ast.NdBitOrEq, modifiableLvalue(node), p.assign())
+	} else if p.consume("^=") {
+		node = ast.NewBinaryNode(ast.NdBitXorEq, modifiableLvalue(node), p.assign())
+	} else if p.consume("<<=") {
+		node = ast.NewBinaryNode(ast.NdShlEq, modifiableLvalue(node), p.assign())
+	} else if p.consume(">>=") {
+		node = ast.NewBinaryNode(ast.NdShrEq, modifiableLvalue(node), p.assign())
 	}
 	return node
 }
@@ -850,13 +2046,9 @@ func (p *Parser) shift() ast.Node {
 	node := p.addSub()
 	for {
 		if p.consume("<<") {
-			node = ast.NewBinaryNode(ast.NdShl, node, p.shift())
+			node = ast.NewBinaryNode(ast.NdShl, node, p.addSub())
 		} else if p.consume(">>") {
-			node = ast.NewBinaryNode(ast.NdShr, node, p.shift())
-		} else if p.consume("<<=") {
-			node = ast.NewBinaryNode(ast.NdShlEq, node, p.shift())
-		} else if p.consume(">>=") {
-			node = ast.NewBinaryNode(ast.NdShrEq, node, p.shift())
+			node = ast.NewBinaryNode(ast.NdShr, node, p.addSub())
 		} else {
 			return node
 		}
@@ -883,6 +2075,8 @@ func (p *Parser) mulDiv() ast.Node {
 			node = ast.NewBinaryNode(ast.NdMul, node, p.cast())
 		} else if p.consume("/") {
 			node = ast.NewBinaryNode(ast.NdDiv, node, p.cast())
+		} else if p.consume("%") {
+			node = ast.NewBinaryNode(ast.NdMod, node, p.cast())
 		} else {
 			return node
 		}
@@ -893,12 +2087,17 @@ func (p *Parser) cast() ast.Node {
 	orig := p.Toks
 	if p.consume("(") {
 		if p.isType() {
-			t, _, _ := p.baseType()
-			for p.consume("*") {
-				t = types.NewPtr(t)
-			}
+			t := p.typeName()
 			p.expect(")")
-			return ast.NewCastNode(p.cast(), t)
+			if !p.beginsWith("{") {
+				node := ast.NewCastNode(p.cast(), t)
+				if sizes := vlaSizes(t); sizes != nil {
+					// e.g) (int (*)[n])p, where the size is computed for the arithmetic on the result.
+					return ast.NewCommaNode(sizes, node)
+				}
+				return node
+			}
+			// a compound literal, which is a postfix expression.
 		}
 		p.Toks = orig
 	}
@@ -916,19 +2115,30 @@ func (p *Parser) unary() ast.Node {
 		return ast.NewDerefNode(p.cast())
 	}
 	if p.consume("&") {
-		return ast.NewAddrNode(p.cast().(ast.AddressableNode))
+		n := p.cast()
+		v, ok := n.(ast.AddressableNode)
+		if !ok || ast.IsRvalue(n) {
+			log.Fatal("Lvalue required as unary & operand")
+		}
+		return ast.NewAddrNode(v)
 	}
 	if p.consume("!") {
 		return ast.NewNotNode(p.cast())
 	}
+	if p.consume("&&") {
+		// GNU extension: address of a label
+		label := p.expectID().Str()
+		p.labelRefs = append(p.labelRefs, label)
+		return ast.NewLabelAddrNode(label, p.curFnName)
+	}
 	if p.consume("~") {
 		return ast.NewBitNotNode(p.cast())
 	}
 	if p.consume("++") {
-		return ast.NewIncNode(p.unary().(ast.AddressableNode), true)
+		return ast.NewIncNode(modifiableLvalue(p.unary()), true)
 	}
 	if p.consume("--") {
-		return ast.NewDecNode(p.unary().(ast.AddressableNode), true)
+		return ast.NewDecNode(modifiableLvalue(p.unary()), true)
 	}
 	return p.postfix()
 }
@@ -936,6 +2146,17 @@ func (p *Parser) unary() ast.Node {
 func (p *Parser) postfix() ast.Node {
 	node := p.primary()
 	for {
+		if p.consume("(") {
+			args := p.fnArgs()
+			call := ast.NewFnCallNode(node, args)
+			if _, ok := call.FnTy.RetTy.(types.Composite); ok {
+				call.RetBuf = p.newTmpLVar(call.FnTy.RetTy)
+			}
+			checkArgs(node, call.FnTy, args)
+			p.checkFormat(node, args)
+			node = call
+			continue
+		}
 		if p.consume("[") {
 			add := ast.NewAddNode(node, p.expr())
 			node = ast.NewDerefNode(add)
@@ -943,35 +2164,95 @@ func (p *Parser) postfix() ast.Node {
 			continue
 		}
 		if p.consume(".") {
-			if s, ok := node.LoadType().(*types.Struct); ok {
-				mem := s.FindMember(p.expectID().Str())
-				node = ast.NewMemberNode(node.(ast.AddressableNode), mem)
+			if c, ok := node.LoadType().(types.Composite); ok {
+				mem := p.member(c)
+				lhs, ok := node.(ast.AddressableNode)
+				if !ok {
+					// a struct or union rvalue such as (a = b) is copied to a temporary to access its member.
+					lhs = ast.NewRvalueNode(node, p.newTmpLVar(c))
+				}
+				node = ast.NewMemberNode(lhs, mem)
 				continue
 			}
-			log.Fatalf("Expected struct but got %T", node.LoadType())
+			log.Fatalf("Expected struct or union but got %T", node.LoadType())
 		}
 		if p.consume("->") {
-			if t, ok := node.LoadType().(*types.Ptr); ok {
-				mem := t.Base().(*types.Struct).FindMember(p.expectID().Str())
-				node = ast.NewMemberNode(ast.NewDerefNode(node.(ast.AddressableNode)), mem)
-				continue
+			if t, ok := node.LoadType().(types.Pointing); ok {
+				if c, ok := t.Base().(types.Composite); ok {
+					mem := p.member(c)
+					node = ast.NewMemberNode(ast.NewDerefNode(node), mem)
+					continue
+				}
 			}
-			log.Fatalf("Expected pointer but got %T", node.LoadType())
+			log.Fatalf("Expected pointer to struct or union but got %T", node.LoadType())
 		}
 		if p.consume("++") {
-			node = ast.NewIncNode(node.(ast.AddressableNode), false)
+			node = ast.NewIncNode(modifiableLvalue(node), false)
 			continue
 		}
 		if p.consume("--") {
-			node = ast.NewDecNode(node.(ast.AddressableNode), false)
+			node = ast.NewDecNode(modifiableLvalue(node), false)
 			continue
 		}
 		return node
 	}
 }
 
+func (p *Parser) fnArgs() (args []ast.Node) {
+	// "(" is already read.
+	if p.consume(")") {
+		return
+	}
+	args = append(args, p.assign())
+	for p.consume(",") {
+		args = append(args, p.assign())
+	}
+	p.expect(")")
+	return
+}
+
+// checkArgs checks the arguments of the call to fn against its prototype.
+// Each argument is converted to the type of its parameter at the call.
+func checkArgs(fn ast.Node, fnTy *types.Fn, args []ast.Node) {
+	if !fnTy.HasProto {
+		return
+	}
+	name := "function"
+	if v, ok := fn.(*ast.VarNode); ok {
+		name = "function " + v.Var.Name()
+	}
+	if len(args) < len(fnTy.Params) {
+		log.Fatalf("Too few arguments to %s: %d expected but got %d", name, len(fnTy.Params), len(args))
+	}
+	if len(args) > len(fnTy.Params) && !fnTy.IsVariadic {
+		log.Fatalf("Too many arguments to %s: %d expected but got %d", name, len(fnTy.Params), len(args))
+	}
+	for i, param := range fnTy.Params {
+		arg := args[i].LoadType()
+		_, isParamComposite := param.(types.Composite)
+		_, isArgComposite := arg.(types.Composite)
+		if (isParamComposite || isArgComposite) && !types.IsCompatible(types.Unqualified(param), types.Unqualified(arg)) {
+			log.Fatalf("Incompatible type for argument %d of %s", i+1, name)
+		}
+		warnDiscardedQuals(param, arg)
+	}
+}
+
+func (p *Parser) member(c types.Composite) *types.Member {
+	if types.IsIncomplete(c) {
+		log.Fatal("Member access to incomplete type")
+	}
+	id := p.expectID().Str()
+	mem := c.FindMember(id)
+	if mem == nil {
+		log.Fatalf("No such member %s", id)
+	}
+	return mem
+}
+
 func (p *Parser) stmtExpr() ast.Node {
 	// "(" and "{" is already read.
+	exit := p.exit
 	p.spawnScope()
 	body := make([]ast.Node, 0)
 	body = append(body, p.stmt())
@@ -985,7 +2266,70 @@ func (p *Parser) stmtExpr() ast.Node {
 		body[len(body)-1] = ex.Body
 	}
 	p.rewindScope()
-	return ast.NewStmtExprNode(body)
+	node := ast.NewStmtExprNode(body)
+	node.Restore = p.restoreExit(exit)
+	return node
+}
+
+// genericSelection reads the rest of `_Generic(ctrl, type: expr, ..., default: expr)` and returns the selected expression.
+// The controlling expression is not evaluated, and its type is the one after the lvalue conversion.
+func (p *Parser) genericSelection() ast.Node {
+	p.expect("(")
+	t := lvalueConvert(p.assign().LoadType())
+	var selected, dflt ast.Node
+	var assocs []types.Type
+	for p.consume(",") {
+		if p.consume("default") {
+			p.expect(":")
+			if dflt != nil {
+				log.Fatal("Duplicate default association in _Generic")
+			}
+			dflt = p.assign()
+			continue
+		}
+		at := p.typeName()
+		p.expect(":")
+		node := p.assign()
+		if types.IsIncomplete(at) || types.IsVariablyModified(at) {
+			log.Fatalf("_Generic association has incomplete or variably modified type %T", at)
+		}
+		for _, prev := range assocs {
+			if types.IsCompatible(prev, at) {
+				log.Fatalf("_Generic has more than one association compatible with type %T", at)
+			}
+		}
+		assocs = append(assocs, at)
+		if types.IsCompatible(t, at) {
+			selected = node
+		}
+	}
+	p.expect(")")
+	if selected == nil {
+		selected = dflt
+	}
+	if selected == nil {
+		log.Fatalf("_Generic selector of type %T is not compatible with any association", t)
+	}
+	return selected
+}
+
+// compoundLit reads the initializer of a compound literal of type t.
+// The literal is an unnamed global variable at file scope, and an unnamed local variable in a function.
+func (p *Parser) compoundLit(t types.Type) ast.Node {
+	if types.IsIncomplete(t) && !isUnsized(t) {
+		log.Fatal("Compound literal has incomplete type")
+	}
+	if types.IsVariablyModified(t) {
+		log.Fatal("Compound literal has variable size")
+	}
+	if p.curScope.super == nil {
+		init := p.initializer(t, static)
+		return ast.NewVarNode(p.newAnonGVar(t, buildGVarInit(t, init)))
+	}
+	init := p.initializer(t, 0)
+	// allocated after the initializer is read, since it may determine the length of the array.
+	lv := p.newTmpLVar(t)
+	return ast.NewCompoundLitNode(storeInit(t, ast.NewVarNode(lv), init), ast.NewVarNode(lv))
 }
 
 func (p *Parser) primary() ast.Node {
@@ -993,6 +2337,11 @@ func (p *Parser) primary() ast.Node {
 		if p.consume("{") {
 			return p.stmtExpr()
 		}
+		if p.isType() {
+			t := p.typeName()
+			p.expect(")")
+			return p.compoundLit(t)
+		}
 		node := p.expr()
 		p.expect(")")
 		return node
@@ -1002,41 +2351,79 @@ func (p *Parser) primary() ast.Node {
 		orig := p.Toks
 		if p.consume("(") {
 			if p.isType() {
-				base, _, _ := p.baseType()
-				n := base.Size()
+				t := p.typeName()
 				p.expect(")")
-				return ast.NewNumNode(int64(n))
+				if !p.beginsWith("{") {
+					if types.IsIncomplete(t) {
+						log.Fatal("sizeof applied to incomplete type")
+					}
+					// the size of a VLA is computed at runtime.
+					return vlaSize(t)
+				}
 			}
 			p.Toks = orig
 		}
-		return ast.NewNumNode(int64(p.unary().LoadType().Size()))
+		node := p.unary()
+		if types.IsIncomplete(node.LoadType()) {
+			log.Fatal("sizeof applied to incomplete type")
+		}
+		if v, ok := node.LoadType().(*types.VLA); ok {
+			// the operand of VLA type is evaluated, which computes the size where the type appears in a cast.
+			return ast.NewCommaNode(node, ast.NewVarNode(v.SizeVar.(*vars.LVar)))
+		}
+		return ast.NewTypedNumNode(int64(node.LoadType().Size()), types.NewULong())
 	}
 
-	if id, isID := p.consumeID(); isID {
-		id := id.Str()
+	if p.consume("_Generic") {
+		return p.genericSelection()
+	}
+
+	if p.consume("_Alignof") || p.consume("alignof") {
+		orig := p.Toks
 		if p.consume("(") {
-			var t types.Type
-			if fn, ok := p.searchVar(id).(*vars.GVar); ok {
-				t = fn.Type().(*types.Fn).RetTy
-			} else {
-				t = types.NewInt()
+			if p.isType() {
+				t := p.typeName()
+				p.expect(")")
+				return ast.NewTypedNumNode(int64(t.Alignment()), types.NewULong())
 			}
-			var params []ast.Node
-			if p.consume(")") {
-				return ast.NewFnCallNode(id, params, t)
+			p.Toks = orig
+		}
+		// GNU extension: the alignment of an expression, which reflects _Alignas on a declared variable.
+		node := p.unary()
+		if v, ok := node.(*ast.VarNode); ok {
+			switch v := v.Var.(type) {
+			case *vars.LVar:
+				return ast.NewTypedNumNode(int64(v.Alignment()), types.NewULong())
+			case *vars.GVar:
+				return ast.NewTypedNumNode(int64(v.Alignment()), types.NewULong())
 			}
-			params = append(params, p.expr())
-			for p.consume(",") {
-				params = append(params, p.expr())
+		}
+		return ast.NewTypedNumNode(int64(node.LoadType().Alignment()), types.NewULong())
+	}
+
+	if id, isID := p.consumeID(); isID {
+		id := id.Str()
+		if node, ok := p.builtin(id); ok {
+			return node
+		}
+		if p.beginsWith("(") && p.searchVar(id) == nil {
+			// implicitly declared function returning int
+			if p.WerrorImplicitFnDecl {
+				log.Fatalf("Implicit declaration of function %s", id)
 			}
-			p.expect(")")
-			return ast.NewFnCallNode(id, params, t)
+			log.Printf("warning: implicit declaration of function %s", id)
+			fn := vars.NewGVar(false, id, types.NewFn(types.NewInt(), nil, false, false), nil)
+			return ast.NewVarNode(fn)
 		}
 
 		switch v := p.findVar(id).(type) {
 		case *vars.Enum:
-			return ast.NewNumNode(int64(v.Val))
-		case *vars.LVar, *vars.GVar:
+			return ast.NewTypedNumNode(v.Val, v.Type())
+		case *vars.LVar:
+			v.IsReferenced = true
+			return ast.NewVarNode(v)
+		case *vars.GVar:
+			v.IsReferenced = true
 			return ast.NewVarNode(v)
 		default:
 			log.Fatalf("Unhandled case of vars.Var in primary: %T", p.findVar(id))
@@ -1044,11 +2431,9 @@ func (p *Parser) primary() ast.Node {
 	}
 
 	if strTok, isStr := p.consumeStr(); isStr {
-		init := vars.NewGVarInitStr(strTok.Str())
-		s := vars.NewGVar(true, newGVarLabel(), types.NewArr(types.NewChar(), strTok.Len()), init)
-		p.Ast.GVars = append(p.Ast.GVars, s)
-		return ast.NewVarNode(s)
+		return ast.NewVarNode(p.strLit(strTok))
 	}
 
-	return ast.NewNumNode(int64(p.expectNum().Val))
+	tok := p.expectNum()
+	return ast.NewTypedNumNode(tok.Val, numType(tok))
 }
@@ -38,18 +38,12 @@ func newEnumTag(name string, t types.Type) *enumTag {
 	return &enumTag{name, t}
 }
 
-func (s *scope) addGVar(emit bool, id string, t types.Type, init vars.GVarInit) *vars.GVar {
-	if v, exists := s.searchVar(id).(*vars.GVar); exists {
-		if ty, ok := v.Type().(*types.Fn); ok && !ty.IsComplete {
-			v.SetType(t)
-		} else {
-			log.Fatalf("identifier %s is already defined", id)
-		}
-		return v
+// addVar adds v to s. It is an error when a variable of the same name is already declared in s.
+func (s *scope) addVar(v vars.Var) {
+	if s.searchVar(v.Name()) != nil {
+		log.Fatalf("identifier %s is already defined", v.Name())
 	}
-	v := vars.NewGVar(emit, id, t, init)
 	s.vars = append(s.vars, v)
-	return v
 }
 
 func (s *scope) addLVar(id string, t types.Type) *vars.LVar {
@@ -70,7 +64,7 @@ func (s *scope) addTypeDef(id string, t types.Type) *vars.TypeDef {
 	return v
 }
 
-func (s *scope) addEnum(id string, t types.Type, val int) *vars.Enum {
+func (s *scope) addEnum(id string, t types.Type, val int64) *vars.Enum {
 	if _, exists := s.searchVar(id).(*vars.Enum); exists {
 		log.Fatalf("enum %s is already defined", id)
 	}
@@ -141,14 +135,41 @@ func (p *Parser) spawnScope() {
 func (p *Parser) rewindScope() {
 	offset := p.curScope.curOffset
 	base := p.curScope.baseOffset
-	lvars, gvars, _ := p.curScope.segregateScopeVars()
+	lvars, _, _ := p.curScope.segregateScopeVars()
+	if p.WarnUnused {
+		p.warnUnusedLVars(lvars)
+	}
 	for _, v := range lvars {
-		offset = types.AlignTo(offset, v.Type().Size())
-		offset += v.Type().Size()
+		// the variable is placed at rbp-(offset+base), so the sum is aligned.
+		offset = types.AlignTo(base+offset+v.Type().Size(), v.Alignment()) - base
 		v.Offset = offset + base
 	}
 	p.curScope.curOffset += offset
 	p.curScope = p.curScope.super
 	p.curScope.curOffset += offset
-	p.Ast.GVars = append(p.Ast.GVars, gvars...)
+}
+
+// warnUnusedLVars warns about the local variables never referenced, except parameters and temporaries.
+func (p *Parser) warnUnusedLVars(lvars []*vars.LVar) {
+	for _, v := range lvars {
+		if v.Name() != "" && !v.IsReferenced && !p.isParam(v) && !p.isMaybeUnused(v) {
+			log.Printf("warning: unused variable %s", v.Name())
+		}
+	}
+}
+
+func (p *Parser) isParam(v *vars.LVar) bool {
+	for _, param := range p.curFn.Params {
+		if v == param {
+			return true
+		}
+	}
+	return false
+}
+
+// newTmpLVar allocates an unnamed local variable in the current scope.
+func (p *Parser) newTmpLVar(t types.Type) *vars.LVar {
+	v := vars.NewLVar("", t)
+	p.curScope.vars = append(p.curScope.vars, v)
+	return v
 }
@@ -6,7 +6,9 @@ import (
 	"strings"
 	"unicode/utf8"
 
+	"github.com/joehattori/tgocc/ast"
 	"github.com/joehattori/tgocc/tokenizer"
+	"github.com/joehattori/tgocc/types"
 	"github.com/joehattori/tgocc/vars"
 )
 
@@ -76,6 +78,64 @@ func (p *Parser) expectNum() (tok *tokenizer.NumTok) {
 	return
 }
 
+// numType returns the type of the integer constant tok, which is the first type in its list that can represent the value.
+// The list is decided by the suffix and whether the constant is decimal, following C11 6.4.4.1.
+func numType(tok *tokenizer.NumTok) types.Type {
+	if tok.IsChar {
+		return types.NewInt()
+	}
+	var candidates []types.Type
+	switch {
+	case tok.IsUnsigned && tok.IsLong:
+		candidates = []types.Type{types.NewULong()}
+	case tok.IsUnsigned:
+		candidates = []types.Type{types.NewUInt(), types.NewULong()}
+	case tok.IsLong && tok.IsDecimal:
+		candidates = []types.Type{types.NewLong()}
+	case tok.IsLong:
+		candidates = []types.Type{types.NewLong(), types.NewULong()}
+	case tok.IsDecimal:
+		candidates = []types.Type{types.NewInt(), types.NewLong()}
+	default:
+		candidates = []types.Type{types.NewInt(), types.NewUInt(), types.NewLong(), types.NewULong()}
+	}
+	// Val holds the bit pattern of the constant, which is at most the maximum of unsigned long.
+	val := uint64(tok.Val)
+	for _, t := range candidates {
+		if val <= maxOf(t) {
+			return t
+		}
+	}
+	// GCC gives unsigned long to a decimal constant which is too large for long, with a warning.
+	log.Printf("warning: integer constant %d is so large that it is unsigned", val)
+	return types.NewULong()
+}
+
+// maxOf returns the maximum value of the integer type t.
+func maxOf(t types.Type) uint64 {
+	bits := uint(t.Size() * 8)
+	if !types.IsUnsigned(t) {
+		bits--
+	}
+	return 1<<bits - 1
+}
+
+// fitsIn reports whether the integer type t can represent val, which is unsigned if isUnsigned is set.
+func fitsIn(val int64, isUnsigned bool, t types.Type) bool {
+	if isUnsigned || val >= 0 {
+		return uint64(val) <= maxOf(t)
+	}
+	return val >= minOf(t)
+}
+
+// minOf returns the minimum value of the integer type t.
+func minOf(t types.Type) int64 {
+	if types.IsUnsigned(t) {
+		return 0
+	}
+	return -1 << uint(t.Size()*8-1)
+}
+
 func (p *Parser) expectStr() (tok *tokenizer.StrTok) {
 	tok, _ = p.Toks[0].(*tokenizer.StrTok)
 	if tok == nil {
@@ -85,11 +145,16 @@ func (p *Parser) expectStr() (tok *tokenizer.StrTok) {
 	return
 }
 
+// isFunction reports whether the declarator to be read declares a function, whose identifier is followed by "(",
+// e.g) f(int a), (*getf(int k))(int, int)
 func (p *Parser) isFunction() bool {
+	// base type is already read.
 	orig := p.Toks
 	defer func() { p.Toks = orig }()
-	p.baseType()
-	for p.consume("*") {
+	p.pointers(types.NewEmpty())
+	for p.isNestedDecl() {
+		p.popToks()
+		p.pointers(types.NewEmpty())
 	}
 	_, isID := p.consumeID()
 	return isID && p.consume("(")
@@ -116,6 +181,49 @@ func newGVarLabel() string {
 	return fmt.Sprintf(".L.data.%d", gVarLabelCount)
 }
 
+// strLit returns the array of the string literal tok.
+// Identical literals of the same element type share the same array, since they are never modified.
+func (p *Parser) strLit(tok *tokenizer.StrTok) *vars.GVar {
+	units := tok.Units()
+	prefix := tok.Prefix
+	if prefix == "u8" {
+		prefix = ""
+	}
+	key := fmt.Sprint(prefix, units)
+	if g, ok := p.strLits[key]; ok {
+		return g
+	}
+	g := p.newAnonGVar(types.NewArr(strElemType(tok), len(units)), vars.NewGVarInitStr(units, tok.ElemSize()))
+	g.IsStrLit = true
+	p.strLits[key] = g
+	return g
+}
+
+// strElemType returns the type of the elements of the string literal tok.
+// u"" and U"" have char16_t and char32_t, which are unsigned short and unsigned int,
+// and L"" has wchar_t, which is int on Linux.
+func strElemType(tok *tokenizer.StrTok) types.Type {
+	switch tok.Prefix {
+	case "u":
+		return types.NewUShort()
+	case "U":
+		return types.NewUInt()
+	case "L":
+		return types.NewInt()
+	}
+	return types.NewChar()
+}
+
+// newAnonGVar creates an unnamed global variable, e.g) for a string literal, which is not visible from other translation units.
+func (p *Parser) newAnonGVar(t types.Type, init vars.GVarInit) *vars.GVar {
+	g := vars.NewGVar(true, newGVarLabel(), t, init)
+	g.IsStatic = true
+	// it is referred to by the expression creating it.
+	g.IsReferenced = true
+	p.Ast.GVars = append(p.Ast.GVars, g)
+	return g
+}
+
 func (p *Parser) findVar(s string) vars.Var {
 	v := p.searchVar(s)
 	if v == nil {
@@ -156,3 +264,95 @@ func (p *Parser) searchVar(varName string) vars.Var {
 	}
 	return nil
 }
+
+// modifiableLvalue checks that n can be the operand of an assignment or an increment.
+func modifiableLvalue(n ast.Node) ast.AddressableNode {
+	lhs, ok := n.(ast.AddressableNode)
+	if !ok || ast.IsRvalue(n) {
+		log.Fatalf("Lvalue required but got %T", n)
+	}
+	t := lhs.LoadType()
+	switch t.(type) {
+	case *types.Fn:
+		log.Fatal("Cannot assign to function")
+	case *types.Arr, *types.VLA:
+		log.Fatal("Cannot assign to array")
+	}
+	if types.IsConst(t) {
+		log.Fatal("Cannot assign to const-qualified lvalue")
+	}
+	if hasConstMember(t) {
+		log.Fatal("Cannot assign to struct or union with const-qualified member")
+	}
+	return lhs
+}
+
+// hasConstMember reports whether t is a struct or union having a const-qualified member, possibly nested.
+func hasConstMember(t types.Type) bool {
+	var members []*types.Member
+	switch t := t.(type) {
+	case *types.Struct:
+		members = t.Members
+	case *types.Union:
+		members = t.Members
+	}
+	for _, m := range members {
+		mt := m.Type
+		for arr, ok := mt.(*types.Arr); ok; arr, ok = mt.(*types.Arr) {
+			mt = arr.Of
+		}
+		if types.IsConst(mt) || hasConstMember(mt) {
+			return true
+		}
+	}
+	return false
+}
+
+// lvalueConvert returns the type of the value of an expression of type t,
+// where arrays and functions decay to pointers and the qualifiers are dropped.
+func lvalueConvert(t types.Type) types.Type {
+	switch t := t.(type) {
+	case *types.Arr:
+		return types.NewPtr(t.Of)
+	case *types.VLA:
+		return types.NewPtr(t.Of)
+	case *types.Fn:
+		return types.NewPtr(t)
+	}
+	return types.Unqualified(t)
+}
+
+// warnDiscardedQuals warns when a pointer conversion from `from` to `to` drops the qualifiers of the pointed type.
+func warnDiscardedQuals(to types.Type, from types.Type) {
+	toPtr, ok := to.(*types.Ptr)
+	if !ok {
+		return
+	}
+	fromPtr, ok := from.(types.Pointing)
+	if !ok {
+		return
+	}
+	toQuals := types.QualsOf(toPtr.To)
+	fromQuals := types.QualsOf(fromPtr.Base())
+	if fromQuals.IsConst && !toQuals.IsConst {
+		log.Print("warning: conversion discards 'const' qualifier from pointer target type")
+	}
+	if fromQuals.IsVolatile && !toQuals.IsVolatile {
+		log.Print("warning: conversion discards 'volatile' qualifier from pointer target type")
+	}
+}
+
+// isLabel reports whether the statement begins with `ident ":"`.
+func (p *Parser) isLabel() bool {
+	if _, ok := p.Toks[0].(*tokenizer.IDTok); !ok || len(p.Toks) < 2 {
+		return false
+	}
+	r, ok := p.Toks[1].(*tokenizer.ReservedTok)
+	return ok && r.Str() == ":"
+}
+
+// isUnsized reports whether t is an array of unknown length, e.g) int[], which its initializer may complete.
+func isUnsized(t types.Type) bool {
+	arr, ok := t.(*types.Arr)
+	return ok && arr.Len < 0
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"log"
+
+	"github.com/joehattori/tgocc/ast"
+	"github.com/joehattori/tgocc/types"
+	"github.com/joehattori/tgocc/vars"
+)
+
+// stackBottom returns the variable holding the bottom of the stack area allocated by VLAs and alloca in the current function.
+func (p *Parser) stackBottom() *vars.LVar {
+	if p.curFn == nil {
+		log.Fatal("Variable length array or alloca outside of function")
+	}
+	if p.curFn.StackBottom == nil {
+		p.curFn.StackBottom = p.newTmpLVar(types.NewPtr(types.NewVoid()))
+	}
+	return p.curFn.StackBottom
+}
+
+// allocVLA returns the node allocating the storage of the VLA lv, and brings it in scope.
+func (p *Parser) allocVLA(lv *vars.LVar) ast.Node {
+	savedSP := p.newTmpLVar(types.NewPtr(types.NewVoid()))
+	p.exit = &exitScope{savedSP: savedSP, parent: p.exit}
+	return ast.NewVLAAllocNode(lv, vlaSize(lv.Type()), savedSP, p.stackBottom())
+}
+
+// vlaSize returns the node computing the size of t in bytes.
+// The size of each VLA in t is stored to its SizeVar on the way.
+func vlaSize(t types.Type) ast.Node {
+	v, ok := t.(*types.VLA)
+	if !ok {
+		return ast.NewTypedNumNode(int64(t.Size()), types.NewULong())
+	}
+	l := ast.NewCastNode(v.Len.(ast.Node), types.NewULong())
+	size := ast.NewBinaryNode(ast.NdMul, l, vlaSize(v.Of))
+	return ast.NewAssignNode(ast.NewVarNode(v.SizeVar.(*vars.LVar)), size)
+}
+
+// vlaSizes returns the node computing the sizes of the VLAs which t is derived from, e.g) int (*)[n].
+// It returns nil when t is not variably modified.
+func vlaSizes(t types.Type) ast.Node {
+	switch t := t.(type) {
+	case *types.Ptr:
+		return vlaSizes(t.To)
+	case *types.VLA:
+		return vlaSize(t)
+	}
+	return nil
+}
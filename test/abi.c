@@ -0,0 +1,93 @@
+// compiled by cc to check the calling convention of tgocc against it.
+
+#include "test.h"
+
+struct small gcc_make_small(char a, short b) { struct small s = {a, b}; return s; }
+struct pair gcc_make_pair(long a, long b) { struct pair p = {a, b}; return p; }
+struct mid gcc_make_mid(int a, char b, int c) { struct mid m = {a, b, c}; return m; }
+struct big gcc_make_big(long a) { struct big b = {a, a*2, a*3}; return b; }
+
+struct odd gcc_make_odd(int base) {
+    struct odd o;
+    for (int i=0; i<11; i++)
+        o.c[i] = base+i;
+    return o;
+}
+
+long gcc_sum_small(struct small s) { return s.a + s.b; }
+long gcc_sum_packed(struct packed p) { return p.a + p.b + p.c; }
+long gcc_packed_size(void) { return sizeof(struct packed); }
+long gcc_sum_pair(struct pair p) { return p.a + p.b; }
+long gcc_sum_mid(struct mid m) { return m.a + m.b + m.c; }
+long gcc_sum_big(struct big b) { return b.a + b.b + b.c; }
+
+long gcc_sum_odd(struct odd o) {
+    long sum = 0;
+    for (int i=0; i<11; i++)
+        sum += o.c[i];
+    return sum;
+}
+
+long gcc_mixed(int x, struct big b, struct pair p, int y) {
+    return x*1000 + (b.a+b.b+b.c)*100 + (p.a+p.b)*10 + y;
+}
+
+long gcc_exhaust(long a, long b, long c, long d, long e, struct pair p, long f) {
+    return a+b+c+d+e + p.a*100 + p.b*1000 + f*10000;
+}
+
+long gcc_call_tgocc(void) {
+    struct pair p = tg_make_pair(3, 4);
+    struct big b = tg_make_big(5);
+    struct odd o = tg_make_odd(1);
+    struct big arg = {1, 2, 3};
+    struct pair q = {6, 7};
+    return p.a + p.b + b.a + b.b + b.c + tg_sum_odd(o) + tg_sum_big(arg) + tg_exhaust(1, 1, 1, 1, 1, q, 8);
+}
+
+long gcc_sum10(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j) {
+    return a + b*2 + c*3 + d*4 + e*5 + f*6 + g*7 + h*8 + i*9 + j*10;
+}
+
+long gcc_mixed10(char a, short b, int c, long d, int e, int f, char g, short h, int i, long j) {
+    return a + b + c + d + e + f + g + h + i + j;
+}
+
+// the frame pointer is 16-byte aligned only when the caller aligns rsp at the call.
+long gcc_frame_align(void) {
+    long rbp;
+    __asm__("mov %%rbp, %0" : "=r"(rbp));
+    return rbp & 15;
+}
+
+long gcc_frame_align7(long a, long b, long c, long d, long e, long f, long g) {
+    long rbp;
+    __asm__("mov %%rbp, %0" : "=r"(rbp));
+    return (rbp & 15) + (a+b+c+d+e+f+g != 28);
+}
+
+// a double is passed in xmm0, which tg_format forwards to vsprintf through its va_list.
+long gcc_call_tg_format(void) {
+    char buf[64];
+    int n = tg_format(buf, "%d %s %.2f %ld", 1, "ab", 2.5, 10L);
+    return n*10 + (strcmp(buf, "1 ab 2.50 10") == 0);
+}
+
+long gcc_call_tg_vsum(void) {
+    return tg_vsum(9, 1L, 2L, 3L, 4L, 5L, 6L, 7L, 8L, 9L);
+}
+
+long gcc_call_tgocc_many(void) {
+    return tg_sum10(1, 2, 3, 4, 5, 6, 7, 8, 9, 10) + tg_mixed10(-1, -2, -3, -4, -5, -6, -7, -8, -9, -10);
+}
+
+// only the lowest bit of a _Bool argument may be set.
+int gcc_bool_arg(_Bool b) { return b; }
+
+long gcc_call_tg_to_bool(void) {
+    return tg_to_bool(256) + tg_to_bool(0)*10;
+}
+
+__thread int gcc_tls = 7;
+int gcc_tls_get(void) { return gcc_tls; }
+long gcc_tg_tls2(void) { return tg_tls2; }
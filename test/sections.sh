@@ -0,0 +1,41 @@
+#!/bin/sh
+# sections.sh checks the section, the type and the size of symbols in the objects compiled from test1.c and test2.c,
+# and that the linker discarded the unused sections of test2.c.
+# usage: sections.sh tmp1.o tmp2.o tmp
+fail=0
+
+# expect OBJ SYMBOL SECTION TYPE SIZE checks the symbol table of OBJ, where TYPE is O for an object and F for a function.
+# SIZE - only checks that the size is given by .size, which is the case for functions.
+expect() {
+    got=$(objdump -t "$1" | awk -v s="$2" '$NF == s { print $(NF-2), $(NF-3), $(NF-1) }')
+    if [ "$5" = - ]; then
+        got=$(echo "$got" | sed 's/ 0*[1-9a-f][0-9a-f]*$/ -/')
+        want="$3 $4 -"
+    else
+        want=$(printf "%s %s %016x" "$3" "$4" "$5")
+    fi
+    if [ "$got" != "$want" ]; then
+        echo "$1: $2: expected '$want', but got '$got'"
+        fail=1
+    fi
+}
+
+expect "$1" g17 .data O 7
+expect "$1" g26 .rodata O 4
+expect "$1" g27 .rodata O 7
+expect "$1" main .text F -
+objdump -h "$1" | grep -q ' \.rodata\.str1\.1 ' || { echo "$1: string literals are not in .rodata.str1.1"; fail=1; }
+
+expect "$2" sec_bss2 .bss.sec_bss2 O 4
+expect "$2" sec_ro2 .rodata.sec_ro2 O 4
+expect "$2" sec_data2 .data.sec_data2 O 4
+expect "$2" sg .data.sg O 4
+expect "$2" static_fn2 .text.static_fn2 F -
+
+if nm "$3" | grep -q ' sec_unused2$'; then
+    echo "$3: sec_unused2 is not discarded"
+    fail=1
+fi
+
+[ $fail = 0 ] && echo "sections OK"
+exit $fail
@@ -1,10 +1,22 @@
 #define ZERO 0
 #define WEEKS 365/7
 #define MIN(X, Y)  ((X) < (Y) ? (X) : (Y))
+#define type_id(x) _Generic((x), char: 1, short: 2, int: 3, long: 4, unsigned int: 5, unsigned long: 6, default: 0)
+#define max(a, b) ({ typeof(a) _a = (a); typeof(b) _b = (b); _a > _b ? _a : _b; })
+
+typedef __builtin_va_list va_list;
+#define va_start(ap, last) __builtin_va_start(ap, last)
+#define va_arg(ap, type) __builtin_va_arg(ap, type)
+#define va_end(ap) __builtin_va_end(ap)
+#define va_copy(dst, src) __builtin_va_copy(dst, src)
+#define alloca(size) __builtin_alloca(size)
 
 int printf();
 int exit();
 int strcmp(char *p, char *q);
+int memcmp(const void *p, const void *q, unsigned long n);
+void qsort(void *base, unsigned long n, unsigned long size, int (*cmp)(const void *, const void *));
+int vsprintf(char *restrict buf, const char *restrict fmt, va_list ap);
 int add2(int x, int y);
 int sub2(int x, int y);
 int add6(int a, int b, int c, int d, int e, int f);
@@ -14,8 +26,66 @@ int sub_char(char a, char b, char c);
 int sub_short(short a, short b, short c);
 int sub_long(long a, long b, long c);
 
+// defined in test2.c.
+int static_fn2(void);
+int get_sg2(void);
+int get_common1(void);
+int counter2(void);
+
 typedef struct {
     int a;
     int b;
     int c;
 } abc;
+
+struct small { char a; short b; };
+struct pair { long a; long b; };
+struct odd { char c[11]; };
+struct mid { int a; char b; int c; };
+struct big { long a; long b; long c; };
+struct __attribute__((packed)) packed { char a; int b; short c; };
+
+// defined in abi.c, which is compiled by cc.
+struct small gcc_make_small(char a, short b);
+struct pair gcc_make_pair(long a, long b);
+struct odd gcc_make_odd(int base);
+struct mid gcc_make_mid(int a, char b, int c);
+struct big gcc_make_big(long a);
+long gcc_sum_small(struct small s);
+long gcc_sum_pair(struct pair p);
+long gcc_sum_odd(struct odd o);
+long gcc_sum_mid(struct mid m);
+long gcc_sum_big(struct big b);
+long gcc_sum_packed(struct packed p);
+long gcc_packed_size(void);
+long gcc_mixed(int x, struct big b, struct pair p, int y);
+long gcc_exhaust(long a, long b, long c, long d, long e, struct pair p, long f);
+long gcc_call_tgocc(void);
+int gcc_bool_arg(_Bool b);
+long gcc_call_tg_to_bool(void);
+
+// defined in test1.c and called from abi.c.
+struct pair tg_make_pair(long a, long b);
+struct big tg_make_big(long a);
+struct odd tg_make_odd(int base);
+long tg_sum_big(struct big b);
+long tg_sum_odd(struct odd o);
+long tg_exhaust(long a, long b, long c, long d, long e, struct pair p, long f);
+_Bool tg_to_bool(long x);
+long gcc_sum10(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j);
+long gcc_mixed10(char a, short b, int c, long d, int e, int f, char g, short h, int i, long j);
+long gcc_frame_align(void);
+long gcc_frame_align7(long a, long b, long c, long d, long e, long f, long g);
+long gcc_call_tgocc_many(void);
+long tg_sum10(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j);
+long tg_mixed10(char a, short b, int c, long d, int e, int f, char g, short h, int i, long j);
+long gcc_call_tg_format(void);
+long gcc_call_tg_vsum(void);
+int tg_format(char *buf, char *fmt, ...) __attribute__((format(printf, 2, 3)));
+long tg_vsum(int n, ...);
+
+// thread-local variables defined in abi.c and test2.c, each accessed from the other compiler or translation unit.
+extern __thread int gcc_tls;
+int gcc_tls_get(void);
+extern __thread long tg_tls2;
+long gcc_tg_tls2(void);
@@ -14,6 +14,12 @@ int test(long expected, long actual, char *input) {
     return 0;
 }
 
+int unnamed_params(int, char *, long[]);
+
+int unnamed_params(int a, char *s, long v[]) {
+    return a + s[1] + v[2];
+}
+
 int ret2(void) {
     return 2;
     return 1;
@@ -34,6 +40,59 @@ struct {int a[2];} g12[2] = {{{1, 2}}};
 char g17[] = "foobar";
 char g18[10] = "foobar";
 char g19[3] = "foobar";
+union {int a; char b[6];} g20 = {0x01020304};
+union {char a; int b; long c;} g21 = {.b=7};
+struct {char a; union {int b; char c;}; long d;} g22 = {1, {2}, 3};
+int g23[2][2] = {{1}};
+struct {unsigned mode:2; unsigned en:1; int level:5; unsigned :4; unsigned irq:8;} g24 = {3, 1, -5, 200};
+struct {char a; int b:4; long c:40; short d:3;} g25 = {1, -3, 123456789012, 2};
+const int g26 = 26;
+const char g27[] = "rodata";
+const char *const g28 = g27;
+int g29 = 29, *g30 = &g29, g31[3] = {1, 2, 3}, g32;
+typedef int int_t, *int_ptr_t, int_arr_t[4];
+int g33[6] = {[2] = 3, 4, [0] = 1, [4 ... 5] = 9};
+struct pair g34 = {.b = 2, .a = 1};
+int g35[2][3] = {1, 2, 3, 4, 5};
+int *g36 = (int[]){5, 6, 7};
+struct pair *g37 = &(struct pair){8, 9};
+struct {int a[3]; struct pair p;} g38 = {.a[1] = 5, .p.b = 7, .a[0] = 3};
+union {int i; char c[4];} g39 = {.c[1] = 1};
+int g40[] = {[3] = 1, 2};
+struct pair g41[2] = {1, 2, 3, 4};
+int g42[5] = {10, 11, 12, 13, 14};
+int *g43 = &g42[3];
+struct big g44 = {1, 2, 3};
+long *g45 = &g44.c;
+char *g46 = "hello" + 2;
+char *g47 = (char *)&g29 + 4 - 4;
+struct big *g48 = &g44;
+int *g49[] = {g42, g42 + 1, &g42[4], g42 + 5 - 1};
+int g50[2][3] = {{1, 2, 3}, {4, 5, 6}};
+int *g51 = &g50[1][2];
+int *g52 = g50[1];
+long *g53 = &g41[1].b;
+char g54 = 300;
+_Bool g55 = 5;
+unsigned char g56 = -1;
+int g57 = (short)65537;
+enum { CE1 = 1 << 4, CE2 = CE1 * 3 % 7, CE3 = (unsigned)-1 >> 31 };
+// shifting into the sign bit is accepted with a warning as in GCC.
+enum { CE4 = 1 << 31, CE5 = -1 << 1 };
+int ce_ternary = 1 ? 2 : 3L;
+int addr_arr[5];
+long addr_diff = &addr_arr[3] - &addr_arr[0];
+int addr_x = 7;
+int *addr_cond = 1 ? &addr_x : 0;
+int *addr_cond0 = 0 ? &addr_x : 0;
+int *addr_cond1 = sizeof(int) == 4 ? addr_arr + 2 : addr_arr;
+_Static_assert(sizeof(int) == 4, "int is 4 bytes");
+char g58;
+_Alignas(32) char g59;
+struct {int a[3]; struct pair p;} g60 = {.a[1] = 5, 6, 7};
+int g61[2][3] = {1, 2, 3, [1][1] = 9, 10};
+struct {int k; union {int x; char c;}; struct {int y, z;};} g62 = {.y = 3, 4, .x = 5};
+struct aligned { char a; _Alignas(8) char b; _Static_assert(1, "in struct"); };
 
 extern int ext1;
 extern int *ext2;
@@ -50,6 +109,34 @@ typedef long long ll;
 
 static int static_fn(void) { return 3; }
 
+static int sg = 3;
+int tent;
+int tent;
+int tent = 5;
+int tent2;
+int common1;
+extern int arr_later[];
+int arr_later[3] = {1, 2, 3};
+// completed to have one element at the end of the file.
+long arr_tent[];
+
+static int sfn(void);
+int sfn(void) { return 4; }
+
+int static_a() {
+    static int i;
+    return ++i;
+}
+
+int static_b() {
+    static int i = 10;
+    {
+        static int i = 20;
+        i++;
+    }
+    return ++i;
+}
+
 int counter() {
     static int i;
     static int j = 1+1;
@@ -62,6 +149,482 @@ void store(int *x) {
     *x = 3;
 }
 
+int add1(int x) { return x+1; }
+int mul2(int x) { return x*2; }
+int apply(int (*f)(int), int x) { return f(x); }
+int (*fn_table[2])(int) = {add1, mul2};
+int (*g_fp)(int, int) = &add2;
+
+int (*getf(int k))(int);
+int (*getf(int k))(int) { return k ? mul2 : add1; }
+// returns a pointer to the row i of the global 2x3 array g35.
+int (*row_of(int i))[3] { return &g35[i]; }
+
+int cmp_int(int *a, int *b) { return *a - *b; }
+
+struct list;
+struct list { int val; struct list *next; };
+struct tree_a;
+struct tree_b { struct tree_a *a; int val; };
+struct tree_a { struct tree_b *b; int val; };
+enum color { RED, GREEN, BLUE };
+
+int str_len(const char *restrict s) {
+    int n=0;
+    while (*s++)
+        n++;
+    return n;
+}
+
+struct const_list { const struct const_list *next; int val; };
+
+int goto_sum(int n) {
+    int i=0, s=0;
+loop:
+    if (i > n)
+        goto end;
+    s += i++;
+    goto loop;
+end:
+    return s;
+}
+
+int goto_nested(int n) {
+    for (int i=0; i<10; i++)
+        for (int j=0; j<10; j++)
+            if (i*j == n)
+                goto found;
+    return -1;
+    {
+    found:
+    }
+    return n;
+}
+
+// the goto-cleanup idiom, where the label is followed by a null statement.
+int goto_cleanup(int n) {
+    int r = 0;
+    if (n < 0)
+        goto out;
+    r = n * 2;
+    ;
+out: ;
+    return r;
+}
+
+// a tiny threaded-dispatch interpreter: 0 halts, 1 increments, 2 doubles.
+int run_ops(char *ops) {
+    void *dispatch[3] = {&&op_halt, &&op_inc, &&op_dbl};
+    int acc=0;
+    goto *dispatch[*ops++];
+op_inc:
+    acc++;
+    goto *dispatch[*ops++];
+op_dbl:
+    acc*=2;
+    goto *dispatch[*ops++];
+op_halt:
+    return acc;
+}
+
+// the table is built only once, since it is static.
+int run_ops_static(char *ops) {
+    static void *dispatch[] = {&&op_halt, &&op_inc, &&op_dbl};
+    int acc=0;
+    goto *dispatch[*ops++];
+op_inc:
+    acc++;
+    goto *dispatch[*ops++];
+op_dbl:
+    acc*=2;
+    goto *dispatch[*ops++];
+op_halt:
+    return acc + sizeof(dispatch)/8*100;
+}
+
+struct pair tg_make_pair(long a, long b) { struct pair p = {a, b}; return p; }
+struct big tg_make_big(long a) { struct big b = {a, a*2, a*3}; return b; }
+long tg_sum_big(struct big b) { return b.a + b.b + b.c; }
+long tg_exhaust(long a, long b, long c, long d, long e, struct pair p, long f) {
+    return a+b+c+d+e + p.a*100 + p.b*1000 + f*10000;
+}
+
+struct odd tg_make_odd(int base) {
+    struct odd o;
+    for (int i=0; i<11; i++)
+        o.c[i] = base+i;
+    return o;
+}
+
+long tg_sum_odd(struct odd o) {
+    long sum = 0;
+    for (int i=0; i<11; i++)
+        sum += o.c[i];
+    return sum;
+}
+
+struct mid tg_swap_mid(struct mid m) {
+    struct mid r = m;
+    r.a = m.c;
+    r.c = m.a;
+    return r;
+}
+
+long tg_sum10(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j) {
+    return a + b*2 + c*3 + d*4 + e*5 + f*6 + g*7 + h*8 + i*9 + j*10;
+}
+
+long tg_mixed10(char a, short b, int c, long d, int e, int f, char g, short h, int i, long j) {
+    return a + b + c + d + e + f + g + h + i + j;
+}
+
+long tg_big_after_regs(long a, long b, long c, long d, long e, long f, struct big g, char h, struct pair i) {
+    return a+b+c+d+e+f + g.a + g.b + g.c + h + i.a + i.b;
+}
+
+int tg_format(char *buf, char *fmt, ...) {
+    va_list ap;
+    va_start(ap, fmt);
+    int n = vsprintf(buf, fmt, ap);
+    va_end(ap);
+    return n;
+}
+
+long tg_vsum(int n, ...) {
+    va_list ap;
+    va_start(ap, n);
+    long sum = 0;
+    for (int i = 0; i < n; i++)
+        sum = sum*2 + va_arg(ap, long);
+    va_end(ap);
+    return sum;
+}
+
+int tg_vmixed(char *s, ...) {
+    va_list ap;
+    va_start(ap, s);
+    int sum = 0;
+    for (; *s; s++) {
+        if (*s == 'i')
+            sum += va_arg(ap, int);
+        else if (*s == 'c')
+            sum += va_arg(ap, char);
+        else if (*s == 'p')
+            sum += *va_arg(ap, int *);
+        else if (*s == 's') {
+            struct pair p = va_arg(ap, struct pair);
+            sum += p.a*10 + p.b;
+        } else if (*s == 'b') {
+            struct big b = va_arg(ap, struct big);
+            sum += b.a*100 + b.b*10 + b.c;
+        }
+    }
+    va_end(ap);
+    return sum;
+}
+
+int tg_vtwice(int n, ...) {
+    va_list ap, aq;
+    va_start(ap, n);
+    va_copy(aq, ap);
+    int sum = 0;
+    for (int i = 0; i < n; i++)
+        sum += va_arg(ap, int);
+    for (int i = 0; i < n; i++)
+        sum += va_arg(aq, int)*10;
+    va_end(aq);
+    va_end(ap);
+    return sum;
+}
+
+long tg_vmember(int n, ...) {
+    va_list ap;
+    va_start(ap, n);
+    long sum = __builtin_va_arg(ap, struct big).c;
+    sum += va_arg(ap, struct pair).b*10;
+    va_end(ap);
+    return sum;
+}
+
+int vla_elem(int n, int m[][n], int i, int j) {
+    return m[i][j];
+}
+
+int vla_sum(int n, int a[n]) {
+    int sum = 0;
+    for (int i = 0; i < n; i++)
+        sum += a[i];
+    return sum;
+}
+
+// the storage of a VLA in a loop body is reused by every iteration, since it is freed at the end of the body.
+int vla_loop(int n) {
+    char *first = 0;
+    for (int i = 0; i < 100; i++) {
+        char a[n];
+        if (!first)
+            first = a;
+        if (a != first)
+            return 0;
+        if (i % 3 == 0)
+            continue;
+        char b[n];
+        if (i == 50)
+            break;
+    }
+    char c[n];
+    return c == first;
+}
+
+int vla_goto(int n) {
+    char *first = 0;
+    int i = 0;
+again:
+    {
+        char a[n];
+        if (!first)
+            first = a;
+        if (a != first)
+            return 0;
+        if (++i < 10)
+            goto again;
+        char b[n];
+        goto out;
+    }
+out:
+    {
+        char c[n];
+        return c == first && i == 10;
+    }
+}
+
+int vla_alloca(int n) {
+    char *p = alloca(n);
+    char *q = alloca(n);
+    for (int i = 0; i < n; i++)
+        p[i] = q[i] = i;
+    int sum = 0;
+    for (int i = 0; i < n; i++)
+        sum += p[i] + q[i];
+    return sum + (p - q == 16);
+}
+
+_Bool tg_to_bool(long x) {
+    return x;
+}
+
+int bool_arg(_Bool b) {
+    return b;
+}
+
+unsigned char uchar_ret(int x) {
+    return x;
+}
+
+long long_id(long x) {
+    return x;
+}
+
+int char_id(char c) {
+    return c;
+}
+
+int no_proto();
+
+int no_proto() {
+    return 3;
+}
+
+int list_sum(struct list *l) {
+    int sum=0;
+    for (; l; l=l->next)
+        sum+=l->val;
+    return sum;
+}
+
+extern char str_g[3];
+extern int str_gw[5];
+extern char *str_gp;
+
+struct __attribute__((packed)) attr_packed1 { char a; int b; };
+struct attr_packed2 { char a; long b; } __attribute__((__packed__));
+struct attr_packed3 { char a; int b __attribute__((packed)); short c; };
+struct __attribute__((packed, aligned(4))) attr_packed4 { char a; int b; };
+struct attr_aligned1 { char c; } __attribute__((aligned(8)));
+struct attr_aligned2 { char c; int x __attribute__((aligned(16))); };
+
+int attr_aligned_g __attribute__((aligned(64))) = 3;
+char attr_aligned_c __attribute__((aligned)) = 4;
+typedef int attr_aint __attribute__((aligned(16)));
+typedef struct {int a, b, c;} attr_astruct __attribute__((aligned(16)));
+struct attr_aint_member { char c; attr_aint x; };
+typedef long attr_along1 __attribute__((aligned(1)));
+typedef attr_along1 attr_along16 __attribute__((aligned(16)));
+struct attr_along1_member { char c; attr_along1 l; };
+enum __attribute__((packed)) attr_penum1 { ATTR_PA1, ATTR_PB1 = 200 };
+enum attr_penum2 { ATTR_PA2 = -300 } __attribute__((packed));
+int * __attribute__((aligned(16))) attr_aptr;
+struct attr_aptr_member { char c; int * __attribute__((aligned(1))) p; };
+char attr_pad_g;
+attr_aint attr_aint_g = 5;
+
+int attr_sec1 __attribute__((section("tgocc_set"))) = 1;
+// it is kept though never referenced.
+static int attr_sec2 __attribute__((section("tgocc_set"), used)) = 2;
+extern int __start_tgocc_set[], __stop_tgocc_set[];
+
+__attribute__((section(".text.tgocc"))) int attr_sec_fn(void) { return 10; }
+
+__attribute__((weak)) int attr_weak_fn(void) { return 1; }
+__attribute__((weak)) int attr_weak_var = 5;
+extern int attr_weak_undef(void) __attribute__((weak));
+
+int attr_alias_target(void) { return 7; }
+int attr_alias(void) __attribute__((alias("attr_alias_target")));
+int attr_alias_var_target = 8;
+extern int attr_alias_var __attribute__((alias("attr_alias_var_target")));
+
+__attribute__((visibility("hidden"))) int attr_hidden(void) { return 9; }
+
+static int attr_unused_fn(void) __attribute__((unused));
+static int attr_unused_fn(void) { return 0; }
+
+__attribute__((noreturn)) void attr_die(void) { exit(1); }
+
+static int attr_ctor_val;
+__attribute__((constructor(102))) static void attr_ctor2(void) { attr_ctor_val = attr_ctor_val * 10 + 2; }
+__attribute__((constructor(101))) static void attr_ctor1(void) { attr_ctor_val = attr_ctor_val * 10 + 1; }
+__attribute__((constructor)) static void attr_ctor3(void) { attr_ctor_val = attr_ctor_val * 10 + 3; }
+__attribute__((destructor)) static void attr_dtor(void) { attr_ctor_val = 0; }
+
+int cleanup_log;
+
+void cleanup_push(int *p) {
+    cleanup_log = cleanup_log * 10 + *p;
+}
+
+int cleanup_block(void) {
+    cleanup_log = 0;
+    {
+        int a __attribute__((cleanup(cleanup_push))) = 1;
+        int b __attribute__((cleanup(cleanup_push))) = 2;
+    }
+    return cleanup_log;
+}
+
+int cleanup_ret(void) {
+    int a __attribute__((cleanup(cleanup_push))) = 3;
+    a = 4;
+    return a + 1;
+}
+
+void cleanup_fall(void) {
+    int a __attribute__((cleanup(cleanup_push))) = 8;
+}
+
+int cleanup_loop(void) {
+    cleanup_log = 0;
+    for (int i = 1; i <= 5; i++) {
+        int x __attribute__((cleanup(cleanup_push))) = i;
+        if (i == 2)
+            continue;
+        if (i == 4)
+            break;
+    }
+    return cleanup_log;
+}
+
+int cleanup_goto(void) {
+    cleanup_log = 0;
+    {
+        int a __attribute__((cleanup(cleanup_push))) = 5;
+        {
+            int b __attribute__((cleanup(cleanup_push))) = 6;
+            goto out;
+        }
+    }
+out:
+    return cleanup_log;
+}
+
+int cleanup_switch(int x) {
+    int r = 0;
+    switch (x) {
+    case 1:
+        r = 1;
+        __attribute__((fallthrough));
+    case 2:
+        r += 2;
+        break;
+    }
+    return r;
+}
+
+asm(".globl asm_top_fn\n"
+    "asm_top_fn:\n"
+    "\tmov eax, 42\n"
+    "\tret");
+int asm_top_fn(void);
+
+long asm_add(long a, long b) {
+    asm("add %0, %1" : "+r"(a) : "r"(b));
+    return a;
+}
+
+int asm_rdtsc(void) {
+    unsigned lo, hi;
+    asm volatile("rdtsc" : "=a"(lo), "=d"(hi));
+    return lo != 0 || hi != 0;
+}
+
+int asm_callee_saved(void) {
+    long x;
+    __asm__ __volatile__("mov rbx, 3\n\tmov r12, 4\n\tlea %0, [rbx+r12]" : "=r"(x) : : "rbx", "r12");
+    return x;
+}
+
+int asm_unique(int x) {
+    // %= makes the labels of each asm distinct.
+    asm("test %0, %0\n\tjz .Lasm_zero%=\n\tmov %0, 1\n.Lasm_zero%=:" : "+r"(x));
+    asm("test %0, %0\n\tjz .Lasm_zero%=\n\tadd %0, 1\n.Lasm_zero%=:" : "+r"(x));
+    return x;
+}
+
+int pthread_create();
+int pthread_join();
+
+_Atomic int atomic_counter;
+_Atomic(long) atomic_lcounter;
+int atomic_plain;
+__thread int tls_counter = 100;
+_Thread_local int tls_bss;
+static thread_local char tls_arr[3] = {1, 2, 3};
+int tls_seen[4];
+int tls_ext_get(void);
+
+void *atomic_worker(void *arg) {
+    long id = (long)arg;
+    for (int i = 0; i < 10000; i++) {
+        atomic_counter++;
+        atomic_lcounter += 2;
+        __atomic_fetch_add(&atomic_plain, 1, __ATOMIC_SEQ_CST);
+        tls_counter++;
+    }
+    tls_bss = id;
+    tls_arr[1] += id;
+    tls_seen[id] = tls_counter * 100 + tls_bss * 10 + tls_arr[1] + tls_ext_get() - 1;
+    return 0;
+}
+
+int atomic_threads(void) {
+    unsigned long th[4];
+    for (long i = 0; i < 4; i++)
+        pthread_create(&th[i], 0, atomic_worker, (void *)i);
+    for (int i = 0; i < 4; i++)
+        pthread_join(th[i], 0);
+    return atomic_counter == 40000 && atomic_lcounter == 80000 && atomic_plain == 40000;
+}
+
 int main() {
     test(0, 0, "0");
     test(42, 42, "42");
@@ -487,8 +1050,8 @@ int main() {
 
     test(1, ({ struct {int a; int b;} x[2]={{1,2}}; x[0].a; }), "struct {int a; int b;} x[2]={{1,2}}; x[0].a;");
     test(2, ({ struct {int a; int b;} x[2]={{1,2}}; x[0].b; }), "struct {int a; int b;} x[2]={{1,2}}; x[0].b;");
-    /*test(0, ({ struct {int a; int b;} x[2]={{1,2}}; x[1].a; }), "struct {int a; int b;} x[2]={{1,2}}; x[1].a;");*/
-    /*test(0, ({ struct {int a; int b;} x[2]={{1,2}}; x[1].b; }), "struct {int a; int b;} x[2]={{1,2}}; x[1].b;");*/
+    test(0, ({ struct {int a; int b;} x[2]={{1,2}}; x[1].a; }), "struct {int a; int b;} x[2]={{1,2}}; x[1].a;");
+    test(0, ({ struct {int a; int b;} x[2]={{1,2}}; x[1].b; }), "struct {int a; int b;} x[2]={{1,2}}; x[1].b;");
 
     test(0, ({ struct {int a; int b;} x={}; x.a; }), "struct {int a; int b;} x={}; x.a;");
     test(0, ({ struct {int a; int b;} x={}; x.b; }), "struct {int a; int b;} x={}; x.b;");
@@ -568,6 +1131,648 @@ int main() {
 
     test(3, ({ volatile int i=3; i; }), "volatile int i=3; i;");
 
+    test(4, ({ int (*fp)(int) = add1; fp(3); }), "int (*fp)(int) = add1; fp(3);");
+    test(4, ({ int (*fp)(int) = &add1; (*fp)(3); }), "int (*fp)(int) = &add1; (*fp)(3);");
+    test(8, ({ int (*fp)(int, int) = add2; fp(3, 5); }), "int (*fp)(int, int) = add2; fp(3, 5);");
+    test(8, ({ int (*fp)(int, char*); sizeof(fp); }), "int (*fp)(int, char*); sizeof(fp);");
+    test(6, apply(add1, 5), "apply(add1, 5)");
+    test(10, apply(&mul2, 5), "apply(&mul2, 5)");
+    test(14, getf(1)(7), "getf(1)(7)");
+    test(8, getf(0)(7), "getf(0)(7)");
+    test(5, (*row_of(1))[1], "(*row_of(1))[1]");
+    test(4, fn_table[0](3), "fn_table[0](3)");
+    test(6, fn_table[1](3), "fn_table[1](3)");
+    test(6, ({ int (*t[2])(int) = {add1, mul2}; int i=1; t[i](3); }), "int (*t[2])(int) = {add1, mul2}; int i=1; t[i](3);");
+    test(16, ({ int (*t[2])(int); sizeof(t); }), "int (*t[2])(int); sizeof(t);");
+    test(7, g_fp(3, 4), "g_fp(3, 4)");
+    test(8, ({ struct handler { int (*handle)(int); } h; struct handler *s=&h; h.handle=mul2; s->handle(4); }),
+        "struct handler { int (*handle)(int); } h; struct handler *s=&h; h.handle=mul2; s->handle(4);");
+    test(3, ({ typedef int (*unary_t)(int); unary_t f=add1; f(2); }), "typedef int (*unary_t)(int); unary_t f=add1; f(2);");
+    test(2, ({ int x[4]={3,1,2,0}; qsort(x, 4, sizeof(int), cmp_int); x[2]; }),
+        "int x[4]={3,1,2,0}; qsort(x, 4, sizeof(int), cmp_int); x[2];");
+
+    test(8, ({ union {int a; char b[6];} x; sizeof(x); }), "union {int a; char b[6];} x; sizeof(x);");
+    test(4, ({ union {int a; char b[4];} x; sizeof(x); }), "union {int a; char b[4];} x; sizeof(x);");
+    test(8, ({ union {char a; long b;} x; sizeof(x); }), "union {char a; long b;} x; sizeof(x);");
+    test(3, ({ union {int a; char b[4];} x; x.a=515; x.b[0]; }), "union {int a; char b[4];} x; x.a=515; x.b[0];");
+    test(2, ({ union {int a; char b[4];} x; x.a=515; x.b[1]; }), "union {int a; char b[4];} x; x.a=515; x.b[1];");
+    test(0, ({ union {int a; char b[4];} x; x.a=515; x.b[2]; }), "union {int a; char b[4];} x; x.a=515; x.b[2];");
+    test(4, ({ union {int a; char b[4];} x; x.b[0]=4; x.b[1]=0; x.b[2]=0; x.b[3]=0; x.a; }),
+        "union {int a; char b[4];} x; x.b[0]=4; x.b[1]=0; x.b[2]=0; x.b[3]=0; x.a;");
+    test(7, ({ union u {int a; char b;} x; union u *y=&x; y->a=7; x.b; }), "union u {int a; char b;} x; union u *y=&x; y->a=7; x.b;");
+    test(3, ({ union {int a; char b;} x={3}; x.a; }), "union {int a; char b;} x={3}; x.a;");
+    test(5, ({ union {int a; char b;} x={.b=5}; x.a; }), "union {int a; char b;} x={.b=5}; x.a;");
+    test(16, ({ struct {char a; union {int b; long c;}; } x; sizeof(x); }), "struct {char a; union {int b; long c;}; } x; sizeof(x);");
+    test(9, ({ struct {char a; union {int b; long c;}; } x; x.c=9; x.b; }), "struct {char a; union {int b; long c;}; } x; x.c=9; x.b;");
+    test(2, ({ struct {char a; union {int b; long c;}; } x={1, {2}}; x.b; }), "struct {char a; union {int b; long c;}; } x={1, {2}}; x.b;");
+    test(4, ({ union {struct {char a; char b;}; short c;} x; x.c=0x0304; x.a; }), "union {struct {char a; char b;}; short c;} x; x.c=0x0304; x.a;");
+    test(3, ({ union {struct {char a; char b;}; short c;} x; x.c=0x0304; x.b; }), "union {struct {char a; char b;}; short c;} x; x.c=0x0304; x.b;");
+
+    test(4, g20.b[0], "g20.b[0]");
+    test(1, g20.b[3], "g20.b[3]");
+    test(8, sizeof(g20), "sizeof(g20)");
+    test(7, g21.b, "g21.b");
+    test(1, g22.a, "g22.a");
+    test(2, g22.b, "g22.b");
+    test(3, g22.d, "g22.d");
+    test(1, g23[0][0], "g23[0][0]");
+    test(0, g23[1][1], "g23[1][1]");
+    test(16, sizeof(g23), "sizeof(g23)");
+
+    test(4, ({ struct {unsigned x;} s; sizeof(s); }), "struct {unsigned x;} s; sizeof(s);");
+    test(4294967295, ({ unsigned x=-1; x; }), "unsigned x=-1; x;");
+    test(255, ({ unsigned char x=-1; x; }), "unsigned char x=-1; x;");
+    test(65535, ({ unsigned short x=-1; x; }), "unsigned short x=-1; x;");
+    test(200, (unsigned char)200, "(unsigned char)200");
+    test(-56, (char)200, "(char)200");
+
+    test(8, ({ struct {int a:3; int b:5; int c:25;} x; sizeof(x); }), "struct {int a:3; int b:5; int c:25;} x; sizeof(x);");
+    test(2, ({ struct {char a:3; char b:6;} x; sizeof(x); }), "struct {char a:3; char b:6;} x; sizeof(x);");
+    test(4, ({ struct {int a:3; char b;} x; sizeof(x); }), "struct {int a:3; char b;} x; sizeof(x);");
+    test(8, ({ struct {int a:3; int :0; int b:2;} x; sizeof(x); }), "struct {int a:3; int :0; int b:2;} x; sizeof(x);");
+    test(8, ({ struct {char a; int b:4; long c:40; short d:3;} x; sizeof(x); }),
+        "struct {char a; int b:4; long c:40; short d:3;} x; sizeof(x);");
+    test(8, ({ struct {unsigned a:31; unsigned b:2;} x; sizeof(x); }), "struct {unsigned a:31; unsigned b:2;} x; sizeof(x);");
+    test(2, ({ struct {char a; int :4;} x; sizeof(x); }), "struct {char a; int :4;} x; sizeof(x);");
+    test(4, ({ union {int a:3; char b;} x; sizeof(x); }), "union {int a:3; char b;} x; sizeof(x);");
+    test(3, ({ struct {int a:3; int b:5; int c:25;} x; x.c=0; x.b=-1; x.a=3; x.a; }),
+        "struct {int a:3; int b:5; int c:25;} x; x.c=0; x.b=-1; x.a=3; x.a;");
+    test(-1, ({ struct {int a:3; int b:5; int c:25;} x; x.c=0; x.b=-1; x.a=3; x.b; }),
+        "struct {int a:3; int b:5; int c:25;} x; x.c=0; x.b=-1; x.a=3; x.b;");
+    test(0, ({ struct {int a:3; int b:5; int c:25;} x; x.c=0; x.b=-1; x.a=3; x.c; }),
+        "struct {int a:3; int b:5; int c:25;} x; x.c=0; x.b=-1; x.a=3; x.c;");
+    test(-4, ({ struct {int a:3;} x; x.a=4; x.a; }), "struct {int a:3;} x; x.a=4; x.a;");
+    test(4, ({ struct {unsigned a:3;} x; x.a=4; x.a; }), "struct {unsigned a:3;} x; x.a=4; x.a;");
+    test(-4, ({ struct {int a:3;} x; x.a=4; }), "struct {int a:3;} x; x.a=4;");
+    test(2147483647, ({ struct {unsigned a:31; unsigned b:2;} x; x.a=0x7fffffff; x.b=3; x.a; }),
+        "struct {unsigned a:31; unsigned b:2;} x; x.a=0x7fffffff; x.b=3; x.a;");
+    test(3, ({ struct {unsigned a:31; unsigned b:2;} x; x.a=0x7fffffff; x.b=3; x.b; }),
+        "struct {unsigned a:31; unsigned b:2;} x; x.a=0x7fffffff; x.b=3; x.b;");
+    test(5, ({ struct {char a:4; char b:4;} x; x.a=1; x.b=7; x.a+=4; x.a; }), "struct {char a:4; char b:4;} x; x.a=1; x.b=7; x.a+=4; x.a;");
+    test(7, ({ struct {char a:4; char b:4;} x; x.a=1; x.b=7; x.a+=4; x.b; }), "struct {char a:4; char b:4;} x; x.a=1; x.b=7; x.a+=4; x.b;");
+    test(0, ({ struct {unsigned a:2; unsigned b:2;} x; x.b=1; x.a=3; x.a++; x.a; }), "struct {unsigned a:2; unsigned b:2;} x; x.b=1; x.a=3; x.a++; x.a;");
+    test(1, ({ struct {unsigned a:2; unsigned b:2;} x; x.b=1; x.a=3; x.a++; x.b; }), "struct {unsigned a:2; unsigned b:2;} x; x.b=1; x.a=3; x.a++; x.b;");
+    test(3, ({ struct {unsigned a:2; unsigned b:2;} x; x.b=1; x.a=0; x.a--; x.a; }), "struct {unsigned a:2; unsigned b:2;} x; x.b=1; x.a=0; x.a--; x.a;");
+    test(1, ({ struct {_Bool a:1; int b:3;} x; x.a=2; x.a; }), "struct {_Bool a:1; int b:3;} x; x.a=2; x.a;");
+    test(-2, ({ struct {int a:3; int b:4;} x={1, -2}; x.b; }), "struct {int a:3; int b:4;} x={1, -2}; x.b;");
+    test(123456789012, ({ struct {char a; long c:40;} x; x.c=123456789012; x.c; }), "struct {char a; long c:40;} x; x.c=123456789012; x.c;");
+    test(1, ({ struct {unsigned a:3;} x; x.a=1; x.a - 2 < 0; }), "struct {unsigned a:3;} x; x.a=1; x.a - 2 < 0;");
+    test(-1, ({ struct {unsigned a:3;} x; x.a=1; (long)(x.a - 2); }), "struct {unsigned a:3;} x; x.a=1; (long)(x.a - 2);");
+    test(1, ({ struct {unsigned a:3;} x; _Generic(x.a + 0, int: 1, unsigned: 2); }),
+        "struct {unsigned a:3;} x; _Generic(x.a + 0, int: 1, unsigned: 2);");
+    test(1, ({ struct {unsigned long a:3;} x; _Generic(~x.a, int: 1, default: 2); }),
+        "struct {unsigned long a:3;} x; _Generic(~x.a, int: 1, default: 2);");
+    test(2, ({ struct {unsigned a:32;} x; _Generic(x.a + 0, int: 1, unsigned: 2); }),
+        "struct {unsigned a:32;} x; _Generic(x.a + 0, int: 1, unsigned: 2);");
+
+    test(4, sizeof(g24), "sizeof(g24)");
+    test(3, g24.mode, "g24.mode");
+    test(1, g24.en, "g24.en");
+    test(-5, g24.level, "g24.level");
+    test(200, g24.irq, "g24.irq");
+    test(0, memcmp(&g24, "\337\200\014\0", 4), "memcmp(&g24, \"\\337\\200\\014\\0\", 4)");
+    test(-3, g25.b, "g25.b");
+    test(123456789012, g25.c, "g25.c");
+    test(2, g25.d, "g25.d");
+    test(0, memcmp(&g25, "\001\115\241\221\351\313\041\0", 8), "memcmp(&g25, \"\\001\\115\\241\\221\\351\\313\\041\\0\", 8)");
+
+    test(17, ({ struct {char a; char c[16];} x; sizeof(x); }), "struct {char a; char c[16];} x; sizeof(x);");
+    test(24, ({ struct {char a[16]; long b;} x; sizeof(x); }), "struct {char a[16]; long b;} x; sizeof(x);");
+    test(12, ({ struct {char a; struct {char b[3]; int c;} d;} x; sizeof(x); }),
+        "struct {char a; struct {char b[3]; int c;} d;} x; sizeof(x);");
+    test(6, ({ struct list c={3, 0}; struct list b={2, &c}; struct list a={1, &b}; list_sum(&a); }),
+        "struct list c={3, 0}; struct list b={2, &c}; struct list a={1, &b}; list_sum(&a);");
+    test(16, sizeof(struct list), "sizeof(struct list)");
+    test(5, ({ struct tree_a a; struct tree_b b; a.b=&b; b.a=&a; b.val=5; a.b->a->b->val; }),
+        "struct tree_a a; struct tree_b b; a.b=&b; b.a=&a; b.val=5; a.b->a->b->val;");
+    test(3, ({ struct t; struct t *p; struct t {int a; int b;} x; p=&x; x.b=3; p->b; }),
+        "struct t; struct t *p; struct t {int a; int b;} x; p=&x; x.b=3; p->b;");
+    test(8, ({ struct t *p; struct t {int a; int b;}; sizeof(*p); }), "struct t *p; struct t {int a; int b;}; sizeof(*p);");
+    test(4, ({ struct {int n; int d[];} x; sizeof(x); }), "struct {int n; int d[];} x; sizeof(x);");
+    test(8, ({ struct {char n; long d[];} x; sizeof(x); }), "struct {char n; long d[];} x; sizeof(x);");
+    test(7, ({ int buf[4]; struct flex {int n; int d[];} *f=buf; f->d[2]=7; buf[3]; }),
+        "int buf[4]; struct flex {int n; int d[];} *f=buf; f->d[2]=7; buf[3];");
+    test(2, BLUE, "BLUE");
+
+    test(3, ({ const int x=3; x; }), "const int x=3; x;");
+    test(3, ({ int const x=3; x; }), "int const x=3; x;");
+    test(5, ({ const static int x=5; x; }), "const static int x=5; x;");
+    test(4, ({ int x=4; const int *p=&x; *p; }), "int x=4; const int *p=&x; *p;");
+    test(7, ({ int x=4; int y=7; const int *p=&x; p=&y; *p; }), "int x=4; int y=7; const int *p=&x; p=&y; *p;");
+    test(6, ({ int x=4; int *const p=&x; *p=6; x; }), "int x=4; int *const p=&x; *p=6; x;");
+    test(8, ({ int x=8; int *q=&x; int *const *const pp=&q; **pp; }), "int x=8; int *q=&x; int *const *const pp=&q; **pp;");
+    test(8, sizeof(const char *volatile), "sizeof(const char *volatile)");
+    test(2, ({ volatile int x=1; x++; x; }), "volatile int x=1; x++; x;");
+    test(3, ({ volatile struct {int a; int b;} s; s.b=3; s.b; }), "volatile struct {int a; int b;} s; s.b=3; s.b;");
+    test(5, ({ char *restrict p="hello"; str_len(p); }), "char *restrict p=\"hello\"; str_len(p);");
+    test(5, ({ char *__restrict p="hello"; str_len(p); }), "char *__restrict p=\"hello\"; str_len(p);");
+    test(3, ({ typedef const int cint; cint x=3; x; }), "typedef const int cint; cint x=3; x;");
+    test(2, ({ const int a[]={1, 2}; a[1]; }), "const int a[]={1, 2}; a[1];");
+    test(3, ({ struct const_list c={0, 3}; const struct const_list l={&c, 1}; l.next->val; }),
+        "struct const_list c={0, 3}; const struct const_list l={&c, 1}; l.next->val;");
+    test(26, g26, "g26");
+    test(6, str_len(g28), "str_len(g28)");
+    test('d', g27[2], "g27[2]");
+
+    test(3, ({ int a=1, b=2; a+b; }), "int a=1, b=2; a+b;");
+    test(5, ({ int a=5, *b=&a; *b; }), "int a=5, *b=&a; *b;");
+    test(16, ({ int a, *b, c[3]; sizeof(a)+sizeof(c)-sizeof(b)+8; }), "int a, *b, c[3]; sizeof(a)+sizeof(c)-sizeof(b)+8;");
+    test(6, ({ int a[]={1, 2, 3}, b=a[2]; a[0]+a[1]+b; }), "int a[]={1, 2, 3}, b=a[2]; a[0]+a[1]+b;");
+    test(3, ({ int s=0; for (int i=0, j=3; i<j; i++) s++; s; }), "int s=0; for (int i=0, j=3; i<j; i++) s++; s;");
+    test(4, ({ static int a=1, b=3; a+b; }), "static int a=1, b=3; a+b;");
+    test(29, *g30, "*g30");
+    test(3, g31[2], "g31[2]");
+    test(0, g32, "g32");
+    test(4, sizeof(int_t), "sizeof(int_t)");
+    test(8, sizeof(int_ptr_t), "sizeof(int_ptr_t)");
+    test(16, sizeof(int_arr_t), "sizeof(int_arr_t)");
+    test(24, ({ struct {int a, b; char *c, d;} x; sizeof(x); }), "struct {int a, b; char *c, d;} x; sizeof(x);");
+    test(7, ({ struct {int a, *b;} x; x.a=7; x.b=&x.a; *x.b; }), "struct {int a, *b;} x; x.a=7; x.b=&x.a; *x.b;");
+    test(8, ({ struct {int a:3, b:5, :0, c:4;} x; sizeof(x); }), "struct {int a:3, b:5, :0, c:4;} x; sizeof(x);");
+    test(10, sizeof(char[10]), "sizeof(char[10])");
+    test(24, sizeof(struct list *[3]), "sizeof(struct list *[3])");
+    test(8, sizeof(int (*)[4]), "sizeof(int (*)[4])");
+    test(8, sizeof(int (*)(int)), "sizeof(int (*)(int))");
+    test(48, sizeof(int [3][4]), "sizeof(int [3][4])");
+    test(6, ({ int a[2][4]={{1, 2, 3, 4}, {5, 6, 7, 8}}; int *p=a; (*(int (*)[4])(p+4))[1]; }),
+        "int a[2][4]={{1, 2, 3, 4}, {5, 6, 7, 8}}; int *p=a; (*(int (*)[4])(p+4))[1];");
+    test(4, ({ int (*f)(int)=(int (*)(int))add1; f(3); }), "int (*f)(int)=(int (*)(int))add1; f(3);");
+
+    test(2, 17%5, "17%5");
+    test(-2, -17%5, "-17%5");
+    test(4, 1+11%4*1, "1+11%4*1");
+    test(2, ({ int i=17; i%=5; i; }), "int i=17; i%=5; i;");
+    test(2, ({ int i=6; i&=3; i; }), "int i=6; i&=3; i;");
+    test(7, ({ int i=6; i|=3; i; }), "int i=6; i|=3; i;");
+    test(5, ({ int i=6; i^=3; i; }), "int i=6; i^=3; i;");
+    test(24, ({ int i=3; i<<=3; i; }), "int i=3; i<<=3; i;");
+    test(3, ({ int i=24; i>>=3; i; }), "int i=24; i>>=3; i;");
+    test(-3, ({ int i=-24; i>>=3; i; }), "int i=-24; i>>=3; i;");
+    test(16, ({ int i=1; i<<=2<<1; i; }), "int i=1; i<<=2<<1; i;");
+    test(14, ({ int i=3, j=2; i<<=j<<=0; i+j; }), "int i=3, j=2; i<<=j<<=0; i+j;");
+    test(16, 1<<2<<2, "1<<2<<2");
+    test(2, 32>>2>>2, "32>>2>>2");
+    test(11, ({ int a[3]={1, 2, 3}; int i=0; a[i++]+=10; a[0]+i-1; }), "int a[3]={1, 2, 3}; int i=0; a[i++]+=10; a[0]+i-1;");
+    test(1, ({ int a[3]={1, 2, 3}; int i=0; a[i++]<<=1; i; }), "int a[3]={1, 2, 3}; int i=0; a[i++]<<=1; i;");
+    test(3, ({ struct {int a:4; int b:4;} x={1, 2}; x.b|=1; x.b; }), "struct {int a:4; int b:4;} x={1, 2}; x.b|=1; x.b;");
+    test(3, (1, 2, 3), "(1, 2, 3)");
+    test(6, ({ int i=2, j=3; i=(j++, i+j); i; }), "int i=2, j=3; i=(j++, i+j); i;");
+    test(70, ({ int i, j, s=0; for (i=0, j=10; i<j; i++, j--) s+=i*j; s; }), "int i, j, s=0; for (i=0, j=10; i<j; i++, j--) s+=i*j; s;");
+    test(8, ({ long l; sizeof((char)1, l); }), "long l; sizeof((char)1, l);");
+    test(2, ({ char a[7%5]; sizeof(a); }), "char a[7%5]; sizeof(a);");
+
+    test(55, goto_sum(10), "goto_sum(10)");
+    test(12, goto_nested(12), "goto_nested(12)");
+    test(-1, goto_nested(97), "goto_nested(97)");
+    test(0, goto_cleanup(-3), "goto_cleanup(-3)");
+    test(8, goto_cleanup(4), "goto_cleanup(4)");
+    test(10, run_ops("\001\001\002\001\002\000"), "run_ops(\"\\001\\001\\002\\001\\002\\000\")");
+    test(310, run_ops_static("\001\001\002\001\002\000"), "run_ops_static(\"\\001\\001\\002\\001\\002\\000\")");
+    test(302, run_ops_static("\001\002\000"), "run_ops_static(\"\\001\\002\\000\")");
+    test(3, ({ int i=0; goto skip; i=5; skip: i+=3; i; }), "int i=0; goto skip; i=5; skip: i+=3; i;");
+    test(9, ({ void *p=&&here; int n=1; here: n+=sizeof(p); n; }), "void *p=&&here; int n=1; here: n+=sizeof(p); n;");
+
+    test(3, ({ struct {int a; int b;} x, y; x.a=1; x.b=2; y=x; y.a+y.b; }), "struct {int a; int b;} x, y; x.a=1; x.b=2; y=x; y.a+y.b;");
+    test(66, ({ struct odd a=tg_make_odd(1), b; b=a; tg_sum_odd(b); }), "struct odd a=tg_make_odd(1), b; b=a; tg_sum_odd(b);");
+    test(7, ({ struct pair p={3, 4}, *q=&p; struct pair r=*q; r.a+r.b; }), "struct pair p={3, 4}, *q=&p; struct pair r=*q; r.a+r.b;");
+    test(4, ({ struct {struct pair p; int x;} s; s.p=tg_make_pair(1, 3); s.p.a+s.p.b; }), "struct {struct pair p; int x;} s; s.p=tg_make_pair(1, 3); s.p.a+s.p.b;");
+    test(7, gcc_sum_small(gcc_make_small(3, 4)), "gcc_sum_small(gcc_make_small(3, 4))");
+    test(-98, ({ struct small s=gcc_make_small(2, -100); s.a+s.b; }), "struct small s=gcc_make_small(2, -100); s.a+s.b;");
+    test(11, gcc_sum_pair(gcc_make_pair(5, 6)), "gcc_sum_pair(gcc_make_pair(5, 6))");
+    test(55, ({ struct odd o=gcc_make_odd(0); gcc_sum_odd(o); }), "struct odd o=gcc_make_odd(0); gcc_sum_odd(o);");
+    test(66, tg_sum_odd(gcc_make_odd(1)), "tg_sum_odd(gcc_make_odd(1))");
+    test(8, ({ struct mid m=gcc_make_mid(1, 2, 5); gcc_sum_mid(m); }), "struct mid m=gcc_make_mid(1, 2, 5); gcc_sum_mid(m);");
+    test(5, tg_swap_mid(gcc_make_mid(1, 2, 5)).a, "tg_swap_mid(gcc_make_mid(1, 2, 5)).a");
+    test(24, sizeof(gcc_make_big(1)), "sizeof(gcc_make_big(1))");
+    test(42, gcc_sum_big(gcc_make_big(7)), "gcc_sum_big(gcc_make_big(7))");
+    test(14, gcc_make_big(7).b, "gcc_make_big(7).b");
+    test(43, ({ struct pair a={1, 2}, b={3, 4}; (a=b).b*10+a.a; }), "struct pair a={1, 2}, b={3, 4}; (a=b).b*10+a.a;");
+    test(3, ({ struct pair s1={1, 2}, s2={3, 4}; int c=0; (c ? s1 : s2).a; }), "struct pair s1={1, 2}, s2={3, 4}; int c=0; (c ? s1 : s2).a;");
+    test(6, ({ struct pair s={5, 6}; (0, s).b; }), "struct pair s={5, 6}; (0, s).b;");
+    test(7, ({ struct pair s={7, 8}; ({ s; }).a; }), "struct pair s={7, 8}; ({ s; }).a;");
+    test(4, ({ struct pair s[2]={{1, 2}, {3, 4}}; (s+1)->b; }), "struct pair s[2]={{1, 2}, {3, 4}}; (s+1)->b;");
+    test(9, ({ struct {struct pair p;} s={{8, 9}}, t; (t=s).p.b; }), "struct {struct pair p;} s={{8, 9}}, t; (t=s).p.b;");
+    test(42, tg_sum_big(tg_make_big(7)), "tg_sum_big(tg_make_big(7))");
+    test(1607, ({ struct big b={1, 2, 3}; gcc_mixed(1, b, tg_make_pair(0, 0), 7)+0*gcc_sum_big(b); }),
+        "struct big b={1, 2, 3}; gcc_mixed(1, b, tg_make_pair(0, 0), 7)+0*gcc_sum_big(b);");
+    test(87605, gcc_exhaust(1, 1, 1, 1, 1, gcc_make_pair(6, 7), 8), "gcc_exhaust(1, 1, 1, 1, 1, gcc_make_pair(6, 7), 8)");
+    test(87605, tg_exhaust(1, 1, 1, 1, 1, tg_make_pair(6, 7), 8), "tg_exhaust(1, 1, 1, 1, 1, tg_make_pair(6, 7), 8)");
+    test(87714, gcc_call_tgocc(), "gcc_call_tgocc()");
+
+    test(385, gcc_sum10(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), "gcc_sum10(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)");
+    test(385, tg_sum10(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), "tg_sum10(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)");
+    test(-55, gcc_mixed10(-1, -2, -3, -4, -5, -6, -7, -8, -9, -10), "gcc_mixed10(-1, -2, -3, -4, -5, -6, -7, -8, -9, -10)");
+    test(-55, tg_mixed10(-1, -2, -3, -4, -5, -6, -7, -8, -9, -10), "tg_mixed10(-1, -2, -3, -4, -5, -6, -7, -8, -9, -10)");
+    test(330, gcc_call_tgocc_many(), "gcc_call_tgocc_many()");
+    test(0, gcc_frame_align(), "gcc_frame_align()");
+    test(0, gcc_frame_align7(1, 2, 3, 4, 5, 6, 7), "gcc_frame_align7(1, 2, 3, 4, 5, 6, 7)");
+    test(0, ({ int x=1; gcc_frame_align7(x, 2, 3, 4, 5, 6, 7); }), "int x=1; gcc_frame_align7(x, 2, 3, 4, 5, 6, 7);");
+    test(55, tg_sum10(1, 1, 1, 1, 1, 1, 1, 1, 1, tg_sum10(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)-54), "tg_sum10(1, 1, 1, 1, 1, 1, 1, 1, 1, tg_sum10(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)-54)");
+    test(27, ({ struct big b={2, 3, 4}; struct pair p={5, 6}; tg_big_after_regs(0, 0, 0, 0, 0, 1, b, 6, p); }),
+        "struct big b={2, 3, 4}; struct pair p={5, 6}; tg_big_after_regs(0, 0, 0, 0, 0, 1, b, 6, p);");
+
+    test(5, ({ char buf[16]; tg_format(buf, "%d-%s", 42, "ab"); }), "char buf[16]; tg_format(buf, \"%d-%s\", 42, \"ab\");");
+    test(0, ({ char buf[16]; tg_format(buf, "%d-%s", 42, "ab"); strcmp(buf, "42-ab"); }), "char buf[16]; tg_format(buf, \"%d-%s\", 42, \"ab\"); strcmp(buf, \"42-ab\");");
+    test(0, ({ char buf[32]; tg_format(buf, "%d%d%d%d%d%d%d", 1, 2, 3, 4, 5, 6, 7); strcmp(buf, "1234567"); }),
+        "char buf[32]; tg_format(buf, \"%d%d%d%d%d%d%d\", 1, 2, 3, 4, 5, 6, 7); strcmp(buf, \"1234567\");");
+    test(121, gcc_call_tg_format(), "gcc_call_tg_format()");
+    test(0, tg_vsum(0), "tg_vsum(0)");
+    test(11, tg_vsum(3, 1, 2, 3), "tg_vsum(3, 1, 2, 3)");
+    test(1013, tg_vsum(9, 1, 2, 3, 4, 5, 6, 7, 8, 9), "tg_vsum(9, 1, 2, 3, 4, 5, 6, 7, 8, 9)");
+    test(1013, gcc_call_tg_vsum(), "gcc_call_tg_vsum()");
+    test(-1, tg_vsum(1, -1), "tg_vsum(1, -1)");
+    test(13, ({ int x=7; tg_vmixed("icp", 2, 4, &x); }), "int x=7; tg_vmixed(\"icp\", 2, 4, &x);");
+    test(35, tg_vmixed("si", tg_make_pair(3, 4), 1), "tg_vmixed(\"si\", tg_make_pair(3, 4), 1)");
+    test(39, tg_vmixed("iiiisi", 1, 1, 1, 1, tg_make_pair(3, 4), 1), "tg_vmixed(\"iiiisi\", 1, 1, 1, 1, tg_make_pair(3, 4), 1)");
+    test(133, tg_vmixed("bi", tg_make_big(1), 10), "tg_vmixed(\"bi\", tg_make_big(1), 10)");
+    test(66, tg_vtwice(3, 1, 2, 3), "tg_vtwice(3, 1, 2, 3)");
+    test(53, ({ struct big b={1, 2, 3}; struct pair p={4, 5}; tg_vmember(2, b, p); }), "struct big b={1, 2, 3}; struct pair p={4, 5}; tg_vmember(2, b, p);");
+
+    test(94301, ({ int x[6]={[2]=3, 4, [0]=1, [4 ... 5]=9}; x[0]+x[1]*10+x[2]*100+x[3]*1000+x[5]*10000; }),
+        "int x[6]={[2]=3, 4, [0]=1, [4 ... 5]=9}; x[0]+x[1]*10+x[2]*100+x[3]*1000+x[5]*10000;");
+    test(20, ({ int x[]={[3]=1, 2}; sizeof(x); }), "int x[]={[3]=1, 2}; sizeof(x);");
+    test(5, ({ int x[2][3]={1, 2, 3, 4, 5}; x[1][1]+x[1][2]*10; }), "int x[2][3]={1, 2, 3, 4, 5}; x[1][1]+x[1][2]*10;");
+    test(12, ({ struct pair p={.b=2, .a=1}; p.a*10+p.b; }), "struct pair p={.b=2, .a=1}; p.a*10+p.b;");
+    test(7, ({ int x[3]={1, 2, 3, [1]=7}; x[1]; }), "int x[3]={1, 2, 3, [1]=7}; x[1];");
+    test(52, ({ struct pair p={1, 2, .a=5}; p.a*10+p.b; }), "struct pair p={1, 2, .a=5}; p.a*10+p.b;");
+    test(70053, ({ struct {int a[3]; struct pair p;} s={.a[1]=5, .p.b=7, .a[0]=3}; s.a[0]+s.a[1]*10+s.a[2]*100+s.p.a*1000+s.p.b*10000; }),
+        "struct {int a[3]; struct pair p;} s={.a[1]=5, .p.b=7, .a[0]=3}; s.a[0]+s.a[1]*10+s.a[2]*100+s.p.a*1000+s.p.b*10000;");
+    test(34, ({ struct pair ps[2]={1, 2, 3, 4}; ps[1].a*10+ps[1].b; }), "struct pair ps[2]={1, 2, 3, 4}; ps[1].a*10+ps[1].b;");
+    test(634, ({ struct pair q={5, 6}; struct pair ps[2]={q, 3, 4}; ps[0].b*100+ps[1].a*10+ps[1].b; }),
+        "struct pair q={5, 6}; struct pair ps[2]={q, 3, 4}; ps[0].b*100+ps[1].a*10+ps[1].b;");
+    test(102, ({ struct {char s[4]; int n;} x[2]={"ab", 1, "cd", 2}; x[1].s[1]+x[1].n; }), "struct {char s[4]; int n;} x[2]={\"ab\", 1, \"cd\", 2}; x[1].s[1]+x[1].n;");
+    test(256, ({ union {int i; char c[4];} u={.c[1]=1}; u.i; }), "union {int i; char c[4];} u={.c[1]=1}; u.i;");
+    test(3, ({ int x={3}; x; }), "int x={3}; x;");
+    test(2, (struct pair){1, 2}.b, "(struct pair){1, 2}.b");
+    test(3, ((int[]){1, 2, 3})[2], "((int[]){1, 2, 3})[2]");
+    test(12, sizeof((int[]){1, 2, 3}), "sizeof((int[]){1, 2, 3})");
+    test(5, ({ int *p=(int[]){4, 5}; p[1]; }), "int *p=(int[]){4, 5}; p[1];");
+    test(3, ({ struct pair *p=&(struct pair){.b=3}; p->a*10+p->b; }), "struct pair *p=&(struct pair){.b=3}; p->a*10+p->b;");
+    test(6, ({ int s=0; for (int i=0; i<3; i++) { int *p=(int[]){i, i}; s+=p[0]+p[1]; } s; }),
+        "int s=0; for (int i=0; i<3; i++) { int *p=(int[]){i, i}; s+=p[0]+p[1]; } s;");
+    test(7, gcc_sum_pair((struct pair){3, 4}), "gcc_sum_pair((struct pair){3, 4})");
+    test(4, ({ int x=(int){4}; x; }), "int x=(int){4}; x;");
+    test(94301, g33[0]+g33[1]*10+g33[2]*100+g33[3]*1000+g33[5]*10000, "g33[0]+g33[1]*10+g33[2]*100+g33[3]*1000+g33[5]*10000");
+    test(12, g34.a*10+g34.b, "g34.a*10+g34.b");
+    test(5, g35[1][1]+g35[1][2]*10, "g35[1][1]+g35[1][2]*10");
+    test(7, g36[2], "g36[2]");
+    test(89, g37->a*10+g37->b, "g37->a*10+g37->b");
+    test(70053, g38.a[0]+g38.a[1]*10+g38.a[2]*100+g38.p.a*1000+g38.p.b*10000, "g38.a[0]+g38.a[1]*10+g38.a[2]*100+g38.p.a*1000+g38.p.b*10000");
+    test(256, g39.i, "g39.i");
+    test(20, sizeof(g40), "sizeof(g40)");
+    test(2, g40[4], "g40[4]");
+    test(34, g41[1].a*10+g41[1].b, "g41[1].a*10+g41[1].b");
+    test(13, *g43, "*g43");
+    test(3, *g45, "*g45");
+    test(0, strcmp(g46, "llo"), "strcmp(g46, \"llo\")");
+    test(29, *(int *)g47, "*(int *)g47");
+    test(2, g48->b, "g48->b");
+    test(49, *g49[0]+*g49[1]+*g49[2]+*g49[3], "*g49[0]+*g49[1]+*g49[2]+*g49[3]");
+    test(6, *g51, "*g51");
+    test(4, g52[0], "g52[0]");
+    test(4, *g53, "*g53");
+    test(1, ({ char x[3>=2]; sizeof(x); }), "char x[3>=2]; sizeof(x);");
+    test(1, ({ char x[2 && 4]; sizeof(x); }), "char x[2 && 4]; sizeof(x);");
+    test(1, ({ char x[1 || 0]; sizeof(x); }), "char x[1 || 0]; sizeof(x);");
+    test(1, ({ char x[1 || 1/0]; sizeof(x); }), "char x[1 || 1/0]; sizeof(x);");
+    test(1, ({ char x[(unsigned char)257]; sizeof(x); }), "char x[(unsigned char)257]; sizeof(x);");
+    test(2, ({ char x[(int)-1 < (unsigned)0 ? 1 : 2]; sizeof(x); }), "char x[(int)-1 < (unsigned)0 ? 1 : 2]; sizeof(x);");
+    test(3, ({ char x[7 % 4]; sizeof(x); }), "char x[7 % 4]; sizeof(x);");
+    test(1, ({ char x[(unsigned)-1 >> 31]; sizeof(x); }), "char x[(unsigned)-1 >> 31]; sizeof(x);");
+    test(2, ({ char x[(unsigned char)-1 / 100]; sizeof(x); }), "char x[(unsigned char)-1 / 100]; sizeof(x);");
+    test(16, CE1, "CE1");
+    test(6, CE2, "CE2");
+    test(1, CE3, "CE3");
+    test(-2147483648, CE4, "CE4");
+    test(-2, CE5, "CE5");
+    test(8, sizeof(1 ? 2 : 3L), "sizeof(1 ? 2 : 3L)");
+    test(2, ce_ternary, "ce_ternary");
+    test(3, addr_diff, "addr_diff");
+    test(7, *addr_cond, "*addr_cond");
+    test(1, addr_cond0 == 0, "addr_cond0 == 0");
+    test(2, addr_cond1 - addr_arr, "addr_cond1 - addr_arr");
+    test(7, ({ int x=1; switch (x) { case 2 >= 1: x=7; break; default: x=0; } x; }), "int x=1; switch (x) { case 2 >= 1: x=7; break; default: x=0; } x;");
+    test(44, g54, "g54");
+    test(1, g55, "g55");
+    test(255, g56, "g56");
+    test(1, g57, "g57");
+    _Static_assert(CE1 == 16, "CE1");
+    static_assert(sizeof(struct aligned) == 16);
+    test(8, _Alignof(long), "_Alignof(long)");
+    test(1, _Alignof(char), "_Alignof(char)");
+    test(8, _Alignof(struct big), "_Alignof(struct big)");
+    test(4, alignof(int[3]), "alignof(int[3])");
+    test(16, ({ _Alignas(16) char c; _Alignof(c); }), "_Alignas(16) char c; _Alignof(c);");
+    test(8, ({ _Alignas(long) char c; _Alignof(c); }), "_Alignas(long) char c; _Alignof(c);");
+    test(4, ({ _Alignas(4) int w; _Alignof(w); }), "_Alignas(4) int w; _Alignof(w);");
+    test(0, ({ char a; _Alignas(16) char b; char c; (long)&b % 16; }), "char a; _Alignas(16) char b; char c; (long)&b % 16;");
+    test(0, ({ char a; int r; { char b; int _Alignas(16) c; r=(long)&c % 16; } r; }), "char a; int r; { char b; int _Alignas(16) c; r=(long)&c % 16; } r;");
+    test(8, ({ struct aligned s; (long)&s.b - (long)&s; }), "struct aligned s; (long)&s.b - (long)&s;");
+    test(16, sizeof(struct aligned), "sizeof(struct aligned)");
+    test(8, _Alignof(struct aligned), "_Alignof(struct aligned)");
+    test(16, ({ union { char a; _Alignas(16) char b; } u; sizeof(u) + _Alignof(u) - 16; }), "union { char a; _Alignas(16) char b; } u; sizeof(u) + _Alignof(u) - 16;");
+    test(0, (long)&g59 % 32, "(long)&g59 % 32");
+    test(32, _Alignof(g59), "_Alignof(g59)");
+    test(76500, g60.a[0]+g60.a[1]*100+g60.a[2]*1000+g60.p.a*10000+g60.p.b*100000, "g60.a[0]+g60.a[1]*100+g60.a[2]*1000+g60.p.a*10000+g60.p.b*100000");
+    test(1090, g61[1][0]+g61[1][1]*10+g61[1][2]*100, "g61[1][0]+g61[1][1]*10+g61[1][2]*100");
+    test(3430, g62.k+g62.y*1000+g62.z*100+g62.x*10-20, "g62.k+g62.y*1000+g62.z*100+g62.x*10-20");
+    test(7094, ({ struct {int a[3]; struct pair p;} s = {.a[1] = 7, 9, 4}; s.a[0]+s.a[1]*1000+s.a[2]*10+s.p.a; }), "struct {int a[3]; struct pair p;} s = {.a[1] = 7, 9, 4}; s.a[0]+s.a[1]*1000+s.a[2]*10+s.p.a;");
+    test(2, ({ union {struct {char lo, hi;}; short s;} u = {.hi = 2}; u.s >> 8; }), "union {struct {char lo, hi;}; short s;} u = {.hi = 2}; u.s >> 8;");
+    test(0, ({ static _Alignas(64) int s; (long)&s % 64; }), "static _Alignas(64) int s; (long)&s % 64;");
+
+    test(20, ({ int n=5; int a[n]; sizeof(a); }), "int n=5; int a[n]; sizeof(a);");
+    test(4, ({ int n=5; int a[n]; sizeof(a[0]); }), "int n=5; int a[n]; sizeof(a[0]);");
+    test(60, ({ int n=5; sizeof(int[n][3]); }), "int n=5; sizeof(int[n][3]);");
+    test(60, ({ int n=5; int a[3][n]; sizeof(a); }), "int n=5; int a[3][n]; sizeof(a);");
+    test(15, ({ int n=3; long a[n+2]; sizeof(a) / sizeof(a[0]) * 3; }), "int n=3; long a[n+2]; sizeof(a) / sizeof(a[0]) * 3;");
+    test(10, ({ int n=5; int a[n]; for (int i=0; i<n; i++) a[i]=i; a[0]+a[1]+a[2]+a[3]+a[4]; }), "int n=5; int a[n]; for (int i=0; i<n; i++) a[i]=i; a[0]+a[1]+a[2]+a[3]+a[4];");
+    test(11, ({ int n=3, m=4; int a[n][m]; for (int i=0; i<n; i++) for (int j=0; j<m; j++) a[i][j]=i*m+j; a[2][3]; }), "int n=3, m=4; int a[n][m]; for (int i=0; i<n; i++) for (int j=0; j<m; j++) a[i][j]=i*m+j; a[2][3];");
+    test(16, ({ int n=3, m=4; int a[n][m]; sizeof(a[1]); }), "int n=3, m=4; int a[n][m]; sizeof(a[1]);");
+    test(9, ({ int n=3, m=4; int a[n][m]; int (*p)[m] = a; p[2][1] = 9; a[2][1]; }), "int n=3, m=4; int a[n][m]; int (*p)[m] = a; p[2][1] = 9; a[2][1];");
+    test(16, ({ int m=4; int a[3][4]; int (*p)[m] = a; (long)(p+1) - (long)p; }), "int m=4; int a[3][4]; int (*p)[m] = a; (long)(p+1) - (long)p;");
+    test(2, ({ int m=4; int a[3][4]; int (*p)[m] = a; int (*q)[m] = p+2; q-p; }), "int m=4; int a[3][4]; int (*p)[m] = a; int (*q)[m] = p+2; q-p;");
+    test(12, ({ int m=3; int a[2][3]; sizeof(*(int (*)[m])a); }), "int m=3; int a[2][3]; sizeof(*(int (*)[m])a);");
+    test(6, ({ int a[2][3] = {{1, 2, 3}, {4, 5, 6}}; vla_elem(3, a, 1, 2); }), "int a[2][3] = {{1, 2, 3}, {4, 5, 6}}; vla_elem(3, a, 1, 2);");
+    test(6, ({ int a[3] = {1, 2, 3}; vla_sum(3, a); }), "int a[3] = {1, 2, 3}; vla_sum(3, a);");
+    test(0, ({ int n=3; char a[n]; (long)a % 16; }), "int n=3; char a[n]; (long)a % 16;");
+    test(0, ({ int n=3; char a[n]; char b[n]; (long)b % 16; }), "int n=3; char a[n]; char b[n]; (long)b % 16;");
+    test(16, ({ int n=3; char a[n]; char b[n]; a - b; }), "int n=3; char a[n]; char b[n]; a - b;");
+    test(1, ({ int n=3; char *p; { char a[n]; p=a; } char b[n]; b == p; }), "int n=3; char *p; { char a[n]; p=a; } char b[n]; b == p;");
+    test(1, vla_loop(7), "vla_loop(7)");
+    test(1, vla_goto(7), "vla_goto(7)");
+    test(43, vla_alloca(7), "vla_alloca(7)");
+    test(7, 3 + ({ int n=2; int a[n]; a[1]=4; a[1]; }), "3 + ({ int n=2; int a[n]; a[1]=4; a[1]; })");
+    test(3, add2(1, ({ int *p = alloca(4); *p = 2; *p; })), "add2(1, ({ int *p = alloca(4); *p = 2; *p; }))");
+    test(0, (long)alloca(3) % 16, "(long)alloca(3) % 16");
+    test(21, add6(1, 2, 3, 4, 5, ({ int n=1; int a[n]; a[0]=6; a[0]; })), "add6(1, 2, 3, 4, 5, ({ int n=1; int a[n]; a[0]=6; a[0]; }))");
+
+    test(3, ({ int x; type_id(x); }), "int x; type_id(x);");
+    test(1, ({ char x; type_id(x); }), "char x; type_id(x);");
+    test(2, ({ short x; type_id(x); }), "short x; type_id(x);");
+    test(4, ({ long x; type_id(x); }), "long x; type_id(x);");
+    test(5, ({ unsigned x; type_id(x); }), "unsigned x; type_id(x);");
+    test(0, ({ int *x; type_id(x); }), "int *x; type_id(x);");
+    test(3, ({ const int x=1; type_id(x); }), "const int x=1; type_id(x);");
+    test(6, ({ int a[3]; _Generic(a, int *: 6, default: 0); }), "int a[3]; _Generic(a, int *: 6, default: 0);");
+    test(7, ({ const int a[3] = {}; _Generic(a, int *: 0, const int *: 7, default: 1); }), "const int a[3] = {}; _Generic(a, int *: 0, const int *: 7, default: 1);");
+    test(8, _Generic(g8, char *: 8, default: 0), "_Generic(g8, char *: 8, default: 0)");
+    test(9, ({ struct pair s; _Generic(s, struct big: 0, struct pair: 9); }), "struct pair s; _Generic(s, struct big: 0, struct pair: 9);");
+    test(10, _Generic(ret2, int (*)(void): 10, default: 0), "_Generic(ret2, int (*)(void): 10, default: 0)");
+    test(2, _Generic(g8, char *: ret2, default: 0)(), "_Generic(g8, char *: ret2, default: 0)()");
+    test(4, ({ int x; __typeof__(x) y; sizeof(y); }), "int x; __typeof__(x) y; sizeof(y);");
+    test(16, ({ typeof(int[4]) a; sizeof(a); }), "typeof(int[4]) a; sizeof(a);");
+    test(24, ({ int a[3][2]; typeof(a) b; sizeof(b); }), "int a[3][2]; typeof(a) b; sizeof(b);");
+    test(98, ({ typeof(g8) p = "xb"; p[1]; }), "typeof(g8) p = \"xb\"; p[1];");
+    test(1, ({ const int c = 1; typeof(c) d = 2; _Generic(&d, const int *: 1, int *: 0); }), "const int c = 1; typeof(c) d = 2; _Generic(&d, const int *: 1, int *: 0);");
+    test(6, ({ typeof_unqual(g26) x = 5; x = 6; _Generic(&x, int *: x, default: 0); }), "typeof_unqual(g26) x = 5; x = 6; _Generic(&x, int *: x, default: 0);");
+    test(5, ({ __typeof__(struct pair) p = {2, 3}; p.a + p.b; }), "__typeof__(struct pair) p = {2, 3}; p.a + p.b;");
+    test(7, ({ int i=7; long l=3; max(i, l); }), "int i=7; long l=3; max(i, l);");
+    test(8, ({ int n=1; typeof(n++) m=8; m + n - 1; }), "int n=1; typeof(n++) m=8; m + n - 1;");
+
+    test(1, (_Bool)256, "(_Bool)256");
+    test(1, tg_to_bool(256), "tg_to_bool(256)");
+    test(1, bool_arg(256), "bool_arg(256)");
+    test(1, gcc_bool_arg(256), "gcc_bool_arg(256)");
+    test(1, gcc_call_tg_to_bool(), "gcc_call_tg_to_bool()");
+    test(255, uchar_ret(-1), "uchar_ret(-1)");
+    test(1, ({ _Bool b=0; b++; b++; b; }), "_Bool b=0; b++; b++; b;");
+    test(0, ({ _Bool b=1; b--; b; }), "_Bool b=1; b--; b;");
+    test(1, ({ _Bool b=0; b += 2; b; }), "_Bool b=0; b += 2; b;");
+    test(127, ({ char c=127; c++; }), "char c=127; c++;");
+    test(-128, ({ char c=127; c++; c; }), "char c=127; c++; c;");
+    test(-128, ({ char c=127; ++c; }), "char c=127; ++c;");
+    test(256, ({ unsigned char c=255; c+1; }), "unsigned char c=255; c+1;");
+    test(0, ({ unsigned char c=255; c += 1; c; }), "unsigned char c=255; c += 1; c;");
+    test(-2147483648, ({ int x=2147483647; x+1; }), "int x=2147483647; x+1;");
+    test(4294967295, ({ unsigned x=0; x-1; }), "unsigned x=0; x-1;");
+    test(0, -1 < 1u, "-1 < 1u");
+    test(1, -1 < 1, "-1 < 1");
+    test(1, -1L < 1u, "-1L < 1u");
+    test(1, ({ unsigned x=-1; x > 0; }), "unsigned x=-1; x > 0;");
+    test(2147483647, ({ unsigned x=4294967295; x/2; }), "unsigned x=4294967295; x/2;");
+    test(5, ({ unsigned x=4294967295; x%10; }), "unsigned x=4294967295; x%10;");
+    test(15, ({ unsigned x=-1; x >> 28; }), "unsigned x=-1; x >> 28;");
+    test(-4, -16 >> 2, "-16 >> 2");
+    test(-1, ~0, "~0");
+    test(4294967295, ~0u, "~0u");
+    test(4, sizeof(1 < 2), "sizeof(1 < 2)");
+    test(4, sizeof(!1L), "sizeof(!1L)");
+    test(3, type_id(1), "type_id(1)");
+    test(4, type_id(1L), "type_id(1L)");
+    test(5, type_id(1u), "type_id(1u)");
+    test(6, type_id(1ul), "type_id(1ul)");
+    test(6, type_id(1LLU), "type_id(1LLU)");
+    test(4, type_id(3000000000), "type_id(3000000000)");
+    test(5, type_id(0xffffffff), "type_id(0xffffffff)");
+    test(6, type_id(0xffffffffffffffff), "type_id(0xffffffffffffffff)");
+    test(3, type_id('a'), "type_id('a')");
+    test(6, type_id(sizeof(int)), "type_id(sizeof(int))");
+    test(3, type_id((char)1 + (char)2), "type_id((char)1 + (char)2)");
+    test(4, type_id(1u + 1L), "type_id(1u + 1L)");
+    test(5, type_id(1u + 1), "type_id(1u + 1)");
+    test(3, type_id(~(short)1), "type_id(~(short)1)");
+    test(3, type_id((char)1 << 2L), "type_id((char)1 << 2L)");
+    test(6, type_id(1 ? 1 : 1ul), "type_id(1 ? 1 : 1ul)");
+    test(4, ({ enum { E1 = 1 } x; sizeof(x); }), "enum { E1 = 1 } x; sizeof(x);");
+    test(1, ({ enum : unsigned char { E2 = 255 } x; sizeof(x); }), "enum : unsigned char { E2 = 255 } x; sizeof(x);");
+    test(256, ({ enum : unsigned char { E2 = 255 } x = E2; x + 1; }), "enum : unsigned char { E2 = 255 } x = E2; x + 1;");
+    test(1, ({ enum : unsigned char { E3 } x = 257; x; }), "enum : unsigned char { E3 } x = 257; x;");
+    test(2, ({ enum e4 : short { E4 } x; _Alignof(x); }), "enum e4 : short { E4 } x; _Alignof(x);");
+    test(8, ({ enum { E5 = 0x100000000 } x; sizeof(x); }), "enum { E5 = 0x100000000 } x; sizeof(x);");
+    test(6, type_id(({ enum { E6 = 0x100000000 }; E6; })), "type_id(({ enum { E6 = 0x100000000 }; E6; }))");
+    test(1, ({ enum { N1 = -1 } x = N1; x < 0; }), "enum { N1 = -1 } x = N1; x < 0;");
+    test(1, ({ enum { P1 = 1 } x = -1; x > 0; }), "enum { P1 = 1 } x = -1; x > 0;");
+    test(3, type_id(({ enum { E7 = 1u }; E7; })), "type_id(({ enum { E7 = 1u }; E7; }))");
+    test(1, ({ enum e8 : unsigned char { E8 }; _Generic((enum e8)0, unsigned char: 1, default: 0); }), "enum e8 : unsigned char { E8 }; _Generic((enum e8)0, unsigned char: 1, default: 0);");
+    test(1, ({ enum e9 { E9 = -1 } x; _Generic(&x, int *: 1, default: 0); }), "enum e9 { E9 = -1 } x; _Generic(&x, int *: 1, default: 0);");
+    test(0, ({ enum e10 { E10 } x; _Generic(&x, int *: 1, default: 0); }), "enum e10 { E10 } x; _Generic(&x, int *: 1, default: 0);");
+    test(5, ({ enum { A=3, B, C }; C; }), "enum { A=3, B, C }; C;");
+
+    test(4294967295, long_id(4294967295u), "long_id(4294967295u)");
+    test(-1, long_id(-1), "long_id(-1)");
+    test(-1, long_id((char)255), "long_id((char)255)");
+    test(1, char_id(257), "char_id(257)");
+    test(0, sub_char(257, 1, 0), "sub_char(257, 1, 0)");
+    test(1, ({ int (*fp)(char) = char_id; fp(257); }), "int (*fp)(char) = char_id; fp(257);");
+    test(3, no_proto(1, 2), "no_proto(1, 2)");
+    test(3, no_proto(), "no_proto()");
+
+    test(3, sg, "sg");
+    test(11, get_sg2(), "get_sg2()");
+    test(7, static_fn2(), "static_fn2()");
+    test(3, static_fn(), "static_fn()");
+    test(5, tent, "tent");
+    test(0, tent2, "tent2");
+    test(3, ({ common1 = 3; get_common1(); }), "common1 = 3; get_common1();");
+    test(7, ({ arr_tent[0] = 7; arr_tent[0]; }), "arr_tent[0] = 7; arr_tent[0];");
+    test(12, sizeof(arr_later), "sizeof(arr_later)");
+    test(4, sfn(), "sfn()");
+    test(1, static_a(), "static_a()");
+    test(2, static_a(), "static_a()");
+    test(11, static_b(), "static_b()");
+    test(12, static_b(), "static_b()");
+    test(1, counter2(), "counter2()");
+    test(5, ({ int tent = 1; int r; { extern int tent; r = tent; } r; }), "int tent = 1; int r; { extern int tent; r = tent; } r;");
+    test(9, ({ extern int ext_late; ext_late; }), "extern int ext_late; ext_late;");
+    test(8, ({ int ret_late(void); ret_late(); }), "int ret_late(void); ret_late();");
+    test(8, ({ extern int ret_late(void); ret_late(); }), "extern int ret_late(void); ret_late();");
+
+    test(6, sizeof("abc" "de"), "sizeof(\"abc\" \"de\")");
+    test(101, "abc" "de"[4], "\"abc\" \"de\"[4]");
+    test(0, strcmp("ab" "c", "abc"), "strcmp(\"ab\" \"c\", \"abc\")");
+    test(3, sizeof("a\n"), "sizeof(\"a\\n\")");
+    test(2, sizeof("\0"), "sizeof(\"\\0\")");
+    test(65, "\x41"[0], "\"\\x41\"[0]");
+    test(65, "\101"[0], "\"\\101\"[0]");
+    test(-1, "\xff"[0], "\"\\xff\"[0]");
+    test(10, '\n', "'\\n'");
+    test(39, '\'', "'\\''");
+    test(-1, '\xff', "'\\xff'");
+    test(1, '\xff' < 0, "'\\xff' < 0");
+    test(4, sizeof('\xff'), "sizeof('\\xff')");
+    test(0, '\0', "'\\0'");
+    test(126, ({ char *s = "a\"b\\"; s[1] + s[3]; }), "char *s = \"a\\\"b\\\\\"; s[1] + s[3];");
+    test(1, "pool" == "pool", "\"pool\" == \"pool\"");
+    test(1, str_gp == "pool", "str_gp == \"pool\"");
+    test(3, sizeof(u8"é"), "sizeof(u8\"é\")");
+    test(6, sizeof(u"ab"), "sizeof(u\"ab\")");
+    test(98, u"ab"[1], "u\"ab\"[1]");
+    test(1, _Generic(u"a"[0], unsigned short: 1, default: 0), "_Generic(u\"a\"[0], unsigned short: 1, default: 0)");
+    test(6, sizeof(u"\U0001F600"), "sizeof(u\"\\U0001F600\")");
+    test(55357, u"\U0001F600"[0], "u\"\\U0001F600\"[0]");
+    test(12, sizeof(U"ab"), "sizeof(U\"ab\")");
+    test(233, U"é"[0], "U\"é\"[0]");
+    test(128512, U"\U0001F600"[0], "U\"\\U0001F600\"[0]");
+    test(1, _Generic(U"a"[0], unsigned int: 1, default: 0), "_Generic(U\"a\"[0], unsigned int: 1, default: 0)");
+    test(12, sizeof(L"ab"), "sizeof(L\"ab\")");
+    test(1, _Generic(L"a"[0], int: 1, default: 0), "_Generic(L\"a\"[0], int: 1, default: 0)");
+    test(12, sizeof(L"a" "b"), "sizeof(L\"a\" \"b\")");
+    test(98, (L"a" "b")[1], "(L\"a\" \"b\")[1]");
+    test(16, ({ int a[] = L"xyz"; sizeof(a); }), "int a[] = L\"xyz\"; sizeof(a);");
+    test(0, ({ unsigned short a[4] = u"ab"; a[3]; }), "unsigned short a[4] = u\"ab\"; a[3];");
+    test(99, ({ char a[3] = "abc"; a[2]; }), "char a[3] = \"abc\"; a[2];");
+    test(3, sizeof(str_g), "sizeof(str_g)");
+    test(99, str_g[2], "str_g[2]");
+    test(20, sizeof(str_gw), "sizeof(str_gw)");
+    test(101, str_gw[3], "str_gw[3]");
+
+    test(5, sizeof(struct attr_packed1), "sizeof(struct attr_packed1)");
+    test(1, _Alignof(struct attr_packed1), "_Alignof(struct attr_packed1)");
+    test(9, sizeof(struct attr_packed2), "sizeof(struct attr_packed2)");
+    test(8, sizeof(struct attr_packed3), "sizeof(struct attr_packed3)");
+    test(6, ({ struct attr_packed3 s; (char *)&s.c - (char *)&s; }), "struct attr_packed3 s; (char *)&s.c - (char *)&s;");
+    test(8, sizeof(struct attr_packed4), "sizeof(struct attr_packed4)");
+    test(4, _Alignof(struct attr_packed4), "_Alignof(struct attr_packed4)");
+    test(8, sizeof(struct attr_aligned1), "sizeof(struct attr_aligned1)");
+    test(32, sizeof(struct attr_aligned2), "sizeof(struct attr_aligned2)");
+    test(16, ({ struct attr_aligned2 s; (char *)&s.x - (char *)&s; }), "struct attr_aligned2 s; (char *)&s.x - (char *)&s;");
+    test(0, (long)&attr_aligned_g % 64, "(long)&attr_aligned_g % 64");
+    test(0, (long)&attr_aligned_c % 16, "(long)&attr_aligned_c % 16");
+    test(0, ({ int x __attribute__((aligned(16))); (long)&x % 16; }), "int x __attribute__((aligned(16))); (long)&x % 16;");
+    test(4, sizeof(attr_aint), "sizeof(attr_aint)");
+    test(16, _Alignof(attr_aint), "_Alignof(attr_aint)");
+    test(12, sizeof(attr_astruct), "sizeof(attr_astruct)");
+    test(16, _Alignof(attr_astruct), "_Alignof(attr_astruct)");
+    test(32, sizeof(struct attr_aint_member), "sizeof(struct attr_aint_member)");
+    test(16, ({ struct attr_aint_member s; (char *)&s.x - (char *)&s; }), "struct attr_aint_member s; (char *)&s.x - (char *)&s;");
+    test(0, (long)&attr_aint_g % 16, "(long)&attr_aint_g % 16");
+    test(0, ({ char c; attr_aint x = 1; (long)&x % 16; }), "char c; attr_aint x = 1; (long)&x % 16;");
+    test(16, ({ const attr_aint x = 1; _Alignof(x); }), "const attr_aint x = 1; _Alignof(x);");
+    test(1, ({ attr_aint x = 3; int *p = &x; *p == 3; }), "attr_aint x = 3; int *p = &x; *p == 3;");
+    test(1, _Alignof(attr_along1), "_Alignof(attr_along1)");
+    test(1, _Alignof(const attr_along1), "_Alignof(const attr_along1)");
+    test(16, _Alignof(attr_along16), "_Alignof(attr_along16)");
+    test(9, sizeof(struct attr_along1_member), "sizeof(struct attr_along1_member)");
+    test(1, sizeof(enum attr_penum1), "sizeof(enum attr_penum1)");
+    test(2, sizeof(enum attr_penum2), "sizeof(enum attr_penum2)");
+    test(4, sizeof(ATTR_PB1), "sizeof(ATTR_PB1)");
+    test(200, ({ enum attr_penum1 e = ATTR_PB1; e; }), "enum attr_penum1 e = ATTR_PB1; e;");
+    test(-300, ({ enum attr_penum2 e = ATTR_PA2; e; }), "enum attr_penum2 e = ATTR_PA2; e;");
+    test(16, _Alignof(attr_aptr), "_Alignof(attr_aptr)");
+    test(9, sizeof(struct attr_aptr_member), "sizeof(struct attr_aptr_member)");
+    test(3, ({ int x = 3; int * __attribute__((unused)) const p = &x; *p; }), "int x = 3; int * __attribute__((unused)) const p = &x; *p;");
+    test(-5, ({ struct attr_along1_member s; s.l = -5; s.l; }), "struct attr_along1_member s; s.l = -5; s.l;");
+    test(gcc_packed_size(), sizeof(struct packed), "gcc_packed_size() == sizeof(struct packed)");
+    test(7, gcc_sum_packed((struct packed){1, 2, 4}), "gcc_sum_packed((struct packed){1, 2, 4})");
+    test(2, __stop_tgocc_set - __start_tgocc_set, "__stop_tgocc_set - __start_tgocc_set");
+    test(10, attr_sec_fn(), "attr_sec_fn()");
+    test(2, attr_weak_fn(), "attr_weak_fn()");
+    test(6, attr_weak_var, "attr_weak_var");
+    test(1, !attr_weak_undef, "!attr_weak_undef");
+    test(7, attr_alias(), "attr_alias()");
+    test(8, attr_alias_var, "attr_alias_var");
+    test(1, attr_alias == attr_alias_target, "attr_alias == attr_alias_target");
+    test(9, attr_hidden(), "attr_hidden()");
+    test(123, attr_ctor_val, "attr_ctor_val");
+    test(21, cleanup_block(), "cleanup_block()");
+    test(54, ({ cleanup_log = 0; cleanup_ret() * 10 + cleanup_log; }), "cleanup_ret() * 10 + cleanup_log");
+    test(8, ({ cleanup_log = 0; cleanup_fall(); cleanup_log; }), "cleanup_fall(); cleanup_log;");
+    test(1234, cleanup_loop(), "cleanup_loop()");
+    test(65, cleanup_goto(), "cleanup_goto()");
+    test(1407, ({ cleanup_log = 0; int v = ({ int a __attribute__((cleanup(cleanup_push))) = 7; a * 2; }); v * 100 + cleanup_log; }), "cleanup in statement expression");
+    test(3, cleanup_switch(1), "cleanup_switch(1)");
+    test(2, cleanup_switch(2), "cleanup_switch(2)");
+
+    test(42, asm_top_fn(), "asm_top_fn()");
+    test(7, asm_add(3, 4), "asm_add(3, 4)");
+    test(1, asm_rdtsc(), "asm_rdtsc()");
+    test(1, ({ __asm__("pause"); asm volatile("" ::: "memory"); 1; }), "__asm__(\"pause\"); asm volatile(\"\" ::: \"memory\");");
+    test(7, ({ int x = 3, y = 4, z; asm("mov %0, %1\n\tadd %0, %2" : "=r"(z) : "r"(x), "r"(y)); z; }), "asm(\"mov %0, %1; add %0, %2\" : \"=r\"(z) : \"r\"(x), \"r\"(y));");
+    test(15, ({ long l = 10; asm("add %0, %1" : "+r"(l) : "i"(5)); l; }), "asm(\"add %0, %1\" : \"+r\"(l) : \"i\"(5));");
+    test(10, ({ int m = 7; asm("add %0, 3" : "+m"(m)); m; }), "asm(\"add %0, 3\" : \"+m\"(m));");
+    test(42, ({ int k; asm("lea %0, [%1+%1]" : "=r"(k) : "0"(21)); k; }), "asm(\"lea %0, [%1+%1]\" : \"=r\"(k) : \"0\"(21));");
+    test(3, ({ char c = 1; asm("add %b0, %1" : "+q"(c) : "n"(2)); c; }), "asm(\"add %b0, %1\" : \"+q\"(c) : \"n\"(2));");
+    test(3, ({ int x = 3, r; asm("mov %k[out], %[in]" : [out] "=r"(r) : [in] "m"(x)); r; }), "asm(\"mov %k[out], %[in]\" : [out] \"=r\"(r) : [in] \"m\"(x));");
+    test(-1, ({ long x; asm("mov %q0, -1" : "=&r"(x)); x; }), "asm(\"mov %q0, -1\" : \"=&r\"(x));");
+    test(7, asm_callee_saved(), "asm_callee_saved()");
+    test(25, ({ struct { int a; int b : 3; int c : 5; } s = {1, 2, 3}; asm("mov %0, 5" : "=r"(s.c)); s.a * 100 + s.b * 10 + s.c - 100; }), "asm(\"mov %0, 5\" : \"=r\"(s.c));");
+    test(3, ({ int a[4] = {1, 2, 3, 4}; int *p; asm("mov %0, %1" : "=r"(p) : "r"(a)); p[2]; }), "asm(\"mov %0, %1\" : \"=r\"(p) : \"r\"(a));");
+    test(4, ({ int d = 1, a = 0; asm("mov %0, %2\n\tadd %1, 1" : "=a"(a), "+d"(d) : "a"(2)); a + d; }), "asm(\"mov %0, %2; add %1, 1\" : \"=a\"(a), \"+d\"(d) : \"a\"(2));");
+    test(0, asm_unique(0), "asm_unique(0)");
+    test(2, asm_unique(5), "asm_unique(5)");
+
+    test(106, ({ long v[3] = {0, 0, 7}; unnamed_params(1, "ab", v); }), "unnamed_params(1, \"ab\", v)");
+    test(1, atomic_threads(), "atomic_threads()");
+    test(100, tls_counter, "tls_counter");
+    test(1010002, tls_seen[0], "tls_seen[0]");
+    test(1010035, tls_seen[3], "tls_seen[3]");
+    test(2, tls_arr[1], "tls_arr[1]");
+    test(1, tls_ext_get(), "tls_ext_get()");
+    test(2, tls_ext_get(), "tls_ext_get()");
+    test(7, gcc_tls, "gcc_tls");
+    test(8, ({ gcc_tls = 8; gcc_tls_get(); }), "gcc_tls = 8; gcc_tls_get();");
+    test(12, ({ tg_tls2++; gcc_tg_tls2(); }), "tg_tls2++; gcc_tg_tls2();");
+    test(1, ({ int *p = &tls_counter; *p = 5; tls_counter == 5; }), "int *p = &tls_counter; *p = 5; tls_counter == 5;");
+    test(1, ({ static __thread int s = 3; s++; s == 4; }), "static __thread int s = 3; s++; s == 4;");
+    test(1, ({ int x = 5, e = 5; __atomic_compare_exchange_n(&x, &e, 9, 0, __ATOMIC_SEQ_CST, __ATOMIC_SEQ_CST) && x == 9; }), "__atomic_compare_exchange_n(&x, &e, 9, ...) succeeds");
+    test(18, ({ int x = 9, e = 1; int r = __atomic_compare_exchange_n(&x, &e, 7, 1, 5, 5); r * 100 + e + x; }), "__atomic_compare_exchange_n(&x, &e, 7, ...) fails");
+    test(93, ({ int x = 9; int r = __sync_val_compare_and_swap(&x, 9, 3); r * 10 + x; }), "__sync_val_compare_and_swap(&x, 9, 3)");
+    test(1, ({ long x = 9; __sync_bool_compare_and_swap(&x, 9, 3) && !__sync_bool_compare_and_swap(&x, 9, 4) && x == 3; }), "__sync_bool_compare_and_swap");
+    test(10, ({ char c = 10; __atomic_load_n(&c, __ATOMIC_ACQUIRE); }), "__atomic_load_n(&c, __ATOMIC_ACQUIRE)");
+    test(9, ({ char c = 10; int old = __atomic_exchange_n(&c, -1, 5); old + c; }), "__atomic_exchange_n(&c, -1, 5)");
+    test(42, ({ int x; __atomic_store_n(&x, 42, __ATOMIC_RELEASE); x; }), "__atomic_store_n(&x, 42, __ATOMIC_RELEASE)");
+    test(5010, ({ int x = 42; int a = __atomic_add_fetch(&x, 8, 5); int b = __atomic_fetch_sub(&x, 10, 5); a * 100 + b - x; }), "__atomic_add_fetch, __atomic_fetch_sub");
+    test(-1, ({ int x = 40; __atomic_fetch_or(&x, 1, 5); __atomic_and_fetch(&x, 3, 5); __atomic_nand_fetch(&x, 2, 5); }), "__atomic_fetch_or, __atomic_and_fetch, __atomic_nand_fetch");
+    test(6, ({ unsigned short s = 3; __sync_fetch_and_xor(&s, 5); }) + ({ unsigned short s = 3; __sync_xor_and_fetch(&s, 5) - 3; }), "__sync_fetch_and_xor, __sync_xor_and_fetch");
+    test(1, ({ int l = 0; int r = __sync_lock_test_and_set(&l, 1); __sync_lock_release(&l); r == 0 && l == 0; }), "__sync_lock_test_and_set, __sync_lock_release");
+    test(1, ({ __sync_synchronize(); __atomic_thread_fence(__ATOMIC_SEQ_CST); __atomic_thread_fence(__ATOMIC_ACQUIRE); __atomic_signal_fence(__ATOMIC_SEQ_CST); 1; }), "fences");
+    test(4, ({ _Atomic unsigned char uc = 250; uc += 10; uc; }), "_Atomic unsigned char uc = 250; uc += 10;");
+    test(30, ({ _Atomic int ai = 3; ai *= 5; ai <<= 1; ai; }), "_Atomic int ai = 3; ai *= 5; ai <<= 1;");
+    test(313130, ({ _Atomic int ai = 30; int pre = ++ai; int post = ai--; pre * 10000 + post * 100 + ai; }), "_Atomic int ai = 30; ++ai; ai--;");
+    test(1, ({ int a[2]; int *_Atomic p = a; p++; p == a + 1; }), "int *_Atomic p = a; p++;");
+    test(1, ({ _Atomic _Bool b = 0; b++; b++; b; }), "_Atomic _Bool b++");
+    test(1, _Generic((_Atomic int)0, int: 1, default: 0), "_Generic((_Atomic int)0, int: 1, default: 0)");
+    test(4, sizeof(_Atomic(int)), "sizeof(_Atomic(int))");
+
     printf("OK\n");
     return 0;
 }
+
+int ext_late = 9;
+char str_g[3] = "abc";
+int str_gw[] = L"wide";
+char *str_gp = "pool";
+
+int ret_late(void) {
+    return 8;
+}
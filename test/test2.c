@@ -1,2 +1,41 @@
 int ext1;
 int *ext2;
+
+// the static ones do not conflict with the ones of the same name in test1.c.
+static int static_fn(void) { return 7; }
+int static_fn2(void) { return static_fn(); }
+// never called, so it is discarded along with the table of its label addresses.
+static int static_dispatch(void) {
+    static void *tbl[] = {&&done};
+    goto *tbl[0];
+done:
+    return 0;
+}
+static int sg = 11;
+int get_sg2(void) { return sg; }
+
+// a tentative definition in both files, which is merged by the linker.
+int common1;
+int get_common1(void) { return common1; }
+
+int counter2(void) {
+    static int i;
+    return ++i;
+}
+
+// the strong definitions override the weak ones in test1.c.
+int attr_weak_fn(void) { return 2; }
+int attr_weak_var = 6;
+
+__thread long tg_tls2 = 11;
+int tls_ext_get(void) {
+    static _Thread_local int calls;
+    return ++calls;
+}
+
+// the objects and the functions below are only checked by test/sections.sh.
+int sec_bss2 = 0;
+const int sec_ro2 = 3;
+int sec_data2 = 4;
+// never referenced, so the linker discards it with --gc-sections.
+int sec_unused2(void) { return sec_data2; }
@@ -7,7 +7,18 @@ import (
 	"unicode/utf8"
 )
 
-var macros = map[string]macro{}
+var macros = predefinedMacros()
+
+// predefinedMacros returns the macros defined before reading any source,
+// which are the memory orders given to the atomic built-in functions as GCC defines them.
+func predefinedMacros() map[string]macro {
+	m := map[string]macro{}
+	orders := [...]string{"__ATOMIC_RELAXED", "__ATOMIC_CONSUME", "__ATOMIC_ACQUIRE", "__ATOMIC_RELEASE", "__ATOMIC_ACQ_REL", "__ATOMIC_SEQ_CST"}
+	for i, name := range orders {
+		m[name] = &objMacro{newNumTok(int64(i), 1)}
+	}
+	return m
+}
 
 type macro interface {
 	aMacro() // dummy method to avoid type errors
@@ -8,16 +8,19 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
 var (
 	idMatcher   = regexp.MustCompile(`^[a-zA-Z_]+\w*`)
 	typeMatcher = regexp.MustCompile(
-		`^(int|char|long|short|struct|void|_Bool|typedef|enum|static|extern|signed|unsigned|volatile)\W`)
-	digitMatcher    = regexp.MustCompile(`^(0(x|X)[[:xdigit:]]+|0(o|O)\d+|0(b|B)(0|1)+|\d+)`)
-	reservedMatcher = regexp.MustCompile(
-		`^(if|else|while|for|return|sizeof|break|continue|switch|case|default|do|define|include)\W`)
+		`^(int|char|long|short|struct|union|void|_Bool|typedef|enum|static|extern|signed|unsigned|const|volatile|restrict|__restrict|__restrict__|_Alignas|alignas|typeof|__typeof__|__typeof|typeof_unqual|__typeof_unqual__|__typeof_unqual|__attribute__|__attribute|_Atomic|_Thread_local|thread_local|__thread)\W`)
+	digitMatcher     = regexp.MustCompile(`^(0(x|X)[[:xdigit:]]+|0(o|O)\d+|0(b|B)(0|1)+|\d+)`)
+	strPrefixMatcher = regexp.MustCompile(`^(u8|u|U|L)?"`)
+	suffixMatcher    = regexp.MustCompile(`^([uU](ll|LL|l|L)?|(ll|LL|l|L)[uU]?)`)
+	reservedMatcher  = regexp.MustCompile(
+		`^(if|else|while|for|return|sizeof|break|continue|switch|case|default|do|goto|define|include|_Alignof|alignof|_Static_assert|static_assert|_Generic|asm|__asm__|__asm)\W`)
 )
 
 type (
@@ -37,9 +40,14 @@ type (
 	}
 
 	// NumTok represents a number token.
+	// The suffix and the base of an integer constant decide its type.
 	NumTok struct {
-		Val int64
-		len int
+		Val        int64
+		len        int
+		IsUnsigned bool
+		IsLong     bool
+		IsDecimal  bool
+		IsChar     bool
 	}
 
 	// paramTok is a token of function-like macro parameter.
@@ -55,9 +63,17 @@ type (
 	}
 
 	// StrTok represents a string literal token.
+	// Prefix is one of "", "u8", "u", "U" and "L", which decides the encoding of the literal.
 	StrTok struct {
-		content string
-		len     int
+		Prefix string
+		chars  []strChar
+	}
+
+	// strChar is a character of a string literal. It is the value of a code unit when isUnit is set,
+	// e.g) \xff, and a code point otherwise.
+	strChar struct {
+		val    uint32
+		isUnit bool
 	}
 )
 
@@ -66,21 +82,76 @@ func (i *IDTok) Str() string       { return i.name }
 func (n *NumTok) Str() string      { return fmt.Sprintf("%d", n.Val) }
 func (p *paramTok) Str() string    { return "param" }
 func (r *ReservedTok) Str() string { return r.str }
-func (s *StrTok) Str() string      { return s.content }
+func (s *StrTok) Str() string      { return s.text() }
 
 func (e *EOFTok) Len() int      { return 0 }
 func (i *IDTok) Len() int       { return i.len }
 func (n *NumTok) Len() int      { return utf8.RuneCountInString(fmt.Sprintf("%d", n.Val)) }
 func (p *paramTok) Len() int    { return -1 }
 func (r *ReservedTok) Len() int { return r.len }
-func (s *StrTok) Len() int      { return s.len }
+func (s *StrTok) Len() int      { return len(s.Units()) }
 
 func newEOFTok() *EOFTok                                         { return &EOFTok{} }
 func newIDTok(str string, l int) *IDTok                          { return &IDTok{str, l} }
-func newNumTok(val int64, l int) *NumTok                         { return &NumTok{val, l} }
+func newNumTok(val int64, l int) *NumTok                         { return &NumTok{Val: val, len: l, IsDecimal: true} }
 func newParamTok(idx int) *paramTok                              { return &paramTok{idx} }
 func newReservedTok(str string, l int, isType bool) *ReservedTok { return &ReservedTok{str, l, isType} }
-func newStrTok(content string, l int) *StrTok                    { return &StrTok{content, l} }
+func newStrTok(prefix string, chars []strChar) *StrTok           { return &StrTok{prefix, chars} }
+
+// ElemSize returns the size of an element of the array of the string literal.
+func (s *StrTok) ElemSize() int {
+	switch s.Prefix {
+	case "u":
+		return 2
+	case "U", "L":
+		return 4
+	}
+	return 1
+}
+
+// Units returns the code units of the string literal including the terminating null character,
+// which are UTF-8 for a narrow literal, UTF-16 for u"" and UTF-32 for U"" and L"".
+func (s *StrTok) Units() []uint32 {
+	var units []uint32
+	max := uint32(1)<<uint(8*s.ElemSize()) - 1
+	for _, c := range s.chars {
+		switch {
+		case c.isUnit:
+			if c.val > max {
+				log.Fatalf("Escape sequence out of range in string literal: %d", c.val)
+			}
+			units = append(units, c.val)
+		case s.ElemSize() == 1:
+			for _, b := range []byte(string(rune(c.val))) {
+				units = append(units, uint32(b))
+			}
+		case s.ElemSize() == 2:
+			for _, u := range utf16.Encode([]rune{rune(c.val)}) {
+				units = append(units, uint32(u))
+			}
+		default:
+			units = append(units, c.val)
+		}
+	}
+	return append(units, 0)
+}
+
+// text returns the content of the string literal without the terminating null character.
+func (s *StrTok) text() string {
+	if s.ElemSize() == 1 {
+		units := s.Units()
+		b := make([]byte, len(units)-1)
+		for i := range b {
+			b[i] = byte(units[i])
+		}
+		return string(b)
+	}
+	var r []rune
+	for _, c := range s.chars {
+		r = append(r, rune(c.val))
+	}
+	return string(r)
+}
 
 // Tokenizer holds the structure defining a tokenizer object.
 type Tokenizer struct {
@@ -133,13 +204,19 @@ func (t *Tokenizer) readCharLiteral() Token {
 		return nil
 	}
 	t.pos++
-	c := int64(t.head())
-	t.pos++
+	ch := t.readChar()
+	if !ch.isUnit && ch.val > unicode.MaxASCII {
+		log.Fatalf("Character too large for char literal: %s", t.input[t.pos:])
+	}
+	// a char literal has the value of char converted to int, e.g) '\xff' is -1.
+	c := int64(int8(ch.val))
 	if t.head() != '\'' {
 		log.Fatalf("Char literal is too long: %s", t.input[t.pos:])
 	}
 	t.pos++
-	return newNumTok(c, 1)
+	tok := newNumTok(c, 1)
+	tok.IsChar = true
+	return tok
 }
 
 func (t *Tokenizer) readDigitLiteral() Token {
@@ -149,12 +226,18 @@ func (t *Tokenizer) readDigitLiteral() Token {
 	}
 	numStr := digitMatcher.FindString(s)
 	numLen := utf8.RuneCountInString(numStr)
-	num, err := strconv.ParseInt(numStr, 0, 64)
+	// constants up to the maximum of unsigned long are allowed, which are held as their bit patterns.
+	num, err := strconv.ParseUint(numStr, 0, 64)
 	if err != nil {
 		log.Fatalf("invalid number literal: %s", s)
 	}
-	t.pos += numLen
-	return newNumTok(num, numLen)
+	suffix := suffixMatcher.FindString(s[len(numStr):])
+	t.pos += numLen + len(suffix)
+	tok := newNumTok(int64(num), numLen+len(suffix))
+	tok.IsUnsigned = strings.ContainsAny(suffix, "uU")
+	tok.IsLong = strings.ContainsAny(suffix, "lL")
+	tok.IsDecimal = numStr == "0" || numStr[0] != '0'
+	return tok
 }
 
 func (t *Tokenizer) readID() Token {
@@ -170,8 +253,8 @@ func (t *Tokenizer) readID() Token {
 
 func (t *Tokenizer) readMultiCharOp() Token {
 	ops := [...]string{
-		"==", "!=", "<=", ">=", "->", "++", "--",
-		"+=", "-=", "*=", "/=", "&&", "||",
+		"...", "==", "!=", "<=", ">=", "->", "++", "--",
+		"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "&&", "||",
 		"<<=", ">>=", "<<", ">>",
 	}
 	s := t.cur()
@@ -217,23 +300,85 @@ func (t *Tokenizer) readRuneFrom(s string) Token {
 }
 
 func (t *Tokenizer) readStrLiteral() Token {
-	if t.head() != '"' {
+	prefix := strPrefixMatcher.FindString(t.cur())
+	if prefix == "" {
 		return nil
 	}
-	t.pos++
-	var s string
-	// TODO: escape charator for others. e.g) \t
+	t.pos += len(prefix)
+	var chars []strChar
 	for t.head() != '"' {
-		if t.head() == '\\' {
-			s += string(t.head())
+		if t.cur() == "" || t.head() == '\n' {
+			log.Fatalf("String literal unclosed: %s", t.input[t.pos:])
+		}
+		chars = append(chars, t.readChar())
+	}
+	t.pos++
+	return newStrTok(prefix[:len(prefix)-1], chars)
+}
+
+// readChar reads a character in a string or char literal, which may be an escape sequence.
+func (t *Tokenizer) readChar() strChar {
+	r, size := utf8.DecodeRuneInString(t.cur())
+	t.pos += size
+	if r != '\\' {
+		return strChar{val: uint32(r)}
+	}
+	r, size = utf8.DecodeRuneInString(t.cur())
+	t.pos += size
+	switch r {
+	case 'a':
+		return strChar{val: 7}
+	case 'b':
+		return strChar{val: 8}
+	case 'e':
+		// GNU extension
+		return strChar{val: 27}
+	case 'f':
+		return strChar{val: 12}
+	case 'n':
+		return strChar{val: 10}
+	case 'r':
+		return strChar{val: 13}
+	case 't':
+		return strChar{val: 9}
+	case 'v':
+		return strChar{val: 11}
+	case 'x':
+		return strChar{t.readHex(-1), true}
+	case 'u':
+		return strChar{val: t.readHex(4)}
+	case 'U':
+		return strChar{val: t.readHex(8)}
+	}
+	if '0' <= r && r <= '7' {
+		// up to 3 octal digits
+		val := uint32(r - '0')
+		for i := 0; i < 2 && '0' <= t.head() && t.head() <= '7'; i++ {
+			val = val*8 + uint32(t.head()-'0')
 			t.pos++
 		}
-		s += string(t.head())
+		return strChar{val, true}
+	}
+	// \\, \', \" and \? are the characters themselves.
+	return strChar{val: uint32(r)}
+}
+
+// readHex reads n hexadecimal digits, or as many as possible when n is negative.
+func (t *Tokenizer) readHex(n int) uint32 {
+	var val uint32
+	i := 0
+	for ; i != n; i++ {
+		d, err := strconv.ParseUint(string(t.head()), 16, 8)
+		if err != nil || t.cur() == "" {
+			break
+		}
+		val = val*16 + uint32(d)
 		t.pos++
 	}
-	s += string('\000')
-	t.pos++
-	return newStrTok(s, len(s))
+	if i == 0 || n > 0 && i < n {
+		log.Fatalf("Invalid escape sequence: %s", t.input[t.pos:])
+	}
+	return val
 }
 
 func (t *Tokenizer) trimSpace() {
@@ -292,7 +437,7 @@ func (t *Tokenizer) Tokenize() []Token {
 			continue
 		}
 
-		if tok := t.readRuneFrom("+-*/(){}[]<>;=,&.!|^:?~#"); tok != nil {
+		if tok := t.readRuneFrom("+-*/%(){}[]<>;=,&.!|^:?~#"); tok != nil {
 			toks = append(toks, tok)
 			continue
 		}
@@ -308,5 +453,32 @@ func (t *Tokenizer) Tokenize() []Token {
 		toks = append(toks, newEOFTok())
 	}
 	p := newPreprocessor(toks, t.addEOF, t.filePath)
-	return p.Preprocess()
+	return concatStrs(p.Preprocess())
+}
+
+// concatStrs concatenates adjacent string literals, which is translation phase 6.
+// An unprefixed literal takes the prefix of the others.
+func concatStrs(toks []Token) []Token {
+	var res []Token
+	for _, tok := range toks {
+		s, ok := tok.(*StrTok)
+		if !ok || len(res) == 0 {
+			res = append(res, tok)
+			continue
+		}
+		last, ok := res[len(res)-1].(*StrTok)
+		if !ok {
+			res = append(res, tok)
+			continue
+		}
+		prefix := last.Prefix
+		if prefix == "" {
+			prefix = s.Prefix
+		} else if s.Prefix != "" && s.Prefix != prefix {
+			log.Fatalf("Concatenation of string literals with different prefixes: %s and %s", last.Prefix, s.Prefix)
+		}
+		chars := append(append([]strChar{}, last.chars...), s.chars...)
+		res[len(res)-1] = newStrTok(prefix, chars)
+	}
+	return res
 }
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"fmt"
+	"io"
+)
+
+// DumpRecordLayout writes the offset and the size of each member of the struct or union t,
+// together with the padding between them.
+func DumpRecordLayout(w io.Writer, name string, t Composite) {
+	var members []*Member
+	switch t := t.(type) {
+	case *Struct:
+		members = t.Members
+	case *Union:
+		members = t.Members
+	}
+	fmt.Fprintf(w, "*** Dumping record layout of %s\n", name)
+	fmt.Fprintf(w, "%8s %8s  %s\n", "offset", "size", "member")
+	// end is the bit position right after the members printed so far.
+	end := 0
+	for _, m := range members {
+		start := m.Offset*8 + m.BitOffset
+		if start > end {
+			dumpPadding(w, end, start)
+		}
+		name := m.Name
+		if name == "" {
+			name = "(anonymous)"
+		}
+		size := m.Type.Size() * 8
+		if m.IsBitField {
+			size = m.BitWidth
+		}
+		fmt.Fprintf(w, "%8s %8s  %s\n", bitsStr(start), bitsStr(size), name)
+		if end < start+size {
+			end = start + size
+		}
+	}
+	if t.Size()*8 > end {
+		dumpPadding(w, end, t.Size()*8)
+	}
+	fmt.Fprintf(w, "%8s [sizeof=%d, align=%d]\n", "", t.Size(), t.Alignment())
+}
+
+func dumpPadding(w io.Writer, from int, to int) {
+	fmt.Fprintf(w, "%8s %8s  (padding)\n", bitsStr(from), bitsStr(to-from))
+}
+
+// bitsStr formats the number of bits in bytes, appending the remaining bits after a colon if any.
+func bitsStr(bits int) string {
+	if bits%8 == 0 {
+		return fmt.Sprintf("%d", bits/8)
+	}
+	return fmt.Sprintf("%d:%d", bits/8, bits%8)
+}
@@ -0,0 +1,172 @@
+package types
+
+// Quals holds the type qualifiers. Every type embeds it.
+type Quals struct {
+	IsConst    bool
+	IsVolatile bool
+	IsRestrict bool
+	// IsAtomic is set by _Atomic, whose objects are accessed by atomic operations.
+	IsAtomic bool
+	// AlignAttr is the alignment given to a typedef by the aligned attribute, or 0.
+	AlignAttr int
+}
+
+func (q *Quals) quals() *Quals { return q }
+
+// IsZero reports whether no qualifier is set.
+func (q Quals) IsZero() bool {
+	return !q.IsConst && !q.IsVolatile && !q.IsRestrict && !q.IsAtomic && q.AlignAttr == 0
+}
+
+// Merge returns the union of q and r. The alignment of r overrides that of q if any.
+func (q Quals) Merge(r Quals) Quals {
+	align := q.AlignAttr
+	if r.AlignAttr > 0 {
+		align = r.AlignAttr
+	}
+	return Quals{
+		IsConst:    q.IsConst || r.IsConst,
+		IsVolatile: q.IsVolatile || r.IsVolatile,
+		IsRestrict: q.IsRestrict || r.IsRestrict,
+		IsAtomic:   q.IsAtomic || r.IsAtomic,
+		AlignAttr:  align,
+	}
+}
+
+// aligned returns the natural alignment a overridden by the aligned attribute of a typedef if any.
+func (q *Quals) aligned(a int) int {
+	if q.AlignAttr > 0 {
+		return q.AlignAttr
+	}
+	return a
+}
+
+// withoutAlign returns q without the alignment, which does not affect the compatibility of types.
+func (q Quals) withoutAlign() Quals {
+	q.AlignAttr = 0
+	return q
+}
+
+// Aligned returns a copy of t whose alignment is align, which may be lower than the natural one as in GCC.
+func Aligned(t Type, align int) Type {
+	if a, ok := t.(*Arr); ok {
+		// unlike qualifiers, the alignment applies to the array itself rather than its elements.
+		c := *a
+		c.AlignAttr = align
+		return &c
+	}
+	return Qualify(t, Quals{AlignAttr: align})
+}
+
+// QualsOf returns the qualifiers of t, which are those of the elements for an array.
+func QualsOf(t Type) Quals {
+	switch t := t.(type) {
+	case *Arr:
+		return QualsOf(t.Of)
+	case *VLA:
+		return QualsOf(t.Of)
+	}
+	return *t.quals()
+}
+
+// IsConst reports whether t is const-qualified.
+func IsConst(t Type) bool { return QualsOf(t).IsConst }
+
+// IsVolatile reports whether t is volatile-qualified.
+func IsVolatile(t Type) bool { return QualsOf(t).IsVolatile }
+
+// IsAtomic reports whether t is _Atomic-qualified.
+func IsAtomic(t Type) bool { return QualsOf(t).IsAtomic }
+
+// Qualify returns t with the qualifiers q added. t itself is left unchanged.
+func Qualify(t Type, q Quals) Type {
+	if q.IsZero() {
+		return t
+	}
+	return withQuals(t, func(c Quals) Quals { return c.Merge(q) })
+}
+
+// Unqualified returns t without qualifiers, e.g) for typeof_unqual and the lvalue conversion.
+func Unqualified(t Type) Type {
+	if QualsOf(t).IsZero() {
+		return t
+	}
+	return withQuals(t, func(Quals) Quals { return Quals{} })
+}
+
+// withQuals returns a copy of t whose qualifiers are replaced by f applied to them.
+func withQuals(t Type, f func(Quals) Quals) Type {
+	switch t := t.(type) {
+	case *Arr:
+		// qualifiers of an array type apply to its elements.
+		c := *t
+		c.Of = withQuals(t.Of, f)
+		return &c
+	case *Bool:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Char:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Empty:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Enum:
+		c := *t
+		c.Quals = f(c.Quals)
+		c.origin = t.declared()
+		return &c
+	case *Fn:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Int:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Long:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Ptr:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Short:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	case *Struct:
+		c := *t
+		c.Quals = f(c.Quals)
+		c.variants = nil
+		o := t.declared()
+		c.origin = o
+		if !o.IsComplete {
+			o.variants = append(o.variants, &c)
+		}
+		return &c
+	case *Union:
+		c := *t
+		c.Quals = f(c.Quals)
+		c.variants = nil
+		o := t.declared()
+		c.origin = o
+		if !o.IsComplete {
+			o.variants = append(o.variants, &c)
+		}
+		return &c
+	case *VLA:
+		c := *t
+		c.Of = withQuals(t.Of, f)
+		return &c
+	case *Void:
+		c := *t
+		c.Quals = f(c.Quals)
+		return &c
+	}
+	return t
+}
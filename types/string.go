@@ -0,0 +1,75 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String renders the type t in C syntax for diagnostics, e.g) `const unsigned char *`.
+func String(t Type) string {
+	switch t := t.(type) {
+	case *Ptr:
+		s := String(t.To)
+		if !strings.HasSuffix(s, "*") {
+			s += " "
+		}
+		// the qualifiers of a pointer follow the asterisk, e.g) char *const
+		return s + "*" + strings.Join(qualNames(t.Quals), " ")
+	case *Arr:
+		if t.Len < 0 {
+			return String(t.Of) + "[]"
+		}
+		return fmt.Sprintf("%s[%d]", String(t.Of), t.Len)
+	case *VLA:
+		return String(t.Of) + "[*]"
+	case *Fn:
+		return String(t.RetTy) + " ()"
+	}
+	return strings.Join(append(qualNames(QualsOf(t)), baseName(t)), " ")
+}
+
+// qualNames returns the keywords of the qualifiers q.
+func qualNames(q Quals) (names []string) {
+	if q.IsConst {
+		names = append(names, "const")
+	}
+	if q.IsVolatile {
+		names = append(names, "volatile")
+	}
+	if q.IsRestrict {
+		names = append(names, "restrict")
+	}
+	if q.IsAtomic {
+		names = append(names, "_Atomic")
+	}
+	return
+}
+
+// baseName returns the name of the type t which is neither a pointer, an array nor a function.
+func baseName(t Type) string {
+	var s string
+	switch t.(type) {
+	case *Bool:
+		return "_Bool"
+	case *Char:
+		s = "char"
+	case *Short:
+		s = "short"
+	case *Int:
+		s = "int"
+	case *Long:
+		s = "long"
+	case *Enum:
+		return "enum"
+	case *Struct:
+		return "struct"
+	case *Union:
+		return "union"
+	default:
+		return "void"
+	}
+	if IsUnsigned(t) {
+		s = "unsigned " + s
+	}
+	return s
+}
@@ -5,6 +5,7 @@ type (
 	Type interface {
 		Alignment() int
 		Size() int
+		quals() *Quals
 	}
 
 	// Pointing is the interface of pointer and array type.
@@ -13,103 +14,469 @@ type (
 		Base() Type
 	}
 
+	// Composite is the interface of struct and union type.
+	Composite interface {
+		Type
+		FindMember(name string) *Member
+	}
+
 	// Arr represents array type.
 	Arr struct {
+		Quals
 		Of  Type
 		Len int
 	}
 
-	Bool  struct{}
-	Char  struct{}
-	Empty struct{}
-	Enum  struct{}
-	Fn    struct {
+	Bool struct{ Quals }
+	Char struct {
+		Quals
+		IsUnsigned bool
+	}
+	Empty struct{ Quals }
+
+	// Enum represents enum type, which has the representation of its underlying integer type Base.
+	// IsFixed is set for an enum whose underlying type is specified, e.g) enum E : unsigned char.
+	Enum struct {
+		Quals
+		Base    Type
+		IsFixed bool
+
+		origin *Enum
+	}
+
+	// Fn represents function type. Params and IsVariadic are meaningful only when HasProto is set,
+	// since a function declared with empty parentheses, e.g) int f(), takes unspecified arguments.
+	Fn struct {
+		Quals
 		RetTy      Type
+		Params     []Type
+		IsVariadic bool
 		IsComplete bool
+		HasProto   bool
 	}
 
-	Int  struct{}
-	Long struct{}
+	Int struct {
+		Quals
+		IsUnsigned bool
+	}
+	Long struct {
+		Quals
+		IsUnsigned bool
+	}
 
 	Ptr struct {
+		Quals
 		To Type
 	}
 
-	Short struct{}
+	Short struct {
+		Quals
+		IsUnsigned bool
+	}
 
 	Struct struct {
-		Align   int
-		Members []*Member
-		Sz      int
+		Quals
+		Align      int
+		Members    []*Member
+		Sz         int
+		IsComplete bool
+
+		// variants holds the qualified copies made while the struct was incomplete.
+		variants []*Struct
+		// origin is the declared struct which this qualified copy is made from.
+		origin *Struct
+	}
+
+	Union struct {
+		Quals
+		Align      int
+		Members    []*Member
+		Sz         int
+		IsComplete bool
+
+		variants []*Union
+		origin   *Union
+	}
+
+	// VLA represents variable length array type, whose length is known only at runtime.
+	// An object of VLA type is allocated on the stack, and its frame slot holds the address of the storage.
+	// Len is the ast.Node computing the length, and SizeVar is the *vars.LVar holding the size in bytes once computed.
+	// They are held as interface{} since this package can not depend on ast and vars.
+	VLA struct {
+		Quals
+		Of      Type
+		Len     interface{}
+		SizeVar interface{}
 	}
 
-	Void struct{}
+	Void struct{ Quals }
 )
 
-func NewArr(of Type, len int) *Arr      { return &Arr{of, len} }
-func NewBool() *Bool                    { return &Bool{} }
-func NewChar() *Char                    { return &Char{} }
-func NewEmpty() *Empty                  { return &Empty{} }
-func NewEnum() *Enum                    { return &Enum{} }
-func NewFn(t Type, isComplete bool) *Fn { return &Fn{t, isComplete} }
-func NewInt() *Int                      { return &Int{} }
-func NewLong() *Long                    { return &Long{} }
-func NewPtr(to Type) *Ptr               { return &Ptr{to} }
-func NewShort() *Short                  { return &Short{} }
+func NewArr(of Type, len int) *Arr { return &Arr{Of: of, Len: len} }
+func NewBool() *Bool               { return &Bool{} }
+func NewChar() *Char               { return &Char{} }
+func NewUChar() *Char              { return &Char{IsUnsigned: true} }
+func NewEmpty() *Empty             { return &Empty{} }
+func NewEnum(base Type, isFixed bool) *Enum {
+	return &Enum{Base: base, IsFixed: isFixed}
+}
+func NewFn(ret Type, params []Type, isVariadic bool, isComplete bool) *Fn {
+	return &Fn{RetTy: ret, Params: params, IsVariadic: isVariadic, IsComplete: isComplete}
+}
+func NewInt() *Int        { return &Int{} }
+func NewUInt() *Int       { return &Int{IsUnsigned: true} }
+func NewLong() *Long      { return &Long{} }
+func NewULong() *Long     { return &Long{IsUnsigned: true} }
+func NewPtr(to Type) *Ptr { return &Ptr{To: to} }
+func NewShort() *Short    { return &Short{} }
+func NewUShort() *Short   { return &Short{IsUnsigned: true} }
 func NewStruct(align int, m []*Member, Size int) *Struct {
-	return &Struct{align, m, Size}
+	return &Struct{Align: align, Members: m, Sz: Size, IsComplete: true}
+}
+func NewIncompleteStruct() *Struct { return &Struct{Align: 1} }
+func NewUnion(align int, m []*Member, size int) *Union {
+	return &Union{Align: align, Members: m, Sz: size, IsComplete: true}
+}
+func NewIncompleteUnion() *Union { return &Union{Align: 1} }
+func NewVoid() *Void             { return &Void{} }
+func NewVLA(of Type, len interface{}, sizeVar interface{}) *VLA {
+	return &VLA{Of: of, Len: len, SizeVar: sizeVar}
 }
-func NewVoid() *Void { return &Void{} }
 
 func NewMember(name string, offset int, t Type) *Member {
-	return &Member{name, offset, t}
+	return &Member{Name: name, Offset: offset, Type: t}
+}
+
+// NewBitField creates a bit-field member whose storage unit of type t starts at offset.
+func NewBitField(name string, offset int, t Type, bitOffset int, bitWidth int) *Member {
+	return &Member{name, offset, t, true, bitOffset, bitWidth}
+}
+
+// Complete fills in the incomplete struct s and its qualified copies with the definition def.
+func (s *Struct) Complete(def *Struct) {
+	for _, t := range append(s.variants, s) {
+		t.Align, t.Members, t.Sz, t.IsComplete = def.Align, def.Members, def.Sz, true
+	}
+	s.variants = nil
+}
+
+// Complete fills in the incomplete union u with the definition def.
+func (u *Union) Complete(def *Union) {
+	for _, t := range append(u.variants, u) {
+		t.Align, t.Members, t.Sz, t.IsComplete = def.Align, def.Members, def.Sz, true
+	}
+	u.variants = nil
+}
+
+// declared returns the struct which s is declared as, stripping the qualified copies.
+func (s *Struct) declared() *Struct {
+	if s.origin != nil {
+		return s.origin
+	}
+	return s
+}
+
+func (e *Enum) declared() *Enum {
+	if e.origin != nil {
+		return e.origin
+	}
+	return e
+}
+
+func (u *Union) declared() *Union {
+	if u.origin != nil {
+		return u.origin
+	}
+	return u
 }
 
 func AlignTo(n int, align int) int {
 	return (n + align - 1) / align * align
 }
 
-func (a *Arr) Alignment() int    { return a.Of.Alignment() }
-func (b *Bool) Alignment() int   { return 1 }
-func (c *Char) Alignment() int   { return 1 }
+func (a *Arr) Alignment() int    { return a.aligned(a.Of.Alignment()) }
+func (b *Bool) Alignment() int   { return b.aligned(1) }
+func (c *Char) Alignment() int   { return c.aligned(1) }
 func (e *Empty) Alignment() int  { return 0 }
-func (e *Enum) Alignment() int   { return 4 }
+func (e *Enum) Alignment() int   { return e.aligned(e.Base.Alignment()) }
 func (f *Fn) Alignment() int     { return 1 }
-func (i *Int) Alignment() int    { return 4 }
-func (l *Long) Alignment() int   { return 8 }
-func (p *Ptr) Alignment() int    { return 8 }
-func (s *Short) Alignment() int  { return 2 }
-func (s *Struct) Alignment() int { return s.Align }
+func (i *Int) Alignment() int    { return i.aligned(4) }
+func (l *Long) Alignment() int   { return l.aligned(8) }
+func (p *Ptr) Alignment() int    { return p.aligned(8) }
+func (s *Short) Alignment() int  { return s.aligned(2) }
+func (s *Struct) Alignment() int { return s.aligned(s.Align) }
+func (u *Union) Alignment() int  { return u.aligned(u.Align) }
+func (v *VLA) Alignment() int    { return v.Of.Alignment() }
 func (v *Void) Alignment() int   { return 1 }
 
 func (a *Arr) Size() int    { return a.Len * a.Of.Size() }
 func (b *Bool) Size() int   { return 1 }
 func (c *Char) Size() int   { return 1 }
 func (e *Empty) Size() int  { return 0 }
-func (e *Enum) Size() int   { return 4 }
+func (e *Enum) Size() int   { return e.Base.Size() }
 func (f *Fn) Size() int     { return 1 }
 func (i *Int) Size() int    { return 4 }
 func (l *Long) Size() int   { return 8 }
 func (p *Ptr) Size() int    { return 8 }
 func (s *Short) Size() int  { return 2 }
 func (s *Struct) Size() int { return s.Sz }
-func (v *Void) Size() int   { return 1 }
+func (u *Union) Size() int  { return u.Sz }
+
+// Size returns the size of the frame slot of a VLA, since the size of the array itself is only known at runtime.
+func (v *VLA) Size() int  { return 8 }
+func (v *Void) Size() int { return 1 }
 
 func (a *Arr) Base() Type { return a.Of }
 func (p *Ptr) Base() Type { return p.To }
+func (v *VLA) Base() Type { return v.Of }
+
+// IsVariablyModified reports whether t is a VLA or is derived from one, e.g) a pointer to a VLA.
+func IsVariablyModified(t Type) bool {
+	switch t := t.(type) {
+	case *VLA:
+		return true
+	case *Ptr:
+		return IsVariablyModified(t.To)
+	case *Arr:
+		return IsVariablyModified(t.Of)
+	}
+	return false
+}
+
+// IsIncomplete reports whether t is an array of unknown length, or a struct or union type whose members are not defined yet.
+func IsIncomplete(t Type) bool {
+	switch t := t.(type) {
+	case *Arr:
+		return t.Len < 0
+	case *Struct:
+		return !t.IsComplete
+	case *Union:
+		return !t.IsComplete
+	}
+	return false
+}
+
+// IsUnsigned reports whether t is an unsigned integer type.
+func IsUnsigned(t Type) bool {
+	switch t := t.(type) {
+	case *Bool:
+		return true
+	case *Char:
+		return t.IsUnsigned
+	case *Short:
+		return t.IsUnsigned
+	case *Int:
+		return t.IsUnsigned
+	case *Long:
+		return t.IsUnsigned
+	case *Enum:
+		return IsUnsigned(t.Base)
+	}
+	return false
+}
+
+// IsInteger reports whether t is an integer type, including _Bool and enum.
+func IsInteger(t Type) bool {
+	switch t.(type) {
+	case *Bool, *Char, *Short, *Int, *Long, *Enum:
+		return true
+	}
+	return false
+}
+
+// Promote returns the type of t after the integer promotions, which is t itself unless it is narrower than int.
+func Promote(t Type) Type {
+	if e, ok := t.(*Enum); ok {
+		t = e.Base
+	}
+	switch t := t.(type) {
+	case *Bool, *Char, *Short:
+		// int can represent every value of these types.
+		return NewInt()
+	case *Int:
+		if t.IsUnsigned {
+			return NewUInt()
+		}
+		return NewInt()
+	case *Long:
+		if t.IsUnsigned {
+			return NewULong()
+		}
+		return NewLong()
+	}
+	return t
+}
+
+// PromoteBitField returns the type of a bit-field of type t and width bits after the integer promotions.
+func PromoteBitField(t Type, width int) Type {
+	if width < 32 || width == 32 && !IsUnsigned(t) {
+		return NewInt()
+	}
+	if width == 32 {
+		return NewUInt()
+	}
+	return Promote(t)
+}
+
+// UsualArith returns the common type of the operands of types l and r after the usual arithmetic conversions.
+// Pointers are treated as unsigned long.
+func UsualArith(l Type, r Type) Type {
+	l, r = promoteOrULong(l), promoteOrULong(r)
+	if l.Size() != r.Size() {
+		// the wider type can represent every value of the narrower one.
+		if l.Size() > r.Size() {
+			return l
+		}
+		return r
+	}
+	if IsUnsigned(r) {
+		return r
+	}
+	return l
+}
+
+func promoteOrULong(t Type) Type {
+	if IsInteger(t) {
+		return Promote(t)
+	}
+	return NewULong()
+}
+
+// isProtoCompatible reports whether the prototype of f is compatible with a function type without prototype,
+// which passes the arguments after the default argument promotions.
+func isProtoCompatible(f *Fn) bool {
+	if f.IsVariadic {
+		return false
+	}
+	for _, p := range f.Params {
+		p = Unqualified(p)
+		if IsInteger(p) && !IsCompatible(p, Promote(p)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCompatible reports whether t and u are compatible types, following C11 6.2.7.
+// Qualifiers are significant.
+func IsCompatible(t Type, u Type) bool {
+	if QualsOf(t).withoutAlign() != QualsOf(u).withoutAlign() {
+		return false
+	}
+	if _, ok := u.(*Enum); ok {
+		if _, ok := t.(*Enum); !ok {
+			t, u = u, t
+		}
+	}
+	switch t := t.(type) {
+	case *Arr:
+		switch u := u.(type) {
+		case *Arr:
+			return IsCompatible(t.Of, u.Of) && (t.Len < 0 || u.Len < 0 || t.Len == u.Len)
+		case *VLA:
+			return IsCompatible(t.Of, u.Of)
+		}
+	case *VLA:
+		switch u := u.(type) {
+		case *Arr:
+			return IsCompatible(t.Of, u.Of)
+		case *VLA:
+			return IsCompatible(t.Of, u.Of)
+		}
+	case *Ptr:
+		if u, ok := u.(*Ptr); ok {
+			return IsCompatible(t.To, u.To)
+		}
+	case *Fn:
+		u, ok := u.(*Fn)
+		if !ok || !IsCompatible(t.RetTy, u.RetTy) {
+			return false
+		}
+		switch {
+		case !t.HasProto && !u.HasProto:
+			return true
+		case !u.HasProto:
+			return isProtoCompatible(t)
+		case !t.HasProto:
+			return isProtoCompatible(u)
+		}
+		if len(t.Params) != len(u.Params) || t.IsVariadic != u.IsVariadic {
+			return false
+		}
+		for i := range t.Params {
+			if !IsCompatible(Unqualified(t.Params[i]), Unqualified(u.Params[i])) {
+				return false
+			}
+		}
+		return true
+	case *Struct:
+		u, ok := u.(*Struct)
+		return ok && t.declared() == u.declared()
+	case *Union:
+		u, ok := u.(*Union)
+		return ok && t.declared() == u.declared()
+	case *Bool:
+		_, ok := u.(*Bool)
+		return ok
+	case *Char:
+		u, ok := u.(*Char)
+		return ok && t.IsUnsigned == u.IsUnsigned
+	case *Short:
+		u, ok := u.(*Short)
+		return ok && t.IsUnsigned == u.IsUnsigned
+	case *Int:
+		u, ok := u.(*Int)
+		return ok && t.IsUnsigned == u.IsUnsigned
+	case *Long:
+		u, ok := u.(*Long)
+		return ok && t.IsUnsigned == u.IsUnsigned
+	case *Enum:
+		if u, ok := u.(*Enum); ok {
+			return t.declared() == u.declared()
+		}
+		// an enum is compatible with its underlying type.
+		return IsCompatible(Qualify(t.Base, QualsOf(t)), u)
+	case *Void:
+		_, ok := u.(*Void)
+		return ok
+	}
+	return false
+}
 
 type Member struct {
 	Name   string
 	Offset int
 	Type   Type
+
+	// BitOffset and BitWidth are only meaningful when IsBitField is set.
+	IsBitField bool
+	BitOffset  int
+	BitWidth   int
 }
 
 // FindMember retrieves the struct member with the given name.
 func (s *Struct) FindMember(name string) *Member {
-	for _, member := range s.Members {
+	return findMember(s.Members, name)
+}
+
+// FindMember retrieves the union member with the given name.
+func (u *Union) FindMember(name string) *Member {
+	return findMember(u.Members, name)
+}
+
+func findMember(members []*Member, name string) *Member {
+	for _, member := range members {
 		if name == member.Name {
 			return member
 		}
+		// search in the anonymous struct or union member.
+		if c, ok := member.Type.(Composite); ok && member.Name == "" {
+			if m := c.FindMember(name); m != nil {
+				nested := *m
+				nested.Offset += member.Offset
+				return &nested
+			}
+		}
 	}
 	return nil
 }
@@ -12,6 +12,8 @@ type (
 	// GVarInit is the interface of global variable initializer.
 	GVarInit interface {
 		Gen(types.Type)
+		// IsZero reports whether every byte of the initialized object is zero.
+		IsZero() bool
 	}
 
 	// GVarInitArr represents an array initializer of global variable.
@@ -19,14 +21,16 @@ type (
 		body []GVarInit
 	}
 
-	// GVarInitLabel represents a reference to another global variable
+	// GVarInitLabel represents the address of another global variable plus the addend, resolved by the linker.
 	GVarInitLabel struct {
-		label string
+		label  string
+		addend int64
 	}
 
-	// GVarInitStr represents a raw string global initializer.
+	// GVarInitStr represents a string literal initializer, whose code units have ElemSize bytes each.
 	GVarInitStr struct {
-		Content string
+		Units    []uint32
+		ElemSize int
 	}
 
 	// GVarInitInt represents a integer initializer.
@@ -45,12 +49,12 @@ func NewGVarInitArr(body []GVarInit) *GVarInitArr {
 	return &GVarInitArr{body}
 }
 
-func NewGVarInitLabel(label string) *GVarInitLabel {
-	return &GVarInitLabel{label}
+func NewGVarInitLabel(label string, addend int64) *GVarInitLabel {
+	return &GVarInitLabel{label, addend}
 }
 
-func NewGVarInitStr(content string) *GVarInitStr {
-	return &GVarInitStr{content}
+func NewGVarInitStr(units []uint32, elemSize int) *GVarInitStr {
+	return &GVarInitStr{units, elemSize}
 }
 
 func NewGVarInitInt(i int64, sz int) *GVarInitInt {
@@ -62,12 +66,10 @@ func NewGVarInitZero(len int) *GVarInitZero {
 }
 
 func (init *GVarInitArr) Gen(t types.Type) {
-	for i, e := range init.body {
+	for _, e := range init.body {
 		switch t := t.(type) {
 		case *types.Arr:
 			e.Gen(t.Base())
-		case *types.Struct:
-			e.Gen(t.Members[i].Type)
 		default:
 			e.Gen(t)
 		}
@@ -75,13 +77,58 @@ func (init *GVarInitArr) Gen(t types.Type) {
 }
 
 func (init *GVarInitLabel) Gen(_ types.Type) {
-	fmt.Printf("	.quad %s\n", init.label)
+	if init.addend == 0 {
+		fmt.Printf("	.quad %s\n", init.label)
+		return
+	}
+	fmt.Printf("	.quad %s%+d\n", init.label, init.addend)
 }
 
 func (init *GVarInitStr) Gen(_ types.Type) {
-	trimmed := strings.TrimRight(init.Content, string('\000'))
-	fmt.Printf("	.string \"%s\"\n", trimmed)
-	fmt.Printf("	.zero %d\n", len(init.Content)-len(trimmed))
+	if init.ElemSize != 1 {
+		directive := map[int]string{2: ".value", 4: ".long"}[init.ElemSize]
+		for _, u := range init.Units {
+			fmt.Printf("	%s %d\n", directive, u)
+		}
+		return
+	}
+	n := len(init.Units)
+	for n > 0 && init.Units[n-1] == 0 {
+		n--
+	}
+	var b strings.Builder
+	for _, u := range init.Units[:n] {
+		switch c := byte(u); {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	nuls := len(init.Units) - n
+	if nuls == 0 {
+		fmt.Printf("	.ascii \"%s\"\n", b.String())
+		return
+	}
+	// .string terminates the string with a NUL.
+	fmt.Printf("	.string \"%s\"\n", b.String())
+	if nuls > 1 {
+		fmt.Printf("	.zero %d\n", nuls-1)
+	}
+}
+
+// IsCString reports whether the string is terminated by its only null character,
+// which can be placed in a section merging identical strings.
+func (init *GVarInitStr) IsCString() bool {
+	for i, u := range init.Units {
+		if (u == 0) != (i == len(init.Units)-1) {
+			return false
+		}
+	}
+	return len(init.Units) > 0
 }
 
 func (init *GVarInitInt) Gen(_ types.Type) {
@@ -102,3 +149,24 @@ func (init *GVarInitInt) Gen(_ types.Type) {
 func (init *GVarInitZero) Gen(_ types.Type) {
 	fmt.Printf("	.zero %d\n", init.len)
 }
+
+func (init *GVarInitArr) IsZero() bool {
+	for _, e := range init.body {
+		if !e.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+func (init *GVarInitLabel) IsZero() bool { return false }
+func (init *GVarInitStr) IsZero() bool {
+	for _, u := range init.Units {
+		if u != 0 {
+			return false
+		}
+	}
+	return true
+}
+func (init *GVarInitInt) IsZero() bool  { return init.val == 0 }
+func (init *GVarInitZero) IsZero() bool { return true }
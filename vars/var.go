@@ -13,19 +13,56 @@ type (
 		Type() types.Type
 	}
 
-	// GVar represents global variable.
+	// GVar represents global variable, which is a variable with static storage duration or a function.
 	GVar struct {
+		// Emit is set when the variable is defined in this translation unit.
+		// It is defined without initializer by a tentative definition, e.g) int x;
 		Emit bool
 		Init GVarInit
-		name string
-		ty   types.Type
+		// Align is the alignment specified by _Alignas or the aligned attribute, or 0.
+		Align int
+		// IsStatic is set for a variable with internal linkage or no linkage, whose label is not visible from other translation units.
+		IsStatic bool
+		// IsStrLit is set for the array of a string literal, which is never modified.
+		IsStrLit bool
+		// IsReferenced is set when the variable or function is referred to in the translation unit.
+		IsReferenced bool
+		// IsTLS is set for a thread-local variable, each thread of which has its own instance.
+		IsTLS bool
+		// Fn is the function which a static local variable belongs to, or nil.
+		Fn *GVar
+		SymAttrs
+		name  string
+		label string
+		ty    types.Type
+	}
+
+	// SymAttrs holds the attributes of the symbol of a global variable or function given by __attribute__.
+	SymAttrs struct {
+		// Section is the section where the symbol is placed instead of the default one, or empty.
+		Section string
+		// Alias is the symbol which this symbol is defined as an alias of, or empty.
+		Alias string
+		// Visibility is the ELF visibility such as hidden, or empty for the default visibility.
+		Visibility string
+		IsWeak     bool
+		// IsUsed is set for a symbol which is emitted even if it is never referenced.
+		IsUsed bool
+		// IsCtor and IsDtor are set for a function called before and after main respectively.
+		// CtorPriority and DtorPriority are their priorities, or 0 when not specified.
+		IsCtor, IsDtor             bool
+		CtorPriority, DtorPriority int
 	}
 
 	// LVar represents local variable.
 	LVar struct {
 		name   string
 		Offset int
-		ty     types.Type
+		// Align is the alignment specified by _Alignas or the aligned attribute, or 0.
+		Align int
+		// IsReferenced is set when the variable is referred to in the function.
+		IsReferenced bool
+		ty           types.Type
 	}
 
 	// TypeDef represents a typedef tag.
@@ -38,7 +75,7 @@ type (
 	Enum struct {
 		name string
 		ty   types.Type
-		Val  int
+		Val  int64
 	}
 )
 
@@ -54,8 +91,47 @@ func (e *Enum) Type() types.Type    { return e.ty }
 
 func (v *GVar) SetType(t types.Type) { v.ty = t }
 
+// Label returns the label of v in assembly, which is its name unless set by SetLabel.
+func (v *GVar) Label() string {
+	if v.label != "" {
+		return v.label
+	}
+	return v.name
+}
+
+// SetLabel sets the label of v, e.g) to distinguish static local variables of the same name.
+func (v *GVar) SetLabel(label string) { v.label = label }
+
+func (e *Enum) SetType(t types.Type) { e.ty = t }
+
+// IsDiscarded reports whether v is a static variable or function which is never referenced, so that it need not be emitted.
+// A static local variable is discarded along with its function, since it may hold the addresses of the labels in it.
+func (v *GVar) IsDiscarded() bool {
+	if v.Fn != nil && v.Fn.IsDiscarded() {
+		return true
+	}
+	return v.IsStatic && !v.IsReferenced && !v.IsUsed && !v.IsCtor && !v.IsDtor
+}
+
+// Alignment returns the alignment of v, which _Alignas may make stricter than that of its type.
+func (v *GVar) Alignment() int { return alignment(v.ty, v.Align) }
+
+// Alignment returns the alignment of v, which _Alignas may make stricter than that of its type.
+func (v *LVar) Alignment() int { return alignment(v.ty, v.Align) }
+
+func alignment(t types.Type, align int) int {
+	if _, ok := t.(*types.VLA); ok {
+		// the frame slot holds the address of the storage.
+		return 8
+	}
+	if a := t.Alignment(); a > align {
+		return a
+	}
+	return align
+}
+
 func NewGVar(emit bool, name string, t types.Type, init GVarInit) *GVar {
-	return &GVar{emit, init, name, t}
+	return &GVar{Emit: emit, Init: init, name: name, ty: t}
 }
 
 func NewLVar(name string, t types.Type) *LVar {
@@ -66,7 +142,7 @@ func NewTypeDef(name string, t types.Type) *TypeDef {
 	return &TypeDef{name, t}
 }
 
-func NewEnum(name string, t types.Type, val int) *Enum {
+func NewEnum(name string, t types.Type, val int64) *Enum {
 	return &Enum{name, t, val}
 }
 